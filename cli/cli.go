@@ -0,0 +1,263 @@
+// Package cli implements the pipelines command: validating declarative pipeline
+// definitions (see package spec), printing their graph and running them.
+//
+// cmd/pipelines runs it with the standard node kinds. To compile in custom kinds,
+// write a main package that registers them and calls Main:
+//
+//	func main() {
+//		r := std.Registry()
+//		spec.RegisterProcessor(r, "resize", spec.Schema{...}, newResizeNode)
+//		cli.Main(r)
+//	}
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/spec"
+)
+
+// Exit codes of Run.
+const (
+	ExitOK          = 0
+	ExitFailure     = 1
+	ExitUsage       = 2
+	ExitInterrupted = 130
+)
+
+const usage = `Usage: pipelines <command> [flags] <file>
+
+Commands:
+  validate <file>...   check definitions against the registered node kinds
+  graph <file>         print the pipeline graph as DOT or Mermaid
+  run <file>           run a pipeline with live per-node progress
+  kinds                list the registered node kinds and their params
+
+Run "pipelines <command> -h" for the flags of a command.
+`
+
+// Main runs the command with os.Args and exits with its exit code.
+func Main(r *spec.Registry) {
+	os.Exit(Run(r, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Run runs the command with args, without the program name, and returns the exit code.
+func Run(r *spec.Registry, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+
+	c := &command{registry: r, stdout: stdout, stderr: stderr}
+	switch args[0] {
+	case "validate":
+		return c.validate(args[1:])
+	case "graph":
+		return c.graph(args[1:])
+	case "run":
+		return c.run(args[1:])
+	case "kinds":
+		return c.kinds(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return ExitOK
+	default:
+		fmt.Fprintf(stderr, "pipelines: unknown command %q\n\n%s", args[0], usage)
+		return ExitUsage
+	}
+}
+
+type command struct {
+	registry *spec.Registry
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+func (c *command) flags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: pipelines %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses flags and returns the positional arguments, or the exit code to return.
+func (c *command) parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, ExitOK, false
+		}
+		return nil, ExitUsage, false
+	}
+	if fs.NArg() < minArgs || maxArgs >= 0 && fs.NArg() > maxArgs {
+		fs.Usage()
+		return nil, ExitUsage, false
+	}
+	return fs.Args(), 0, true
+}
+
+func (c *command) validate(args []string) int {
+	fs := c.flags("validate", "<file>...")
+	files, code, ok := c.parse(fs, args, 1, -1)
+	if !ok {
+		return code
+	}
+
+	code = ExitOK
+	for _, file := range files {
+		s, err := spec.ParseFile(file)
+		if err == nil {
+			err = c.registry.Check(s)
+		}
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			code = ExitFailure
+			continue
+		}
+		fmt.Fprintf(c.stdout, "%s: ok, %d nodes, %d edges\n", file, len(s.Nodes), len(s.Edges))
+	}
+	return code
+}
+
+func (c *command) graph(args []string) int {
+	fs := c.flags("graph", "<file>")
+	format := fs.String("format", "dot", "output format: dot or mermaid")
+	files, code, ok := c.parse(fs, args, 1, 1)
+	if !ok {
+		return code
+	}
+
+	s, err := spec.ParseFile(files[0])
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return ExitFailure
+	}
+
+	switch *format {
+	case "dot":
+		writeDOT(c.stdout, s, c.registry)
+	case "mermaid":
+		writeMermaid(c.stdout, s, c.registry)
+	default:
+		fmt.Fprintf(c.stderr, "pipelines: unknown graph format %q\n", *format)
+		return ExitUsage
+	}
+	return ExitOK
+}
+
+func (c *command) run(args []string) int {
+	fs := c.flags("run", "<file>")
+	interval := fs.Duration("progress", time.Second, "progress refresh interval, 0 to disable")
+	drainTimeout := fs.Duration("drain-timeout", 30*time.Second, "how long to wait for in-flight items after an interrupt")
+	files, code, ok := c.parse(fs, args, 1, 1)
+	if !ok {
+		return code
+	}
+
+	g, err := spec.Load(c.registry, files[0])
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return ExitFailure
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	interrupted := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			return
+		}
+		close(interrupted)
+		fmt.Fprintf(c.stderr, "pipelines: draining, waiting up to %s for in-flight items (interrupt again to stop now)\n", *drainTimeout)
+		g.Drain()
+
+		select {
+		case <-signals:
+		case <-time.After(*drainTimeout):
+			fmt.Fprintln(c.stderr, "pipelines: drain timed out")
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	p := newProgress(c.stderr, g, *interval)
+	p.start()
+	err = g.Run(ctx)
+	p.stop()
+
+	select {
+	case <-interrupted:
+		if err != nil {
+			fmt.Fprintln(c.stderr, "pipelines: stopped:", err)
+		}
+		return ExitInterrupted
+	default:
+	}
+	if err != nil {
+		fmt.Fprintln(c.stderr, "pipelines:", err)
+		return ExitFailure
+	}
+	return ExitOK
+}
+
+func (c *command) kinds(args []string) int {
+	fs := c.flags("kinds", "")
+	if _, code, ok := c.parse(fs, args, 0, 0); !ok {
+		return code
+	}
+
+	for _, k := range c.registry.Kinds() {
+		fmt.Fprintf(c.stdout, "%s (%s)\n", k.Name, portTypes(k))
+		if k.Schema.Doc != "" {
+			fmt.Fprintf(c.stdout, "    %s\n", k.Schema.Doc)
+		}
+		for _, p := range k.Schema.Params {
+			var notes []string
+			if p.Required {
+				notes = append(notes, "required")
+			}
+			if p.Doc != "" {
+				notes = append(notes, p.Doc)
+			}
+			if p.Default != nil {
+				notes = append(notes, fmt.Sprintf("default %v", p.Default))
+			}
+			line := fmt.Sprintf("    %s %s", p.Name, p.Type)
+			if len(notes) > 0 {
+				line += ": " + strings.Join(notes, ", ")
+			}
+			fmt.Fprintln(c.stdout, line)
+		}
+	}
+	return ExitOK
+}
+
+// portTypes describes the item types of a kind, e.g. "string -> int" or "-> string".
+func portTypes(k spec.Kind) string {
+	var in, out string
+	if k.In != nil {
+		in = spec.TypeName(k.In) + " "
+	}
+	if k.Out != nil {
+		out = " " + spec.TypeName(k.Out)
+	}
+	return in + "->" + out
+}
@@ -0,0 +1,164 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/cli"
+	"github.com/Sergey-Polishchenko/pipelines/spec"
+	"github.com/Sergey-Polishchenko/pipelines/spec/std"
+)
+
+func write(t *testing.T, dir, name, data string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(std.Registry(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunPipeline(t *testing.T) {
+	dir := t.TempDir()
+	input := write(t, dir, "in.txt", "b\na\nb\nc\n")
+	output := filepath.Join(dir, "out.txt")
+
+	def := write(t, dir, "pipeline.yaml", `
+nodes:
+  - {id: in, kind: read-lines, params: {path: `+input+`}}
+  - {id: uniq, kind: dedup}
+  - {id: out, kind: write-text, params: {path: `+output+`}}
+edges: [in -> uniq, uniq -> out]
+`)
+
+	if code, _, stderr := run("validate", def); code != cli.ExitOK {
+		t.Fatalf("validate exited with %d: %s", code, stderr)
+	}
+
+	code, _, stderr := run("run", "-progress", "0", def)
+	if code != cli.ExitOK {
+		t.Fatalf("run exited with %d: %s", code, stderr)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if got := string(data); got != "b\na\nc\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestRunProgress(t *testing.T) {
+	dir := t.TempDir()
+	input := write(t, dir, "in.txt", "b\na\nb\nc\n")
+	def := write(t, dir, "pipeline.yaml", `
+nodes:
+  - {id: in, kind: read-lines, params: {path: `+input+`}}
+  - {id: uniq, kind: dedup}
+  - {id: out, kind: write-text, params: {path: `+filepath.Join(dir, "out.txt")+`}}
+edges: [in -> uniq, uniq -> out]
+`)
+
+	// Вывод не терминал: печатается только итоговая таблица, и у каждой ноды есть счётчики
+	code, _, stderr := run("run", "-progress", "1h", def)
+	if code != cli.ExitOK {
+		t.Fatalf("run exited with %d: %s", code, stderr)
+	}
+	want := map[string][]string{
+		"in":   {"read-lines", "0", "4", "0"},
+		"uniq": {"dedup", "4", "3", "1"},
+		"out":  {"write-text", "3", "0", "0"},
+	}
+	for _, line := range strings.Split(stderr, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		if w, ok := want[fields[0]]; ok {
+			if got := fields[1:5]; !slices.Equal(got, w) {
+				t.Errorf("node %s: got %v, want %v", fields[0], got, w)
+			}
+			delete(want, fields[0])
+		}
+	}
+	if len(want) > 0 {
+		t.Fatalf("no progress rows for %v in:\n%s", want, stderr)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	def := write(t, t.TempDir(), "bad.yaml", `nodes:
+  - {id: in, kind: read-lines}
+  - {id: out, kind: write-csv}
+edges: [in -> out]
+`)
+
+	code, _, stderr := run("validate", def)
+	if code != cli.ExitFailure {
+		t.Fatalf("expected exit code %d, got %d", cli.ExitFailure, code)
+	}
+	// Ошибка указывает файл, строку ребра и несовместимые типы
+	if !strings.Contains(stderr, "bad.yaml:4:") || !strings.Contains(stderr, "in emits string, but out accepts []string") {
+		t.Fatalf("unexpected error output: %s", stderr)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	def := write(t, t.TempDir(), "p.yaml", `nodes:
+  - {id: in, kind: read-lines}
+  - {id: out, kind: stdout}
+edges: [in -> out]
+`)
+
+	code, stdout, _ := run("graph", def)
+	if code != cli.ExitOK || !strings.Contains(stdout, `"in" -> "out" [label="string"];`) {
+		t.Fatalf("unexpected DOT (exit %d):\n%s", code, stdout)
+	}
+
+	code, stdout, _ = run("graph", "-format", "mermaid", def)
+	if code != cli.ExitOK || !strings.Contains(stdout, `n0 -->|"string"| n1`) {
+		t.Fatalf("unexpected Mermaid (exit %d):\n%s", code, stdout)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "ticks.txt")
+	s, err := spec.Parse("ticks.yaml", []byte(`nodes:
+  - {id: tick, kind: ticker, params: {interval: 1ms}}
+  - {id: out, kind: write-text, params: {path: `+output+`}}
+edges: [tick -> out]
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	g, err := std.Registry().Build(s)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	time.AfterFunc(20*time.Millisecond, g.Drain)
+
+	// Бесконечный генератор останавливается, а уже выданные элементы дописываются
+	if err := g.Run(ctx); err != nil {
+		t.Fatalf("Run failed after Drain: %v", err)
+	}
+	if data, err := os.ReadFile(output); err != nil || len(data) == 0 {
+		t.Fatalf("expected written ticks, got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Sergey-Polishchenko/pipelines/spec"
+)
+
+// edgeLabel returns the item type flowing along e, if the kind of its source is known.
+func edgeLabel(s *spec.Spec, r *spec.Registry, e spec.EdgeSpec) string {
+	for _, n := range s.Nodes {
+		if n.ID != e.From {
+			continue
+		}
+		if k, ok := r.Kind(n.Kind); ok && k.Out != nil {
+			return spec.TypeName(k.Out)
+		}
+	}
+	return ""
+}
+
+func graphName(s *spec.Spec) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return "pipeline"
+}
+
+func writeDOT(w io.Writer, s *spec.Spec, r *spec.Registry) {
+	fmt.Fprintf(w, "digraph %s {\n", strconv.Quote(graphName(s)))
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	for _, n := range s.Nodes {
+		fmt.Fprintf(w, "  %s [label=%s];\n", strconv.Quote(n.ID), strconv.Quote(n.ID+"\n"+n.Kind))
+	}
+	for _, e := range s.Edges {
+		attrs := ""
+		if label := edgeLabel(s, r, e); label != "" {
+			attrs = fmt.Sprintf(" [label=%s]", strconv.Quote(label))
+		}
+		fmt.Fprintf(w, "  %s -> %s%s;\n", strconv.Quote(e.From), strconv.Quote(e.To), attrs)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeMermaid writes a flowchart. Node ids are replaced with n0, n1, ... since
+// Mermaid restricts the characters of ids; the original ids are shown in labels.
+func writeMermaid(w io.Writer, s *spec.Spec, r *spec.Registry) {
+	ids := make(map[string]string, len(s.Nodes))
+	fmt.Fprintln(w, "flowchart LR")
+	for i, n := range s.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(w, "  %s[\"%s<br/>%s\"]\n", ids[n.ID], mermaidText(n.ID), mermaidText(n.Kind))
+	}
+	for _, e := range s.Edges {
+		from, to := ids[e.From], ids[e.To]
+		if from == "" || to == "" {
+			continue
+		}
+		if label := edgeLabel(s, r, e); label != "" {
+			fmt.Fprintf(w, "  %s -->|\"%s\"| %s\n", from, mermaidText(label), to)
+		} else {
+			fmt.Fprintf(w, "  %s --> %s\n", from, to)
+		}
+	}
+}
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+func mermaidText(s string) string {
+	return mermaidEscaper.Replace(s)
+}
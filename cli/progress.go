@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/spec"
+)
+
+// progress prints the Stats of the graph nodes every interval. On a terminal the table is
+// redrawn in place; otherwise only the final table is printed.
+type progress struct {
+	w        io.Writer
+	g        *spec.Graph
+	interval time.Duration
+	live     bool
+
+	started time.Time
+	lines   int
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newProgress(w io.Writer, g *spec.Graph, interval time.Duration) *progress {
+	return &progress{
+		w:        w,
+		g:        g,
+		interval: interval,
+		live:     interval > 0 && isTerminal(w),
+		done:     make(chan struct{}),
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *progress) start() {
+	p.started = time.Now()
+	if !p.live {
+		return
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		t := time.NewTicker(p.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.draw()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop ends the refresh and prints the final table.
+func (p *progress) stop() {
+	close(p.done)
+	p.wg.Wait()
+	if p.interval > 0 {
+		p.draw()
+	}
+}
+
+func (p *progress) draw() {
+	var b strings.Builder
+	elapsed := time.Since(p.started)
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "NODE\tKIND\tIN\tOUT\tDROPPED\tWORKERS\tOUT/S\n")
+	for _, n := range p.g.Nodes {
+		sp, ok := n.Node.(nodes.StatsProvider)
+		if !ok {
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\t-\n", n.ID, n.Kind)
+			continue
+		}
+		st := sp.Stats()
+		rate := float64(st.Emitted) / max(elapsed.Seconds(), 1e-3)
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%.1f\n", n.ID, n.Kind, st.Processed, st.Emitted, st.Dropped, st.Workers, rate)
+	}
+	fmt.Fprintf(tw, "elapsed %s\n", elapsed.Round(100*time.Millisecond))
+	tw.Flush()
+
+	table := b.String()
+	if p.live && p.lines > 0 {
+		// move the cursor back to the top of the previous table and clear it
+		fmt.Fprintf(p.w, "\x1b[%dA\x1b[J", p.lines)
+	}
+	fmt.Fprint(p.w, table)
+	p.lines = strings.Count(table, "\n")
+}
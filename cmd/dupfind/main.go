@@ -0,0 +1,417 @@
+// Command dupfind finds duplicate files, i.e. regular files with the same content.
+//
+//	dupfind [flags] [path ...]
+//
+// Directories are walked recursively, and no paths means ".". Only files that could be
+// duplicates are read: files are grouped by size first, files of the same size by a hash of
+// their first 4 KiB, and only files still sharing a group are hashed in full and grouped by
+// digest. Hard links to one file share its data and are counted once.
+//
+// Duplicate sets are printed once all files are processed, largest reclaimable size first,
+// as text or as a JSON document, with the bytes that removing all but one copy would free.
+// Files that cannot be read are reported on stderr and do not stop the others.
+//
+// Exit codes: 0 if every file was read, 1 if some files could not be read, 2 for invalid
+// usage, 3 if the run failed or timed out, 130 if interrupted.
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/hashing"
+	"github.com/Sergey-Polishchenko/pipelines/internal/fileid"
+	"github.com/Sergey-Polishchenko/pipelines/internal/flagutil"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+const (
+	exitOK          = 0
+	exitFileErrors  = 1
+	exitUsage       = 2
+	exitFatal       = 3
+	exitInterrupted = 130
+)
+
+// partialSize is how much of each file is hashed to split groups of same-size files.
+const partialSize = 4 << 10
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fset := flag.NewFlagSet("dupfind", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	var (
+		algName = fset.String("a", "sha256", "hash algorithm: "+strings.Join(hashing.Algorithms(), ", "))
+		format  = fset.String("format", "text", "output format: text or json")
+		workers = fset.Int("workers", 10, "number of files hashed in parallel")
+		timeout = fset.Duration("timeout", 0, "stop after this duration, 0 for no limit")
+		minSize = flagutil.Size(1)
+		include flagutil.StringList
+		exclude flagutil.StringList
+		parent  = ctx
+	)
+	fset.Var(&minSize, "min-size", "ignore files smaller than this, e.g. 1M")
+	fset.Var(&include, "include", "consider only files matching the glob pattern (repeatable)")
+	fset.Var(&exclude, "exclude", "skip files and directories matching the glob pattern (repeatable)")
+	fset.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: dupfind [flags] [path ...]")
+		fset.PrintDefaults()
+	}
+
+	if err := fset.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	alg, ok := hashing.Lookup(*algName)
+	if !ok {
+		fmt.Fprintf(stderr, "dupfind: unknown algorithm %q, want one of %s\n", *algName, strings.Join(hashing.Algorithms(), ", "))
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "dupfind: unknown format %q, want text or json\n", *format)
+		return exitUsage
+	}
+	if *workers <= 0 {
+		fmt.Fprintln(stderr, "dupfind: -workers must be positive")
+		return exitUsage
+	}
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	roots := fset.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	f := &finder{alg: alg, minSize: int64(minSize), workers: *workers, errs: &problems{w: stderr}}
+	rep := &report{alg: alg}
+	err := f.run(ctx, roots, sources.WalkOptions{Include: include, Exclude: exclude}, rep)
+	if err == nil {
+		err = rep.print(stdout, *format == "json")
+	}
+
+	switch {
+	case parent.Err() != nil:
+		fmt.Fprintln(stderr, "dupfind: interrupted")
+		return exitInterrupted
+	case errors.Is(err, context.DeadlineExceeded):
+		fmt.Fprintf(stderr, "dupfind: timed out after %s\n", *timeout)
+		return exitFatal
+	case err != nil:
+		fmt.Fprintln(stderr, "dupfind:", err)
+		return exitFatal
+	case f.errs.count() > 0:
+		return exitFileErrors
+	}
+	return exitOK
+}
+
+// candidate is a file that may have duplicates. Partial and Sum are filled in
+// by the stages that hash it.
+type candidate struct {
+	Path    string
+	Size    int64
+	Partial string
+	Sum     string
+	Err     error
+
+	info os.FileInfo
+}
+
+// groupKey is what files must share to stay candidates; the fields not computed
+// yet are empty.
+type groupKey struct {
+	Size    int64
+	Partial string
+	Sum     string
+}
+
+func (c candidate) key() groupKey {
+	return groupKey{Size: c.Size, Partial: c.Partial, Sum: c.Sum}
+}
+
+// problems reports per-file errors to w; it is used from all stages at once.
+type problems struct {
+	mu sync.Mutex
+	w  io.Writer
+	n  int
+}
+
+func (p *problems) report(ctx context.Context, path string, err error) {
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		// the run is stopping; the reason is reported once by run
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.n++
+
+	var perr *fs.PathError
+	if errors.As(err, &perr) {
+		fmt.Fprintf(p.w, "dupfind: %v\n", err)
+	} else {
+		fmt.Fprintf(p.w, "dupfind: %s: %v\n", path, err)
+	}
+}
+
+func (p *problems) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.n
+}
+
+type finder struct {
+	alg     hashing.Algorithm
+	minSize int64
+	workers int
+	errs    *problems
+}
+
+// run runs the pipeline:
+//
+//	walk -> stat -> group by size -> partial hash -> group -> full hash -> group -> report
+//
+// where each grouping is followed by a flat map that passes on the files of groups
+// with at least two members.
+func (f *finder) run(ctx context.Context, roots []string, walk sources.WalkOptions, rep *report) error {
+	pool := nodes.Config{Workers: f.workers, Buffer: f.workers}
+	dups := nodes.GroupConfig{MinItems: 2}
+
+	files := nodes.NewGenerator(sources.ParallelWalk(roots, sources.ParallelWalkOptions{WalkOptions: walk}))
+	stat := nodes.NewContextFlatMap(f.stat)
+	bySize := nodes.NewGroupBy(candidate.key, dups)
+	sameSize := nodes.NewContextFlatMap(f.members)
+	partial := nodes.NewContextWorkerPool(f.hashPartial, pool)
+	byPartial := nodes.NewGroupBy(candidate.key, dups)
+	samePartial := nodes.NewContextFlatMap(f.members)
+	full := nodes.NewContextWorkerPool(f.hashFull, pool)
+	bySum := nodes.NewGroupBy(candidate.key, dups)
+	output := nodes.NewResultAggregator(rep.add)
+
+	if err := pipelines.Connect(files, stat); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(stat, bySize); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(bySize, sameSize); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(sameSize, partial); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(partial, byPartial); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(byPartial, samePartial); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(samePartial, full); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(full, bySum); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(bySum, output); err != nil {
+		return err
+	}
+
+	p := pipelines.New()
+	p.Add(files, stat, bySize, sameSize, partial, byPartial, samePartial, full, bySum, output)
+	return p.Run(ctx)
+}
+
+// stat turns a walked file into a candidate, dropping unreadable and too small files.
+func (f *finder) stat(ctx context.Context, e sources.WalkEntry) ([]candidate, error) {
+	if e.Err != nil {
+		f.errs.report(ctx, e.Path, e.Err)
+		return nil, nil
+	}
+
+	info, err := os.Stat(e.Path)
+	if err != nil {
+		f.errs.report(ctx, e.Path, err)
+		return nil, nil
+	}
+	if !info.Mode().IsRegular() || info.Size() < f.minSize {
+		return nil, nil
+	}
+	return []candidate{{Path: e.Path, Size: info.Size(), info: info}}, nil
+}
+
+// members passes on the files of a group that were read successfully, one per
+// hard-linked file, if at least two are left.
+func (f *finder) members(ctx context.Context, g nodes.Group[groupKey, candidate]) ([]candidate, error) {
+	files := distinct(g.Items)
+	if len(files) < 2 {
+		return nil, nil
+	}
+	return files, nil
+}
+
+// distinct drops failed candidates and later links to a file already in items. Files are
+// told apart by device and inode numbers, or with os.SameFile where the platform has none.
+func distinct(items []candidate) []candidate {
+	var (
+		files []candidate
+		seen  = make(map[[2]uint64]bool, len(items))
+	)
+	for _, c := range items {
+		if c.Err != nil {
+			continue
+		}
+		if dev, ino := fileid.Of(c.info); ino != 0 {
+			if seen[[2]uint64{dev, ino}] {
+				continue
+			}
+			seen[[2]uint64{dev, ino}] = true
+		} else if slices.ContainsFunc(files, func(o candidate) bool { return os.SameFile(o.info, c.info) }) {
+			continue
+		}
+		files = append(files, c)
+	}
+	return files
+}
+
+// hashPartial hashes the first partialSize bytes of the file. Files no larger than that
+// are then hashed completely, so their Sum is set as well.
+func (f *finder) hashPartial(ctx context.Context, c candidate) (candidate, error) {
+	file, err := os.Open(c.Path)
+	if err != nil {
+		return f.failed(ctx, c, err), nil
+	}
+	defer file.Close()
+
+	h := f.alg.New()
+	if _, err := io.CopyN(h, file, min(c.Size, partialSize)); err != nil {
+		return f.failed(ctx, c, fmt.Errorf("%s: %w", c.Path, err)), nil
+	}
+	c.Partial = hex.EncodeToString(h.Sum(nil))
+	if c.Size <= partialSize {
+		c.Sum = c.Partial
+	}
+	return c, nil
+}
+
+// hashFull hashes the whole file unless hashPartial already did.
+func (f *finder) hashFull(ctx context.Context, c candidate) (candidate, error) {
+	if c.Sum != "" {
+		return c, nil
+	}
+
+	fh := hashing.HashFile(ctx, c.Path, f.alg)
+	switch {
+	case fh.Err != nil:
+		return f.failed(ctx, c, fh.Err), nil
+	case fh.Size != c.Size:
+		return f.failed(ctx, c, errors.New("file changed while reading")), nil
+	}
+	c.Sum = hex.EncodeToString(fh.Sum)
+	return c, nil
+}
+
+func (f *finder) failed(ctx context.Context, c candidate, err error) candidate {
+	f.errs.report(ctx, c.Path, err)
+	c.Err = err
+	return c
+}
+
+// set is a group of identical files as printed in JSON.
+type set struct {
+	Size        int64    `json:"size"`
+	Hash        string   `json:"hash"`
+	Reclaimable int64    `json:"reclaimable"`
+	Files       []string `json:"files"`
+}
+
+// report collects the duplicate sets found.
+type report struct {
+	alg  hashing.Algorithm
+	sets []set
+}
+
+func (r *report) add(g nodes.Group[groupKey, candidate]) error {
+	files := distinct(g.Items)
+	if len(files) < 2 {
+		return nil
+	}
+
+	s := set{Size: g.Key.Size, Hash: g.Key.Sum, Reclaimable: g.Key.Size * int64(len(files)-1)}
+	for _, c := range files {
+		s.Files = append(s.Files, c.Path)
+	}
+	slices.Sort(s.Files)
+	r.sets = append(r.sets, s)
+	return nil
+}
+
+// print writes the sets, largest reclaimable size first, and the totals to w.
+func (r *report) print(w io.Writer, asJSON bool) error {
+	slices.SortFunc(r.sets, func(a, b set) int {
+		return cmp.Or(cmp.Compare(b.Reclaimable, a.Reclaimable), strings.Compare(a.Files[0], b.Files[0]))
+	})
+
+	var files, reclaimable int64
+	for _, s := range r.sets {
+		files += int64(len(s.Files))
+		reclaimable += s.Reclaimable
+	}
+
+	if asJSON {
+		doc := struct {
+			Algorithm   string `json:"algorithm"`
+			Sets        []set  `json:"sets"`
+			Files       int64  `json:"files"`
+			Reclaimable int64  `json:"reclaimable"`
+		}{r.alg.Name, r.sets, files, reclaimable}
+		if doc.Sets == nil {
+			doc.Sets = []set{}
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	}
+
+	out := bufio.NewWriter(w)
+	for _, s := range r.sets {
+		fmt.Fprintf(out, "%d files of %d bytes, %d bytes reclaimable, %s %s\n",
+			len(s.Files), s.Size, s.Reclaimable, r.alg.Name, s.Hash)
+		for _, path := range s.Files {
+			fmt.Fprintf(out, "  %s\n", path)
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintf(out, "%d duplicate sets, %d files, %d bytes reclaimable\n", len(r.sets), files, reclaimable)
+	return out.Flush()
+}
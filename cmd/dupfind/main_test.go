@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func dupfind(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func tree(t *testing.T) string {
+	t.Helper()
+
+	big := strings.Repeat("x", 3*partialSize)
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.txt":       "same",
+		"sub/b.txt":   "same",
+		"sub/c.txt":   "diff",
+		"big1":        big,
+		"deep/big2":   big,
+		"deep/big3":   big[:len(big)-1] + "y", // совпадает первый блок, но не весь файл
+		"empty1":      "",
+		"empty2":      "",
+		"unique.data": "only one of this size",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestFindDuplicates(t *testing.T) {
+	dir := tree(t)
+	// Жёсткая ссылка не занимает места и не считается дубликатом
+	if err := os.Link(filepath.Join(dir, "a.txt"), filepath.Join(dir, "link.txt")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	code, stdout, stderr := dupfind(t, "-format", "json", "-workers", "3", dir)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+
+	var doc struct {
+		Sets               []set
+		Files, Reclaimable int64
+	}
+	if err := json.Unmarshal([]byte(stdout), &doc); err != nil {
+		t.Fatalf("invalid JSON %q: %v", stdout, err)
+	}
+
+	if len(doc.Sets) != 2 {
+		t.Fatalf("expected 2 sets, got %+v", doc.Sets)
+	}
+	big, small := doc.Sets[0], doc.Sets[1]
+	if want := []string{filepath.Join(dir, "big1"), filepath.Join(dir, "deep/big2")}; !slices.Equal(big.Files, want) {
+		t.Errorf("got set %v, want %v", big.Files, want)
+	}
+	if big.Reclaimable != 3*partialSize {
+		t.Errorf("got %d reclaimable bytes, want %d", big.Reclaimable, 3*partialSize)
+	}
+	if len(small.Files) != 2 || !strings.HasSuffix(small.Files[1], "b.txt") {
+		t.Errorf("unexpected set %v", small.Files)
+	}
+	if doc.Files != 4 || doc.Reclaimable != 3*partialSize+4 {
+		t.Errorf("got totals %d files, %d bytes", doc.Files, doc.Reclaimable)
+	}
+}
+
+func TestTextOutput(t *testing.T) {
+	dir := tree(t)
+
+	// -min-size 0 учитывает и пустые файлы
+	code, stdout, stderr := dupfind(t, "-a", "md5", "-min-size", "0", "-exclude", "deep", dir)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+
+	want := "2 files of 4 bytes, 4 bytes reclaimable, md5 51037a4a37730f52c8732586d3aaa316\n" +
+		"  " + filepath.Join(dir, "a.txt") + "\n" +
+		"  " + filepath.Join(dir, "sub/b.txt") + "\n\n"
+	if !strings.HasPrefix(stdout, want) {
+		t.Fatalf("got:\n%s\nwant prefix:\n%s", stdout, want)
+	}
+	if !strings.HasSuffix(stdout, "\n2 duplicate sets, 4 files, 4 bytes reclaimable\n") {
+		t.Fatalf("unexpected totals in %q", stdout)
+	}
+	if !strings.Contains(stdout, "\n  "+filepath.Join(dir, "empty2")+"\n") {
+		t.Fatalf("empty files not reported in %q", stdout)
+	}
+}
+
+func TestUnreadableFile(t *testing.T) {
+	code, _, stderr := dupfind(t, filepath.Join(t.TempDir(), "missing"))
+	if code != exitFileErrors || !strings.Contains(stderr, "missing") {
+		t.Fatalf("got exit code %d, stderr %q", code, stderr)
+	}
+}
@@ -0,0 +1,516 @@
+// Command hashsum computes checksums of files and directory trees in parallel.
+//
+//	hashsum [flags] [path ...]
+//	hashsum -c [flags] [manifest ...]
+//	hashsum -watch [flags] [directory ...]
+//
+// Directories are walked recursively; "-" hashes standard input, and no paths means ".".
+// Results are printed in completion order. Files that cannot be read are reported on
+// stderr and do not stop the others.
+//
+// Files are streamed through a read buffer of -buffer bytes; -memory caps the buffers of all
+// files read at once. With -chunk, files are split into chunks hashed in parallel and the
+// results are tree hashes, see hashing.NewTree.
+//
+// With -cache, digests are kept in a file together with the size, modification time and
+// inode of each file, and unchanged files are not read again on the next run; -rehash
+// ignores the stored digests. The hit rate is reported on stderr.
+//
+// With -watch, hashsum hashes the files under the given directories and then keeps running,
+// printing new digests as files are created or modified and a DELETED line when they are
+// removed (see sources.Watch). Changes come from inotify on Linux, or from scanning every
+// -poll interval. It stops on interrupt and then exits as if the run had completed.
+//
+// With -archives, the files inside .zip, .tar, .tar.gz and .tgz archives are hashed as well,
+// named like "bundle.zip!/dir/file", and so are archives nested in them down to -archive-depth
+// levels (see hashing.ExpandArchives). Nothing is extracted to disk.
+//
+// With -c, hashsum reads checksum manifests in GNU, BSD or JSON Lines format ("-" or no
+// manifests means standard input), re-hashes the listed files and prints OK, FAILED or
+// MISSING for each of them, followed by a summary on stderr.
+//
+// Exit codes: 0 if every file was hashed (or verified), 1 if some files failed, did not
+// match or were missing, 2 for invalid usage, 3 if the run failed or timed out, 130 if
+// interrupted (except in watch mode).
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/hashing"
+	"github.com/Sergey-Polishchenko/pipelines/internal/flagutil"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+var (
+	_ nodes.SinkWriter[hashing.FileHash]     = &reporter{}
+	_ nodes.SinkWriter[hashing.Verification] = &checker{}
+	_ nodes.SinkWriter[update]               = updates{}
+)
+
+const (
+	exitOK          = 0
+	exitFileErrors  = 1
+	exitUsage       = 2
+	exitFatal       = 3
+	exitInterrupted = 130
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fset := flag.NewFlagSet("hashsum", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	var (
+		algName = fset.String("a", "md5", "hash algorithm: "+strings.Join(hashing.Algorithms(), ", "))
+		format  = fset.String("format", "gnu", "output format: gnu (md5sum style), bsd (tag style) or jsonl")
+		workers = fset.Int("workers", 10, "number of files hashed in parallel")
+		timeout = fset.Duration("timeout", 0, "stop after this duration, 0 for no limit")
+		check   = fset.Bool("c", false, "verify the checksums listed in the given manifests")
+		quiet   = fset.Bool("quiet", false, "with -c, do not print OK for verified files")
+		buffer  = flagutil.Size(hashing.DefaultBufferSize)
+		memory  flagutil.Size
+		chunk   flagutil.Size
+		cache   = fset.String("cache", "", "file keeping digests of unchanged files between runs")
+		rehash  = fset.Bool("rehash", false, "with -cache, hash every file again and refresh the cache")
+		maxAge  = fset.Duration("cache-max-age", 0, "with -cache, rehash files whose digest is older than this")
+		noInode = fset.Bool("cache-ignore-inode", false, "with -cache, do not compare inode numbers")
+		prune   = fset.Bool("cache-prune", false, "with -cache, drop entries of files not seen in this run")
+		watch   = fset.Bool("watch", false, "keep running and hash files again when they change")
+		poll    = fset.Duration("poll", 0, "with -watch, scan for changes at this interval instead of using inotify")
+		expand  = fset.Bool("archives", false, "also hash the files inside .zip, .tar, .tar.gz and .tgz archives")
+		depth   = fset.Int("archive-depth", hashing.DefaultArchiveDepth, "with -archives, how many levels of nested archives to expand")
+		include flagutil.StringList
+		exclude flagutil.StringList
+		parent  = ctx
+	)
+	fset.Var(&buffer, "buffer", "read buffer size per file, e.g. 256K")
+	fset.Var(&memory, "memory", "limit of all read buffers in use at once, e.g. 64M; 0 for no limit")
+	fset.Var(&chunk, "chunk", "split files into chunks of this size hashed in parallel, producing tree hashes")
+	fset.Var(&include, "include", "hash only files matching the glob pattern (repeatable)")
+	fset.Var(&exclude, "exclude", "skip files and directories matching the glob pattern (repeatable)")
+	fset.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: hashsum [flags] [path ...]")
+		fmt.Fprintln(stderr, "       hashsum -c [flags] [manifest ...]")
+		fset.PrintDefaults()
+	}
+
+	if err := fset.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	alg, ok := hashing.Lookup(*algName)
+	if !ok {
+		fmt.Fprintf(stderr, "hashsum: unknown algorithm %q, want one of %s\n", *algName, strings.Join(hashing.Algorithms(), ", "))
+		return exitUsage
+	}
+	outFormat, err := hashing.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(stderr, "hashsum:", err)
+		return exitUsage
+	}
+	if *workers <= 0 {
+		fmt.Fprintln(stderr, "hashsum: -workers must be positive")
+		return exitUsage
+	}
+
+	if buffer <= 0 || buffer > math.MaxInt32 {
+		fmt.Fprintln(stderr, "hashsum: -buffer must be between 1 byte and 2G")
+		return exitUsage
+	}
+	if chunk > 0 && *check {
+		fmt.Fprintln(stderr, "hashsum: -chunk cannot be used with -c")
+		return exitUsage
+	}
+	if *cache == "" && (*rehash || *maxAge > 0 || *noInode || *prune) {
+		fmt.Fprintln(stderr, "hashsum: -rehash and -cache-* flags require -cache")
+		return exitUsage
+	}
+	if *watch && (*check || chunk > 0) {
+		fmt.Fprintln(stderr, "hashsum: -watch cannot be used with -c or -chunk")
+		return exitUsage
+	}
+	if !*watch && *poll > 0 {
+		fmt.Fprintln(stderr, "hashsum: -poll requires -watch")
+		return exitUsage
+	}
+	if *cache != "" && (*check || chunk > 0) {
+		fmt.Fprintln(stderr, "hashsum: -cache cannot be used with -c or -chunk")
+		return exitUsage
+	}
+	if *expand && (*check || chunk > 0 || *watch) {
+		fmt.Fprintln(stderr, "hashsum: -archives cannot be used with -c, -chunk or -watch")
+		return exitUsage
+	}
+	if *depth <= 0 {
+		fmt.Fprintln(stderr, "hashsum: -archive-depth must be positive")
+		return exitUsage
+	}
+	opts := hashing.Options{BufferSize: int(buffer)}
+	if memory > 0 {
+		opts.Budget = hashing.NewBudget(int64(memory))
+	}
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	var failed int
+	if *check {
+		manifests := fset.Args()
+		if len(manifests) == 0 {
+			manifests = []string{hashing.StdinPath}
+		}
+
+		c := &checker{out: bufio.NewWriter(stdout), errs: stderr, quiet: *quiet}
+		err = verify(ctx, manifests, alg, opts, *workers, c)
+		if err == nil {
+			c.summary()
+		}
+		failed = c.failed()
+	} else {
+		roots := fset.Args()
+		if len(roots) == 0 {
+			roots = []string{"."}
+		}
+
+		var fileCache *hashing.Cache
+		if *cache != "" {
+			fileCache, err = hashing.OpenCache(*cache, hashing.CacheOptions{
+				MaxAge:      *maxAge,
+				IgnoreInode: *noInode,
+				Rehash:      *rehash,
+				Prune:       *prune,
+			})
+			if err != nil {
+				fmt.Fprintln(stderr, "hashsum: cache:", err)
+				return exitFatal
+			}
+		}
+
+		hashFile := func(ctx context.Context, path string) hashing.FileHash {
+			if fileCache != nil {
+				return fileCache.HashFile(ctx, path, alg, opts)
+			}
+			return hashing.HashFile(ctx, path, alg, opts)
+		}
+
+		rep := &reporter{out: bufio.NewWriter(stdout), errs: stderr, format: outFormat}
+		walk := sources.WalkOptions{Include: include, Exclude: exclude}
+		switch {
+		case *watch:
+			err = watchTree(ctx, roots, sources.WatchOptions{
+				WalkOptions:  walk,
+				Existing:     true,
+				Poll:         *poll > 0,
+				PollInterval: *poll,
+			}, hashFile, *workers, rep)
+			if parent.Err() != nil {
+				// interrupting is the normal way to stop watching
+				err = nil
+			}
+		case chunk > 0:
+			err = hashChunked(ctx, roots, hashing.NewTree(alg, int64(chunk)), walk, opts, *workers, rep)
+		default:
+			targets := hashing.Targets(roots, walk)
+			if *expand {
+				targets = hashing.ExpandArchives(targets, hashing.ArchiveOptions{MaxDepth: *depth})
+			}
+			err = hash(ctx, targets, hashFile, alg, opts, *workers, rep)
+		}
+		failed = rep.failed
+
+		if fileCache != nil {
+			// digests computed before an interruption are kept for the next run
+			if serr := fileCache.Save(); serr != nil {
+				fmt.Fprintln(stderr, "hashsum: cache:", serr)
+			}
+			st := fileCache.Stats()
+			fmt.Fprintf(stderr, "hashsum: cache: %d hits, %d misses (%.1f%% hit rate)\n",
+				st.Hits, st.Misses, 100*st.HitRate())
+		}
+	}
+
+	switch {
+	case parent.Err() != nil && !*watch:
+		fmt.Fprintln(stderr, "hashsum: interrupted")
+		return exitInterrupted
+	case errors.Is(err, context.DeadlineExceeded):
+		fmt.Fprintf(stderr, "hashsum: timed out after %s\n", *timeout)
+		return exitFatal
+	case err != nil:
+		fmt.Fprintln(stderr, "hashsum:", err)
+		return exitFatal
+	case failed > 0:
+		return exitFileErrors
+	}
+	return exitOK
+}
+
+// hashFunc hashes one file, with or without a cache.
+type hashFunc func(ctx context.Context, path string) hashing.FileHash
+
+// hash runs the pipeline: targets -> worker pool -> reporter.
+// Archive members are hashed from their archives, bypassing the cache.
+func hash(
+	ctx context.Context,
+	gen nodes.Generator[hashing.Target],
+	hashFile hashFunc,
+	alg hashing.Algorithm,
+	opts hashing.Options,
+	workers int,
+	rep *reporter,
+) error {
+	targets := nodes.NewGenerator(gen)
+	hasher := nodes.NewContextWorkerPool(func(ctx context.Context, t hashing.Target) (hashing.FileHash, error) {
+		if t.Err != nil || t.Open != nil {
+			return hashing.HashTarget(ctx, t, alg, opts), nil
+		}
+		return hashFile(ctx, t.Path), nil
+	}, nodes.Config{Workers: workers, Buffer: workers})
+	output := nodes.NewWriterAggregator(rep)
+
+	if err := pipelines.Connect(targets, hasher); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(hasher, output); err != nil {
+		return err
+	}
+
+	p := pipelines.New()
+	p.Add(targets, hasher, output)
+	return p.Run(ctx)
+}
+
+// hashChunked runs the pipeline: chunks of targets -> worker pool -> tree combiner -> reporter.
+func hashChunked(
+	ctx context.Context,
+	roots []string,
+	tree hashing.Tree,
+	walk sources.WalkOptions,
+	opts hashing.Options,
+	workers int,
+	rep *reporter,
+) error {
+	chunks := nodes.NewGenerator(tree.Chunks(hashing.Targets(roots, walk)))
+	hasher := nodes.NewContextWorkerPool(func(ctx context.Context, c hashing.Chunk) (hashing.ChunkSum, error) {
+		return tree.HashChunk(ctx, c, opts), nil
+	}, nodes.Config{Workers: workers, Buffer: workers})
+	combiner := hashing.NewTreeCombiner(tree)
+	output := nodes.NewWriterAggregator(rep)
+
+	if err := pipelines.Connect(chunks, hasher); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(hasher, combiner); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(combiner, output); err != nil {
+		return err
+	}
+
+	p := pipelines.New()
+	p.Add(chunks, hasher, combiner, output)
+	return p.Run(ctx)
+}
+
+// watchTree runs the long-lived pipeline: file changes -> worker pool -> reporter.
+// It returns once ctx is canceled.
+func watchTree(
+	ctx context.Context,
+	roots []string,
+	wopts sources.WatchOptions,
+	hashFile hashFunc,
+	workers int,
+	rep *reporter,
+) error {
+	changes := nodes.NewGenerator(sources.Watch(roots, wopts))
+	hasher := nodes.NewContextWorkerPool(func(ctx context.Context, ev sources.FileEvent) (update, error) {
+		switch {
+		case ev.Err != nil:
+			return update{FileHash: hashing.FileHash{Path: ev.Path, Err: ev.Err}}, nil
+		case ev.Op == sources.OpRemove:
+			return update{FileHash: hashing.FileHash{Path: ev.Path}, removed: true}, nil
+		}
+		return update{FileHash: hashFile(ctx, ev.Path)}, nil
+	}, nodes.Config{Workers: workers, Buffer: workers})
+	output := nodes.NewWriterAggregator(updates{rep})
+
+	if err := pipelines.Connect(changes, hasher); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(hasher, output); err != nil {
+		return err
+	}
+
+	p := pipelines.New()
+	p.Add(changes, hasher, output)
+	return p.Run(ctx)
+}
+
+// update is a result of watch mode: a new digest, or a removed file.
+type update struct {
+	hashing.FileHash
+	removed bool
+}
+
+// updates adapts a reporter to watch mode, flushing every line as it comes.
+type updates struct {
+	*reporter
+}
+
+func (u updates) Write(ctx context.Context, up update) error {
+	if up.removed {
+		if _, err := fmt.Fprintln(u.out, u.format.RemovedLine(up.Path)); err != nil {
+			return err
+		}
+	} else if err := u.reporter.Write(ctx, up.FileHash); err != nil {
+		return err
+	}
+	return u.out.Flush()
+}
+
+// reporter prints results to out and per-file errors to errs.
+type reporter struct {
+	out    *bufio.Writer
+	errs   io.Writer
+	format hashing.Format
+	failed int
+}
+
+func (r *reporter) Write(ctx context.Context, fh hashing.FileHash) error {
+	if fh.Err != nil {
+		if ctx.Err() != nil && errors.Is(fh.Err, ctx.Err()) {
+			// the run is stopping; the reason is reported once by run
+			return nil
+		}
+		r.failed++
+
+		var perr *fs.PathError
+		if errors.As(fh.Err, &perr) {
+			fmt.Fprintf(r.errs, "hashsum: %v\n", fh.Err)
+		} else {
+			fmt.Fprintf(r.errs, "hashsum: %s: %v\n", fh.Path, fh.Err)
+		}
+		return nil
+	}
+
+	_, err := fmt.Fprintln(r.out, r.format.Line(fh))
+	return err
+}
+
+func (r *reporter) Close() error {
+	return r.out.Flush()
+}
+
+// verify runs the pipeline: manifest entries -> worker pool -> checker.
+func verify(
+	ctx context.Context,
+	manifests []string,
+	alg hashing.Algorithm,
+	opts hashing.Options,
+	workers int,
+	c *checker,
+) error {
+	entries := nodes.NewGenerator(hashing.Manifests(manifests, alg))
+	verifier := nodes.NewContextWorkerPool(func(ctx context.Context, e hashing.Entry) (hashing.Verification, error) {
+		if e.Err != nil {
+			return hashing.Verification{Entry: e, Status: hashing.StatusFailed, Err: e.Err}, nil
+		}
+		return hashing.Verify(ctx, e, opts), nil
+	}, nodes.Config{Workers: workers, Buffer: workers})
+	output := nodes.NewWriterAggregator(c)
+
+	if err := pipelines.Connect(entries, verifier); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(verifier, output); err != nil {
+		return err
+	}
+
+	p := pipelines.New()
+	p.Add(entries, verifier, output)
+	return p.Run(ctx)
+}
+
+// checker prints the status of each verified file to out and counts the outcomes.
+type checker struct {
+	out   *bufio.Writer
+	errs  io.Writer
+	quiet bool
+
+	counts    [hashing.StatusMissing + 1]int
+	malformed int
+	// unreadable counts manifests that could not be read
+	unreadable int
+}
+
+func (c *checker) Write(ctx context.Context, v hashing.Verification) error {
+	if v.Err != nil && ctx.Err() != nil && errors.Is(v.Err, ctx.Err()) {
+		// the run is stopping; the reason is reported once by run
+		return nil
+	}
+
+	if err := v.Entry.Err; err != nil {
+		if errors.Is(err, hashing.ErrBadManifestLine) {
+			c.malformed++
+			fmt.Fprintf(c.errs, "hashsum: %s:%d: %v\n", v.Entry.Manifest, v.Entry.Line, err)
+		} else {
+			c.unreadable++
+			fmt.Fprintf(c.errs, "hashsum: %v\n", err)
+		}
+		return nil
+	}
+
+	c.counts[v.Status]++
+	if v.Err != nil && v.Status != hashing.StatusMissing {
+		fmt.Fprintf(c.errs, "hashsum: %v\n", v.Err)
+	}
+	if c.quiet && v.Status == hashing.StatusOK {
+		return nil
+	}
+	_, err := fmt.Fprintf(c.out, "%s: %s\n", v.Entry.Path, v.Status)
+	return err
+}
+
+func (c *checker) Close() error {
+	return c.out.Flush()
+}
+
+// failed returns the number of files and lines that did not verify.
+func (c *checker) failed() int {
+	return c.counts[hashing.StatusFailed] + c.counts[hashing.StatusMissing] + c.malformed + c.unreadable
+}
+
+// summary prints the totals to errs.
+func (c *checker) summary() {
+	total := c.counts[hashing.StatusOK] + c.counts[hashing.StatusFailed] + c.counts[hashing.StatusMissing]
+	fmt.Fprintf(c.errs, "hashsum: %d files: %d OK, %d FAILED, %d MISSING\n",
+		total, c.counts[hashing.StatusOK], c.counts[hashing.StatusFailed], c.counts[hashing.StatusMissing])
+	if c.malformed > 0 {
+		fmt.Fprintf(c.errs, "hashsum: WARNING: %d improperly formatted lines\n", c.malformed)
+	}
+}
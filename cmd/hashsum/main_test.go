@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func hashsum(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func tree(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.txt":        "abc",
+		"sub/b.txt":    "",
+		"sub/skip.log": "x",
+		"vendor/c.txt": "c",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestHashTree(t *testing.T) {
+	dir := tree(t)
+
+	code, stdout, stderr := hashsum(t, "-a", "sha256", "-workers", "3", "-exclude", "vendor", "-exclude", "*.log", dir)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	slices.Sort(lines)
+	want := []string{
+		"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  " + filepath.Join(dir, "a.txt"),
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  " + filepath.Join(dir, "sub/b.txt"),
+	}
+	if !slices.Equal(lines, want) {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMissingFileIsReported(t *testing.T) {
+	dir := tree(t)
+	missing := filepath.Join(dir, "missing.txt")
+
+	// Ошибка по одному файлу не останавливает остальные, но меняет код выхода
+	code, stdout, stderr := hashsum(t, "-format", "bsd", missing, filepath.Join(dir, "a.txt"))
+	if code != exitFileErrors {
+		t.Fatalf("expected exit code %d, got %d", exitFileErrors, code)
+	}
+	if !strings.Contains(stderr, missing) {
+		t.Fatalf("error for %s not reported: %q", missing, stderr)
+	}
+	if want := "MD5 (" + filepath.Join(dir, "a.txt") + ") = 900150983cd24fb0d6963f7d28e17f72\n"; stdout != want {
+		t.Fatalf("got %q, want %q", stdout, want)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-a", "md4"},
+		{"-format", "xml"},
+		{"-workers", "0"},
+		{"-buffer", "0"},
+		{"-memory", "1X"},
+		{"-c", "-chunk", "1M"},
+		{"-rehash"},
+		{"-cache", "x", "-chunk", "1M"},
+		{"-watch", "-c"},
+		{"-poll", "1s"},
+		{"-archives", "-chunk", "1M"},
+		{"-archive-depth", "0"},
+		{"-no-such-flag"},
+	} {
+		if code, _, _ := hashsum(t, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := tree(t)
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub/b.txt")
+
+	manifest := filepath.Join(t.TempDir(), "sums")
+	content := "900150983cd24fb0d6963f7d28e17f72  " + a + "\n" +
+		"MD5 (" + b + ") = d41d8cd98f00b204e9800998ecf8427e\n" +
+		"SHA1 (" + filepath.Join(dir, "gone.txt") + ") = a9993e364706816aba3e25717850c26c9cd0d89d\n"
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	code, stdout, stderr := hashsum(t, "-c", manifest)
+	if code != exitFileErrors {
+		t.Fatalf("expected exit code %d, got %d: %s", exitFileErrors, code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	slices.Sort(lines)
+	want := []string{a + ": OK", filepath.Join(dir, "gone.txt") + ": MISSING", b + ": OK"}
+	slices.Sort(want)
+	if !slices.Equal(lines, want) {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(stderr, "3 files: 2 OK, 0 FAILED, 1 MISSING") {
+		t.Fatalf("unexpected summary: %q", stderr)
+	}
+
+	// Изменённый файл даёт FAILED
+	if err := os.WriteFile(a, []byte("abd"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	code, stdout, _ = hashsum(t, "-c", "-quiet", manifest)
+	if code != exitFileErrors || !strings.Contains(stdout, a+": FAILED") || strings.Contains(stdout, ": OK") {
+		t.Fatalf("exit code %d, output %q", code, stdout)
+	}
+}
+
+func TestCacheHits(t *testing.T) {
+	dir := tree(t)
+	past := time.Now().Add(-time.Hour)
+	filepath.WalkDir(dir, func(path string, _ fs.DirEntry, _ error) error {
+		return os.Chtimes(path, past, past)
+	})
+	cache := filepath.Join(t.TempDir(), "cache")
+
+	code, first, stderr := hashsum(t, "-cache", cache, dir)
+	if code != exitOK || !strings.Contains(stderr, "0 hits, 4 misses") {
+		t.Fatalf("first run: exit code %d, %q", code, stderr)
+	}
+	code, second, stderr := hashsum(t, "-cache", cache, dir)
+	if code != exitOK || !strings.Contains(stderr, "4 hits, 0 misses (100.0% hit rate)") {
+		t.Fatalf("second run: exit code %d, %q", code, stderr)
+	}
+
+	sorted := func(s string) []string {
+		lines := strings.Split(s, "\n")
+		slices.Sort(lines)
+		return lines
+	}
+	if !slices.Equal(sorted(first), sorted(second)) {
+		t.Fatalf("cached output differs:\n%s\n%s", first, second)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be read while run writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchMode(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("abc"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout, stderr syncBuffer
+	code := make(chan int)
+	go func() {
+		code <- run(ctx, []string{"-watch", "-poll", "20ms", dir}, &stdout, &stderr)
+	}()
+
+	waitFor := func(line string) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if strings.Contains(stdout.String(), line) {
+				return
+			}
+		}
+		t.Fatalf("%q not printed, got %q (stderr %q)", line, stdout.String(), stderr.String())
+	}
+
+	// Сначала хешируются существующие файлы, затем — изменения
+	waitFor("900150983cd24fb0d6963f7d28e17f72  " + filepath.Join(dir, "a.txt"))
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), nil, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	waitFor("d41d8cd98f00b204e9800998ecf8427e  " + filepath.Join(dir, "b.txt"))
+	if err := os.Remove(filepath.Join(dir, "a.txt")); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	waitFor("DELETED  " + filepath.Join(dir, "a.txt"))
+
+	// Прерывание — штатный способ остановить наблюдение
+	cancel()
+	if c := <-code; c != exitOK {
+		t.Fatalf("expected exit code %d, got %d: %s", exitOK, c, stderr.String())
+	}
+}
@@ -0,0 +1,12 @@
+// Command pipelines validates, draws and runs declarative pipeline definitions
+// with the standard node kinds, see package cli.
+package main
+
+import (
+	"github.com/Sergey-Polishchenko/pipelines/cli"
+	"github.com/Sergey-Polishchenko/pipelines/spec/std"
+)
+
+func main() {
+	cli.Main(std.Registry())
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/hashing"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// ManifestGenerator читает манифест, напечатанный прошлым запуском демо (строки "md5  путь"),
+// и выдаёт записанные в нём хеши файлов. Строки каталогов (путь с "/" на конце) пропускаются.
+// Нечитаемый манифест или битая строка завершают генератор с ошибкой (nodes.ReportError).
+func ManifestGenerator(path string) nodes.Generator[FileHash] {
+	md5, _ := hashing.Lookup("md5")
+	entries := hashing.Manifests([]string{path}, md5)
+
+	return func(ctx context.Context) (<-chan FileHash, error) {
+		in, err := entries(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make(chan FileHash)
+		go func() {
+			defer close(out)
+			for e := range in {
+				if e.Err != nil {
+					if e.Line > 0 {
+						e.Err = fmt.Errorf("%s:%d: %w", e.Manifest, e.Line, e.Err)
+					}
+					nodes.ReportError(ctx, e.Err)
+					return
+				}
+				if strings.HasSuffix(e.Path, "/") {
+					continue
+				}
+				if e.Algorithm.Name != md5.Name {
+					nodes.ReportError(ctx, fmt.Errorf("%s:%d: not an MD5 checksum", e.Manifest, e.Line))
+					return
+				}
+
+				fh := FileHash{Path: e.Path}
+				copy(fh.Hash[:], e.Sum)
+				select {
+				case out <- fh:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// NewSnapshotDiff создаёт узел, который сравнивает хеши текущего обхода с манифестом по пути файла.
+func NewSnapshotDiff(manifest string) pipelines.Node[FileHash, nodes.Diff[FileHash]] {
+	return nodes.NewDiff(ManifestGenerator(manifest),
+		func(fh FileHash) string { return fh.Path },
+		func(old, new FileHash) bool { return old.Hash == new.Hash },
+	)
+}
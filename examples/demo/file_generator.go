@@ -2,32 +2,41 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
 )
 
-// FileGenerator возвращает функцию-генератор,
-// которая при каждом вызове отдаёт следующий путь файла из rootDir.
-// Когда файлы заканчиваются — возвращает пустую строку.
+// FileGenerator возвращает генератор путей всех обычных файлов в rootDir (рекурсивно).
+// Каталоги читаются параллельно (sources.ParallelWalk), файлы из .gitignore пропускаются.
+// Ошибки чтения (например, нет прав на каталог) печатаются в stderr и не останавливают обход.
 func FileGenerator(rootDir string) nodes.Generator[string] {
+	walk := sources.ParallelWalk([]string{rootDir}, sources.ParallelWalkOptions{
+		IgnoreFiles: []string{".gitignore"},
+	})
+
 	return func(ctx context.Context) (<-chan string, error) {
+		entries, err := walk(ctx)
+		if err != nil {
+			return nil, err
+		}
+
 		out := make(chan string)
 		go func() {
 			defer close(out)
-			// Вместо накопления всех файлов в слайс, сразу стримим результаты:
-			filepath.WalkDir(rootDir, func(path string, d os.DirEntry, err error) error {
-				if err != nil || d.IsDir() {
-					return nil
+			for e := range entries {
+				if e.Err != nil {
+					fmt.Fprintln(os.Stderr, "walk:", e.Err)
+					continue
 				}
 				select {
-				case out <- path:
-					return nil
+				case out <- e.Path:
 				case <-ctx.Done():
-					return ctx.Err()
+					return
 				}
-			})
+			}
 		}()
 		return out, nil
 	}
@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"crypto/md5"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -16,27 +18,51 @@ type FileHash struct {
 	Hash [16]byte
 }
 
+// HashFile считает MD5 файла, читая его потоком.
+func HashFile(path string) (FileHash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FileHash{}, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return FileHash{}, err
+	}
+
+	fh := FileHash{Path: path}
+	h.Sum(fh.Hash[:0])
+	return fh, nil
+}
+
 func main() {
+	// -diff sums.txt: вместо хешей напечатать, что изменилось с прошлого запуска,
+	// сохранённого командой "go run . > sums.txt"
+	diffWith := flag.String("diff", "", "манифест прошлого запуска для сравнения")
+	flag.Parse()
+
 	// контекст с таймаутом (например, 5 минут), но вы можете убрать таймаут, если он не нужен
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
+	if *diffWith != "" {
+		if err := diffTree(ctx, *diffWith); err != nil {
+			fmt.Fprintf(os.Stderr, "Pipeline error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 1) Генератор, который выдаёт пути файлов
 	fileGen := nodes.NewGenerator(FileGenerator("./"))
 
 	// 2) Пул воркеров: он сам распараллеливает MD5-вычисления.
 	//    По умолчанию DefaultConfig() содержит Workers=10.
 	//    Если хотите другой параллелизм, передайте Config{Workers: N}.
-	md5Worker := nodes.NewWorkerPool(func(path string) (FileHash, error) {
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return FileHash{}, err
-		}
-		return FileHash{
-			Path: path,
-			Hash: md5.Sum(data),
-		}, nil
-	}, nodes.DefaultConfig())
+	//    Файл читается потоком через io.Copy, а не целиком в память: иначе десять
+	//    воркеров на больших файлах съедят всю RAM.
+	md5Worker := nodes.NewWorkerPool(HashFile, nodes.DefaultConfig())
 
 	// 3) Агрегатор итогов: можно либо просто печатать, либо накапливать в срез для дальнейшей обработки.
 	//    Здесь для демонстрации печатаем в stdout.
@@ -45,20 +71,44 @@ func main() {
 		return nil
 	})
 
-	// Составляем пайплайн: fileGen -> md5Worker -> resultAgg
+	// 4) Дайджесты каталогов: пул воркеров отдаёт результаты в произвольном порядке, поэтому
+	//    узел Меркла дожидается всех файлов и считает каталоги снизу вверх, корень — последним.
+	//    У пула ровно один выход, так что результаты раздаёт на два приёмника промежуточный узел.
+	tee := nodes.NewNode(func(fh FileHash) (FileHash, error) { return fh, nil })
+	merkle := NewMerkleAggregator("./")
+	dirAgg := nodes.NewResultAggregator(func(dh DirHash) error {
+		fmt.Printf("%x  %s/\n", dh.Hash, dh.Path)
+		return nil
+	})
+
+	// Составляем пайплайн: fileGen -> md5Worker -> tee -> resultAgg
+	//                                                  \-> merkle -> dirAgg
 	p := pipelines.New()
-	p.Add(fileGen, md5Worker, resultAgg)
+	p.Add(fileGen, md5Worker, tee, resultAgg, merkle, dirAgg)
 
 	// Соединяем их:
 	//   fileGen.Output() -> md5Worker.SetInput()
-	//   md5Worker.Output() -> resultAgg.SetInput()
+	//   md5Worker.Output() -> tee.SetInput()
+	//   tee раздаёт каждый результат и в resultAgg, и в merkle
 	// (в однопоточном конвейере Connect делает всё, что нужно)
 	if err := pipelines.Connect(fileGen, md5Worker); err != nil {
 		fmt.Fprintf(os.Stderr, "Connect fileGen -> md5Worker: %v\n", err)
 		return
 	}
-	if err := pipelines.Connect(md5Worker, resultAgg); err != nil {
-		fmt.Fprintf(os.Stderr, "Connect md5Worker -> resultAgg: %v\n", err)
+	if err := pipelines.Connect(md5Worker, tee); err != nil {
+		fmt.Fprintf(os.Stderr, "Connect md5Worker -> tee: %v\n", err)
+		return
+	}
+	if err := pipelines.Connect(tee, resultAgg); err != nil {
+		fmt.Fprintf(os.Stderr, "Connect tee -> resultAgg: %v\n", err)
+		return
+	}
+	if err := pipelines.Connect(tee, merkle); err != nil {
+		fmt.Fprintf(os.Stderr, "Connect tee -> merkle: %v\n", err)
+		return
+	}
+	if err := pipelines.Connect(merkle, dirAgg); err != nil {
+		fmt.Fprintf(os.Stderr, "Connect merkle -> dirAgg: %v\n", err)
 		return
 	}
 
@@ -67,3 +117,42 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Pipeline error: %v\n", err)
 	}
 }
+
+// diffTree сравнивает текущее дерево с манифестом: fileGen -> md5Worker -> diff -> печать изменений.
+func diffTree(ctx context.Context, manifest string) error {
+	fileGen := nodes.NewGenerator(FileGenerator("./"))
+	md5Worker := nodes.NewWorkerPool(HashFile, nodes.DefaultConfig())
+	diff := NewSnapshotDiff(manifest)
+
+	var counts [nodes.DiffUnchanged + 1]int
+	report := nodes.NewResultAggregator(func(d nodes.Diff[FileHash]) error {
+		counts[d.Kind]++
+		switch d.Kind {
+		case nodes.DiffAdded, nodes.DiffChanged:
+			fmt.Printf("%-8s %s\n", d.Kind.String()+":", d.New.Path)
+		case nodes.DiffRemoved:
+			fmt.Printf("%-8s %s\n", d.Kind.String()+":", d.Old.Path)
+		}
+		return nil
+	})
+
+	if err := pipelines.Connect(fileGen, md5Worker); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(md5Worker, diff); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(diff, report); err != nil {
+		return err
+	}
+
+	p := pipelines.New()
+	p.Add(fileGen, md5Worker, diff, report)
+	if err := p.Run(ctx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%d added, %d removed, %d changed, %d unchanged\n",
+		counts[nodes.DiffAdded], counts[nodes.DiffRemoved], counts[nodes.DiffChanged], counts[nodes.DiffUnchanged])
+	return nil
+}
@@ -3,20 +3,22 @@ package main_test
 import (
 	"context"
 	"crypto/md5"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
-	"time"
 
 	"github.com/Sergey-Polishchenko/pipelines"
 	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
 
 	mainpkg "github.com/Sergey-Polishchenko/pipelines/examples/demo"
 )
 
 func TestPipeline(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-	defer cancel()
+	pipelinetest.VerifyNoLeaks(t)
 
 	// 1. Создаём временную директорию и пару файлов внутри
 	dir := t.TempDir()
@@ -34,49 +36,167 @@ func TestPipeline(t *testing.T) {
 	fileGen := nodes.NewGenerator(mainpkg.FileGenerator(dir))
 
 	// 3. Пул воркеров, который читает файл и считает MD5
-	workers := nodes.NewWorkerPool(func(path string) (mainpkg.FileHash, error) {
-		data, err := os.ReadFile(path)
-		return mainpkg.FileHash{
-			Path: path,
-			Hash: md5.Sum(data),
-		}, err
-	}, nodes.Config{Workers: 2})
-
-	// 4. Нода-агрегатор, которая собирает результаты в срез
-	var results []mainpkg.FileHash
-	resultsNode := nodes.NewResultAggregator(func(fh mainpkg.FileHash) error {
-		results = append(results, fh)
+	workers := nodes.NewWorkerPool(mainpkg.HashFile, nodes.Config{Workers: 2})
+
+	// 4. Сток, который потокобезопасно собирает результаты
+	results, sink := pipelinetest.Collect[mainpkg.FileHash]()
+
+	// 5. Соединяем и запускаем: fileGen → workers → sink
+	pipelinetest.Connect(t, fileGen, workers)
+	pipelinetest.Connect(t, workers, sink)
+	pipelinetest.Run(t, fileGen, workers, sink)
+
+	// 6. Проверяем, что получили ровно ожидаемые хеши, в любом порядке
+	var expected []mainpkg.FileHash
+	for name, content := range files {
+		expected = append(expected, mainpkg.FileHash{Path: filepath.Join(dir, name), Hash: md5.Sum([]byte(content))})
+	}
+	pipelinetest.EqualUnordered(t, results.Items(), expected)
+}
+
+// merkleDigests прогоняет fileGen → пул → узел Меркла и возвращает дайджесты каталогов по путям
+// относительно dir вместе с порядком их выдачи.
+func merkleDigests(t *testing.T, dir string, workers int) (map[string][16]byte, []string) {
+	t.Helper()
+
+	fileGen := nodes.NewGenerator(mainpkg.FileGenerator(dir))
+	pool := nodes.NewWorkerPool(mainpkg.HashFile, nodes.Config{Workers: workers})
+	merkle := mainpkg.NewMerkleAggregator(dir)
+
+	digests := map[string][16]byte{}
+	var order []string
+	sink := nodes.NewResultAggregator(func(dh mainpkg.DirHash) error {
+		rel, _ := filepath.Rel(dir, dh.Path)
+		digests[rel] = dh.Hash
+		order = append(order, rel)
 		return nil
 	})
 
-	// 5. Соединяем: fileGen → workers → resultsNode
-	if err := pipelines.Connect(fileGen, workers); err != nil {
-		t.Fatalf("Connect(fileGen, workers) failed: %v", err)
+	if err := pipelines.Connect(fileGen, pool); err != nil {
+		t.Fatalf("Connect(fileGen, pool) failed: %v", err)
+	}
+	if err := pipelines.Connect(pool, merkle); err != nil {
+		t.Fatalf("Connect(pool, merkle) failed: %v", err)
 	}
-	if err := pipelines.Connect(workers, resultsNode); err != nil {
-		t.Fatalf("Connect(workers, resultsNode) failed: %v", err)
+	if err := pipelines.Connect(merkle, sink); err != nil {
+		t.Fatalf("Connect(merkle, sink) failed: %v", err)
 	}
 
-	// 6. Создаём и запускаем с��м Pipeline
-	pipeline := pipelines.New()
-	pipeline.Add(fileGen, workers, resultsNode)
-	if err := pipeline.Run(ctx); err != nil {
+	p := pipelines.New()
+	p.Add(fileGen, pool, merkle, sink)
+	if err := p.Run(context.Background()); err != nil {
 		t.Fatal("Pipeline error:", err)
 	}
+	return digests, order
+}
 
-	// 7. Проверяем, что мы получили ровно два результата и они совпадают с ожидаемыми
-	expected := map[string][16]byte{}
-	for name, content := range files {
-		expected[filepath.Join(dir, name)] = md5.Sum([]byte(content))
+func TestMerkleAggregator(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.txt":       "a",
+		"x/b.txt":     "b",
+		"x/y/c.txt":   "c",
+		"z/d.txt":     "d",
+		"z/e/f/g.txt": "g",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	// Порядок результатов пула не влияет на дайджесты
+	first, order := merkleDigests(t, dir, 1)
+	second, _ := merkleDigests(t, dir, 8)
+	if len(first) != 6 {
+		t.Fatalf("expected 6 directories, got %v", order)
+	}
+	for path, sum := range first {
+		if second[path] != sum {
+			t.Errorf("%s: digest differs between runs", path)
+		}
+	}
+
+	// Дети выдаются раньше родителей, корень — последним
+	if order[len(order)-1] != "." || slices.Index(order, "x/y") > slices.Index(order, "x") {
+		t.Errorf("unexpected order %v", order)
 	}
 
-	for _, fh := range results {
-		if hash, ok := expected[fh.Path]; !ok || hash != fh.Hash {
-			t.Errorf("Unexpected hash or file: got %s %x", fh.Path, fh.Hash)
+	// Изменение файла меняет дайджесты его каталогов вплоть до корня, но не соседей
+	if err := os.WriteFile(filepath.Join(dir, "x/y/c.txt"), []byte("C"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	changed, _ := merkleDigests(t, dir, 4)
+	for path, want := range map[string]bool{".": true, "x": true, "x/y": true, "z": false, "z/e": false} {
+		if got := changed[path] != first[path]; got != want {
+			t.Errorf("%s: changed = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	write("same.txt", "same")
+	write("changed.txt", "new")
+	write("added.txt", "added")
+
+	// Манифест «прошлого запуска» в том виде, в каком его печатает демо, вместе со строкой каталога
+	old := md5.Sum([]byte("old"))
+	same := md5.Sum([]byte("same"))
+	manifest := filepath.Join(t.TempDir(), "sums.txt")
+	lines := fmt.Sprintf("%x  %s\n%x  %s\n%x  %s\n%x  %s/\n",
+		same, filepath.Join(dir, "same.txt"),
+		old, filepath.Join(dir, "changed.txt"),
+		old, filepath.Join(dir, "removed.txt"),
+		old, dir)
+	if err := os.WriteFile(manifest, []byte(lines), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	fileGen := nodes.NewGenerator(mainpkg.FileGenerator(dir))
+	pool := nodes.NewWorkerPool(mainpkg.HashFile, nodes.Config{Workers: 2})
+	diff := mainpkg.NewSnapshotDiff(manifest)
+	got := map[string]nodes.DiffKind{}
+	sink := nodes.NewResultAggregator(func(d nodes.Diff[mainpkg.FileHash]) error {
+		path := d.New.Path
+		if d.Kind == nodes.DiffRemoved {
+			path = d.Old.Path
 		}
-		delete(expected, fh.Path)
+		got[filepath.Base(path)] = d.Kind
+		return nil
+	})
+
+	if err := pipelines.Connect(fileGen, pool); err != nil {
+		t.Fatalf("Connect(fileGen, pool) failed: %v", err)
+	}
+	if err := pipelines.Connect(pool, diff); err != nil {
+		t.Fatalf("Connect(pool, diff) failed: %v", err)
+	}
+	if err := pipelines.Connect(diff, sink); err != nil {
+		t.Fatalf("Connect(diff, sink) failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(fileGen, pool, diff, sink)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal("Pipeline error:", err)
+	}
+
+	want := map[string]nodes.DiffKind{
+		"same.txt":    nodes.DiffUnchanged,
+		"changed.txt": nodes.DiffChanged,
+		"added.txt":   nodes.DiffAdded,
+		"removed.txt": nodes.DiffRemoved,
 	}
-	if len(expected) != 0 {
-		t.Errorf("Some files were not processed: %v", expected)
+	if !maps.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
 	}
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
+)
+
+var _ pipelines.Node[FileHash, DirHash] = &merkleAggregator{}
+
+// DirHash — дайджест каталога, построенный как дерево Меркла из дайджестов его содержимого.
+type DirHash struct {
+	Path string
+	Hash [16]byte
+	// Files — число файлов в каталоге, включая подкаталоги.
+	Files int
+}
+
+var merkleIDs atomic.Uint64
+
+// merkleDir — каталог, собранный из пришедших дайджестов файлов.
+type merkleDir struct {
+	files map[string][16]byte
+	dirs  map[string]*merkleDir
+}
+
+func newMerkleDir() *merkleDir {
+	return &merkleDir{files: make(map[string][16]byte), dirs: make(map[string]*merkleDir)}
+}
+
+type merkleAggregator struct {
+	id   uint64
+	root string
+
+	in  []<-chan FileHash
+	out []chan<- DirHash
+}
+
+// NewMerkleAggregator создаёт узел, который собирает дайджесты файлов под root в дерево
+// и выдаёт DirHash для каждого каталога, в котором есть файлы, а последним — для самого root.
+//
+// Дайджест каталога — MD5 от записей его детей, отсортированных по имени: для файла
+// "f" + имя + "\x00" + MD5 файла, для подкаталога "d" + имя + "\x00" + его дайджест.
+// Поэтому он не зависит ни от порядка обхода, ни от порядка, в котором пул воркеров
+// выдаёт результаты, и совпадает у одинаковых деревьев. Пустые каталоги не учитываются.
+//
+// Пока не пришли все файлы, нельзя знать, что каталог собран полностью, поэтому узел
+// ждёт закрытия входа и только тогда считает дайджесты снизу вверх: дети раньше родителей.
+func NewMerkleAggregator(root string) pipelines.Node[FileHash, DirHash] {
+	return &merkleAggregator{id: merkleIDs.Add(1), root: filepath.Clean(root)}
+}
+
+func (n *merkleAggregator) ID() string {
+	return fmt.Sprintf("merkle-aggregator-%d", n.id)
+}
+
+func (n *merkleAggregator) SetInput(in ...<-chan FileHash) error {
+	n.in = append(n.in, in...)
+	return nil
+}
+
+func (n *merkleAggregator) Output() (chan DirHash, error) {
+	out := make(chan DirHash)
+	n.out = append(n.out, out)
+	return out, nil
+}
+
+func (n *merkleAggregator) Run(ctx context.Context) error {
+	in, err := utils.FanIn(ctx, n.in, 0)
+	if err != nil {
+		return err
+	}
+	defer utils.CloseChannels(n.out)
+
+	root := newMerkleDir()
+	for {
+		select {
+		case fh, open := <-in:
+			if !open {
+				_, _, err := n.emit(ctx, n.root, root)
+				return err
+			}
+			if err := n.add(root, fh); err != nil {
+				return fmt.Errorf("%s: %w", n.ID(), err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// add кладёт дайджест файла в его каталог, создавая недостающие каталоги.
+func (n *merkleAggregator) add(root *merkleDir, fh FileHash) error {
+	rel, err := filepath.Rel(n.root, fh.Path)
+	if err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return fmt.Errorf("file %s is not under %s", fh.Path, n.root)
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	dir := root
+	for _, name := range parts[:len(parts)-1] {
+		sub, ok := dir.dirs[name]
+		if !ok {
+			sub = newMerkleDir()
+			dir.dirs[name] = sub
+		}
+		dir = sub
+	}
+	dir.files[parts[len(parts)-1]] = fh.Hash
+	return nil
+}
+
+// emit считает дайджест каталога dir по пути path, выдав перед этим дайджесты подкаталогов.
+func (n *merkleAggregator) emit(ctx context.Context, path string, dir *merkleDir) ([16]byte, int, error) {
+	names := slices.AppendSeq(slices.Collect(maps.Keys(dir.files)), maps.Keys(dir.dirs))
+	slices.Sort(names)
+
+	h := md5.New()
+	files := len(dir.files)
+	for _, name := range names {
+		if sum, ok := dir.files[name]; ok {
+			fmt.Fprintf(h, "f%s\x00", name)
+			h.Write(sum[:])
+			continue
+		}
+
+		sum, count, err := n.emit(ctx, filepath.Join(path, name), dir.dirs[name])
+		if err != nil {
+			return sum, 0, err
+		}
+		fmt.Fprintf(h, "d%s\x00", name)
+		h.Write(sum[:])
+		files += count
+	}
+
+	dh := DirHash{Path: path, Files: files}
+	h.Sum(dh.Hash[:0])
+	return dh.Hash, files, utils.Broadcast(ctx, n.out, dh)
+}
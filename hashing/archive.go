@@ -0,0 +1,353 @@
+package hashing
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// ArchiveSeparator separates the path of an archive from the path of a member in it,
+// as in "bundle.zip!/dir/file".
+const ArchiveSeparator = "!/"
+
+const (
+	// DefaultArchiveDepth is the nesting limit used when ArchiveOptions.MaxDepth is not set.
+	DefaultArchiveDepth = 4
+	// DefaultMaxNestedSize is the size limit used when ArchiveOptions.MaxNestedSize is not set.
+	DefaultMaxNestedSize = 64 << 20
+)
+
+// ErrArchiveTooLarge is the error of a nested archive larger than ArchiveOptions.MaxNestedSize.
+// The archive is still hashed as a member, but its own members are not.
+var ErrArchiveTooLarge = errors.New("nested archive too large to expand")
+
+// ArchiveOptions configures ExpandArchives.
+type ArchiveOptions struct {
+	// MaxDepth limits how deeply archives are expanded: 1 expands only archives that are
+	// files, 2 also archives inside them, and so on. DefaultArchiveDepth if not positive.
+	MaxDepth int
+	// MaxNestedSize is the largest nested archive expanded. Nested archives are held in
+	// memory while they are expanded, never extracted to disk. DefaultMaxNestedSize if
+	// not positive.
+	MaxNestedSize int64
+}
+
+// archiveKind is the format of an archive, as told by its name.
+type archiveKind int
+
+const (
+	notArchive archiveKind = iota
+	zipArchive
+	tarArchive
+	tarGzArchive
+)
+
+func kindOf(name string) archiveKind {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return zipArchive
+	case strings.HasSuffix(name, ".tar"):
+		return tarArchive
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return tarGzArchive
+	}
+	return notArchive
+}
+
+// ExpandArchives returns a Generator of targets followed, for each .zip, .tar, .tar.gz or .tgz
+// file among them, by Targets for the regular files inside it, named with ArchiveSeparator.
+// Archives inside archives are expanded as well, down to opts.MaxDepth. Members are read
+// from the archives through Target.Open; nothing is extracted to disk. Archives that cannot
+// be read are emitted as Targets with Err set, and expanding goes on.
+//
+// Every member must be opened and closed, as HashTarget does: members of zip archives may be
+// read in parallel, but tar archives are streams, so ExpandArchives waits for each of their
+// members to be closed before it emits the next one.
+func ExpandArchives(targets nodes.Generator[Target], opts ...ArchiveOptions) nodes.Generator[Target] {
+	var o ArchiveOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.MaxDepth <= 0 {
+		o.MaxDepth = DefaultArchiveDepth
+	}
+	if o.MaxNestedSize <= 0 {
+		o.MaxNestedSize = DefaultMaxNestedSize
+	}
+
+	return func(ctx context.Context) (<-chan Target, error) {
+		in, err := targets(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return nodes.Produce(ctx, func(emit func(Target) bool) error {
+			x := &expander{ctx: ctx, emit: emit, opts: o}
+			for t := range in {
+				if !x.emit(t) {
+					return nil
+				}
+				if t.Err != nil || t.Open != nil || t.Path == StdinPath || kindOf(t.Path) == notArchive {
+					continue
+				}
+				if !x.expandFile(t.Path) {
+					return nil
+				}
+			}
+			return nil
+		}), nil
+	}
+}
+
+// expander emits the members of archives; emit reports false once ctx is canceled.
+type expander struct {
+	ctx  context.Context
+	emit func(Target) bool
+	opts ArchiveOptions
+}
+
+func (x *expander) fail(name string, err error) bool {
+	return x.emit(Target{Path: name, Err: err})
+}
+
+// expandFile expands the archive at path on disk.
+func (x *expander) expandFile(name string) bool {
+	f, err := os.Open(name)
+	if err != nil {
+		return x.fail(name, err)
+	}
+
+	if kind := kindOf(name); kind != zipArchive {
+		defer f.Close()
+		return x.expandTar(name, f, kind, 1)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return x.fail(name, err)
+	}
+	// members are read after expandZip returns, so the file is closed by the last of them
+	shared := &sharedFile{f: f}
+	shared.refs.Store(1)
+	stop := context.AfterFunc(x.ctx, shared.close)
+	defer func() {
+		if shared.release() {
+			stop()
+		}
+	}()
+	return x.expandZip(name, f, info.Size(), 1, shared)
+}
+
+// expandZip emits the members of the zip archive name read from r. shared is the file r
+// reads from, if any; it is retained by every member emitted.
+func (x *expander) expandZip(name string, r io.ReaderAt, size int64, depth int, shared *sharedFile) bool {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return x.fail(name, fmt.Errorf("%s: %w", name, err))
+	}
+
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		member := memberPath(name, f.Name)
+		size := int64(f.UncompressedSize64)
+
+		if x.nested(f.Name, depth) && size <= x.opts.MaxNestedSize {
+			if !x.expandNested(member, f.Open, depth) {
+				return false
+			}
+			continue
+		}
+
+		open := f.Open
+		if shared != nil {
+			shared.refs.Add(1)
+			open = shared.opener(f.Open)
+		}
+		if !x.emit(Target{Path: member, Open: open}) {
+			return false
+		}
+		if x.nested(f.Name, depth) && !x.tooLarge(member) {
+			return false
+		}
+	}
+	return true
+}
+
+// expandTar emits the members of the tar archive name read from r.
+func (x *expander) expandTar(name string, r io.Reader, kind archiveKind, depth int) bool {
+	if kind == tarGzArchive {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return x.fail(name, fmt.Errorf("%s: %w", name, err))
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return x.fail(name, fmt.Errorf("%s: %w", name, err))
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		member := memberPath(name, hdr.Name)
+
+		if x.nested(hdr.Name, depth) && hdr.Size <= x.opts.MaxNestedSize {
+			read := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+			if !x.expandNested(member, read, depth) {
+				return false
+			}
+			continue
+		}
+
+		// the member can only be read before the next one, so wait for it to be read
+		h := &handoff{r: tr, done: make(chan struct{})}
+		if !x.emit(Target{Path: member, Open: h.open}) {
+			return false
+		}
+		select {
+		case <-h.done:
+		case <-x.ctx.Done():
+			return false
+		}
+		if x.nested(hdr.Name, depth) && !x.tooLarge(member) {
+			return false
+		}
+	}
+}
+
+// nested reports whether the member name at depth is an archive to expand.
+func (x *expander) nested(name string, depth int) bool {
+	return depth < x.opts.MaxDepth && kindOf(name) != notArchive
+}
+
+// tooLarge reports that the archive member is not expanded because of its size.
+func (x *expander) tooLarge(member string) bool {
+	return x.fail(member, &fs.PathError{Op: "expand", Path: member, Err: ErrArchiveTooLarge})
+}
+
+// expandNested reads the archive member into memory, emits it and then its members.
+func (x *expander) expandNested(member string, read func() (io.ReadCloser, error), depth int) bool {
+	rc, err := read()
+	if err != nil {
+		return x.fail(member, fmt.Errorf("%s: %w", member, err))
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, x.opts.MaxNestedSize+1))
+	rc.Close()
+	switch {
+	case err != nil:
+		return x.fail(member, fmt.Errorf("%s: %w", member, err))
+	case int64(len(data)) > x.opts.MaxNestedSize:
+		// the archive declared a wrong size, and the member cannot be read again
+		return x.tooLarge(member)
+	}
+
+	open := func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	if !x.emit(Target{Path: member, Open: open}) {
+		return false
+	}
+	kind := kindOf(member)
+	if kind == zipArchive {
+		return x.expandZip(member, bytes.NewReader(data), int64(len(data)), depth+1, nil)
+	}
+	return x.expandTar(member, bytes.NewReader(data), kind, depth+1)
+}
+
+func memberPath(archive, name string) string {
+	return archive + ArchiveSeparator + strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// handoff lends a tar member to the consumer of its Target until it is closed.
+type handoff struct {
+	r      io.Reader
+	done   chan struct{}
+	opened atomic.Bool
+	closed sync.Once
+}
+
+func (h *handoff) open() (io.ReadCloser, error) {
+	if h.opened.Swap(true) {
+		return nil, errors.New("tar member opened twice")
+	}
+	return h, nil
+}
+
+func (h *handoff) Read(p []byte) (int, error) {
+	return h.r.Read(p)
+}
+
+func (h *handoff) Close() error {
+	h.closed.Do(func() { close(h.done) })
+	return nil
+}
+
+// sharedFile is a file closed once every reference to it is released, or when the
+// expansion is canceled.
+type sharedFile struct {
+	f    *os.File
+	refs atomic.Int64
+	once sync.Once
+}
+
+func (s *sharedFile) close() {
+	s.once.Do(func() { s.f.Close() })
+}
+
+// release drops a reference and reports whether it was the last one.
+func (s *sharedFile) release() bool {
+	if s.refs.Add(-1) == 0 {
+		s.close()
+		return true
+	}
+	return false
+}
+
+// opener returns the Open of a member read from the file; the reference it holds is
+// released when the member is closed.
+func (s *sharedFile) opener(open func() (io.ReadCloser, error)) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		rc, err := open()
+		if err != nil {
+			s.release()
+			return nil, err
+		}
+		return &sharedReader{ReadCloser: rc, file: s}, nil
+	}
+}
+
+type sharedReader struct {
+	io.ReadCloser
+	file   *sharedFile
+	closed atomic.Bool
+}
+
+func (r *sharedReader) Close() error {
+	err := r.ReadCloser.Close()
+	if !r.closed.Swap(true) {
+		r.file.release()
+	}
+	return err
+}
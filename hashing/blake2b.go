@@ -0,0 +1,163 @@
+package hashing
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// BLAKE2b (RFC 7693) is implemented here because the standard library does not provide it
+// and the module has no external dependencies. Only unkeyed hashing is supported.
+
+const (
+	blake2bBlockSize = 128
+	blake2bSize512   = 64
+)
+
+var blake2bIV = [8]uint64{
+	0x6a09e667f3bcc908, 0xbb67ae8584caa73b, 0x3c6ef372fe94f82b, 0xa54ff53a5f1d36f1,
+	0x510e527fade682d1, 0x9b05688c2b3e6c1f, 0x1f83d9abfb41bd6b, 0x5be0cd19137e2179,
+}
+
+var blake2bSigma = [12][16]byte{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+	{11, 8, 12, 0, 5, 2, 15, 13, 10, 14, 3, 6, 7, 1, 9, 4},
+	{7, 9, 3, 1, 13, 12, 11, 14, 2, 6, 5, 10, 4, 0, 15, 8},
+	{9, 0, 5, 7, 2, 4, 10, 15, 14, 1, 11, 12, 6, 8, 3, 13},
+	{2, 12, 6, 10, 0, 11, 8, 3, 4, 13, 7, 5, 15, 14, 1, 9},
+	{12, 5, 1, 15, 14, 13, 4, 10, 0, 7, 6, 3, 9, 2, 8, 11},
+	{13, 11, 7, 14, 12, 1, 3, 9, 5, 0, 15, 4, 8, 6, 2, 10},
+	{6, 15, 14, 9, 11, 3, 0, 8, 12, 2, 13, 7, 1, 4, 10, 5},
+	{10, 2, 8, 4, 7, 6, 1, 5, 15, 11, 9, 14, 3, 12, 13, 0},
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+}
+
+type blake2b struct {
+	h    [8]uint64
+	t    [2]uint64
+	buf  [blake2bBlockSize]byte
+	n    int
+	size int
+
+	params blake2bParams
+}
+
+// blake2bParams are the tree hashing fields of the parameter block (RFC 7693, section 2.5
+// and the BLAKE2 paper, section 2.10). Sequential hashing uses fanout 1 and depth 1.
+type blake2bParams struct {
+	fanout      byte
+	depth       byte
+	leafLength  uint32
+	nodeOffset  uint64
+	nodeDepth   byte
+	innerLength byte
+	// lastNode sets the last node flag, used for the last node of each tree level
+	lastNode bool
+}
+
+// newBLAKE2b512 returns a BLAKE2b hash with a 64-byte digest.
+func newBLAKE2b512() hash.Hash {
+	return newBLAKE2bNode(blake2bParams{fanout: 1, depth: 1})
+}
+
+// newBLAKE2bNode returns a BLAKE2b-512 hash of one node of a hash tree.
+func newBLAKE2bNode(p blake2bParams) *blake2b {
+	d := &blake2b{size: blake2bSize512, params: p}
+	d.Reset()
+	return d
+}
+
+func (d *blake2b) Size() int      { return d.size }
+func (d *blake2b) BlockSize() int { return blake2bBlockSize }
+
+func (d *blake2b) Reset() {
+	p := d.params
+	d.h = blake2bIV
+	// parameter block: digest length, no key, tree parameters, no salt or personalization
+	d.h[0] ^= uint64(d.size) | uint64(p.fanout)<<16 | uint64(p.depth)<<24 | uint64(p.leafLength)<<32
+	d.h[1] ^= p.nodeOffset
+	d.h[2] ^= uint64(p.nodeDepth) | uint64(p.innerLength)<<8
+	d.t = [2]uint64{}
+	d.n = 0
+}
+
+func (d *blake2b) Write(p []byte) (int, error) {
+	written := len(p)
+
+	// the last block is compressed with the final flag, so a full buffer is kept
+	// until more input arrives
+	for len(p) > 0 {
+		if d.n == blake2bBlockSize {
+			d.compress(d.buf[:], false)
+			d.n = 0
+		}
+		k := copy(d.buf[d.n:], p)
+		d.n += k
+		p = p[k:]
+	}
+	return written, nil
+}
+
+func (d *blake2b) Sum(b []byte) []byte {
+	c := *d
+	clear(c.buf[c.n:])
+	c.compress(c.buf[:], true)
+
+	var out [blake2bSize512]byte
+	for i, v := range c.h {
+		binary.LittleEndian.PutUint64(out[i*8:], v)
+	}
+	return append(b, out[:c.size]...)
+}
+
+func (d *blake2b) compress(block []byte, last bool) {
+	d.t[0] += uint64(d.n)
+	if d.t[0] < uint64(d.n) {
+		d.t[1]++
+	}
+
+	var m [16]uint64
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(block[i*8:])
+	}
+
+	var v [16]uint64
+	copy(v[:8], d.h[:])
+	copy(v[8:], blake2bIV[:])
+	v[12] ^= d.t[0]
+	v[13] ^= d.t[1]
+	if last {
+		v[14] = ^v[14]
+		if d.params.lastNode {
+			v[15] = ^v[15]
+		}
+	}
+
+	g := func(a, b, c, e int, x, y uint64) {
+		v[a] = v[a] + v[b] + x
+		v[e] = bits.RotateLeft64(v[e]^v[a], -32)
+		v[c] = v[c] + v[e]
+		v[b] = bits.RotateLeft64(v[b]^v[c], -24)
+		v[a] = v[a] + v[b] + y
+		v[e] = bits.RotateLeft64(v[e]^v[a], -16)
+		v[c] = v[c] + v[e]
+		v[b] = bits.RotateLeft64(v[b]^v[c], -63)
+	}
+
+	for _, s := range blake2bSigma {
+		g(0, 4, 8, 12, m[s[0]], m[s[1]])
+		g(1, 5, 9, 13, m[s[2]], m[s[3]])
+		g(2, 6, 10, 14, m[s[4]], m[s[5]])
+		g(3, 7, 11, 15, m[s[6]], m[s[7]])
+		g(0, 5, 10, 15, m[s[8]], m[s[9]])
+		g(1, 6, 11, 12, m[s[10]], m[s[11]])
+		g(2, 7, 8, 13, m[s[12]], m[s[13]])
+		g(3, 4, 9, 14, m[s[14]], m[s[15]])
+	}
+
+	for i := range d.h {
+		d.h[i] ^= v[i] ^ v[i+8]
+	}
+}
@@ -0,0 +1,82 @@
+package hashing
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// Budget limits the total size of the read buffers in use at once, and with it the memory
+// and the number of concurrent reads of all hashing that shares it. Waiters are served in
+// FIFO order. It is safe for concurrent use.
+type Budget struct {
+	mu      sync.Mutex
+	limit   int64
+	used    int64
+	waiters list.List // of *budgetWaiter
+}
+
+type budgetWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// NewBudget returns a Budget of limit bytes.
+func NewBudget(limit int64) *Budget {
+	return &Budget{limit: max(limit, 1)}
+}
+
+// Acquire blocks until n bytes of the budget are available or ctx is done, and returns
+// the number of bytes granted: requests larger than the whole budget are reduced to it.
+// The granted amount must be given back with Release.
+func (b *Budget) Acquire(ctx context.Context, n int64) (int64, error) {
+	n = min(max(n, 1), b.limit)
+
+	b.mu.Lock()
+	if b.used+n <= b.limit && b.waiters.Len() == 0 {
+		b.used += n
+		b.mu.Unlock()
+		return n, nil
+	}
+	w := &budgetWaiter{n: n, ready: make(chan struct{})}
+	elem := b.waiters.PushBack(w)
+	b.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return n, nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		select {
+		case <-w.ready:
+			// granted while giving up; hand the bytes to the next waiters
+			b.used -= n
+		default:
+			b.waiters.Remove(elem)
+		}
+		b.notify()
+		return 0, ctx.Err()
+	}
+}
+
+// Release returns n bytes granted by Acquire.
+func (b *Budget) Release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.notify()
+	b.mu.Unlock()
+}
+
+// notify grants bytes to the waiters at the front of the queue. b.mu must be held.
+func (b *Budget) notify() {
+	for e := b.waiters.Front(); e != nil; e = b.waiters.Front() {
+		w := e.Value.(*budgetWaiter)
+		if b.used+w.n > b.limit {
+			return
+		}
+		b.used += w.n
+		b.waiters.Remove(e)
+		close(w.ready)
+	}
+}
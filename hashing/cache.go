@@ -0,0 +1,252 @@
+package hashing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/internal/fileid"
+)
+
+// racyWindow is how recent a modification time must be for a digest not to be stored:
+// a file changed again within the timestamp granularity would look unchanged.
+const racyWindow = 2 * time.Second
+
+// CacheOptions controls when a Cache trusts its stored digests.
+type CacheOptions struct {
+	// MaxAge makes digests stored longer ago than this count as stale; 0 keeps them forever.
+	MaxAge time.Duration
+	// IgnoreInode compares only size and modification time, for filesystems whose inode
+	// numbers are not stable, such as some network and FUSE mounts.
+	IgnoreInode bool
+	// Rehash ignores all stored digests; the new ones are still stored.
+	Rehash bool
+	// Prune drops, on Save, the entries of files not hashed through the Cache since it was opened.
+	Prune bool
+}
+
+// CacheStats counts the lookups of a Cache.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRate returns the share of lookups served from the cache, between 0 and 1.
+func (s CacheStats) HitRate() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// cacheRecord is one line of the cache file.
+type cacheRecord struct {
+	Path      string `json:"path"`
+	Algorithm string `json:"algorithm"`
+	Size      int64  `json:"size"`
+	ModTime   int64  `json:"mtime"`
+	Device    uint64 `json:"dev,omitempty"`
+	Inode     uint64 `json:"ino,omitempty"`
+	Hash      string `json:"hash"`
+	HashedAt  int64  `json:"hashed_at"`
+
+	sum  []byte
+	used bool
+}
+
+// Cache remembers the digests of files keyed by absolute path and algorithm, together with
+// the size, modification time and inode they had when hashed, so that unchanged files are
+// not read again. It is kept in a JSON Lines file and is safe for concurrent use.
+type Cache struct {
+	path string
+	opts CacheOptions
+
+	mu      sync.Mutex
+	records map[string]*cacheRecord
+	dirty   bool
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// OpenCache loads the cache file at path; a missing file gives an empty Cache. Lines that
+// cannot be parsed are dropped, so a damaged file only costs re-hashing.
+func OpenCache(path string, opts ...CacheOptions) (*Cache, error) {
+	c := &Cache{path: path, records: make(map[string]*cacheRecord)}
+	if len(opts) > 0 {
+		c.opts = opts[0]
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for line := range bytes.Lines(data) {
+		var r cacheRecord
+		if json.Unmarshal(line, &r) != nil || r.Path == "" {
+			c.dirty = true
+			continue
+		}
+		if r.sum, err = hex.DecodeString(r.Hash); err != nil {
+			c.dirty = true
+			continue
+		}
+		c.records[cacheKey(r.Path, r.Algorithm)] = &r
+	}
+	return c, nil
+}
+
+func cacheKey(path, alg string) string {
+	return alg + "\x00" + path
+}
+
+// HashFile is like the package-level HashFile, but returns the stored digest if the file
+// has not changed since it was stored, and stores the digests it computes. Standard input
+// is never cached.
+func (c *Cache) HashFile(ctx context.Context, path string, alg Algorithm, opts ...Options) FileHash {
+	if path == StdinPath {
+		return HashFile(ctx, path, alg, opts...)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return FileHash{Path: path, Algorithm: alg, Err: err}
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		return FileHash{Path: path, Algorithm: alg, Err: err}
+	}
+
+	key := cacheKey(abs, alg.Name)
+	if sum, ok := c.lookup(key, before); ok {
+		c.hits.Add(1)
+		return FileHash{Path: path, Algorithm: alg, Sum: sum, Size: before.Size()}
+	}
+	c.misses.Add(1)
+
+	started := time.Now()
+	fh := HashFile(ctx, path, alg, opts...)
+	if fh.Err != nil {
+		return fh
+	}
+
+	// store only what is known to belong to this version of the file
+	after, err := os.Stat(path)
+	if err != nil || !sameVersion(before, after) || before.ModTime().After(started.Add(-racyWindow)) {
+		return fh
+	}
+	dev, ino := fileid.Of(before)
+	c.store(key, &cacheRecord{
+		Path:      abs,
+		Algorithm: alg.Name,
+		Size:      before.Size(),
+		ModTime:   before.ModTime().UnixNano(),
+		Device:    dev,
+		Inode:     ino,
+		Hash:      hex.EncodeToString(fh.Sum),
+		HashedAt:  started.Unix(),
+		sum:       fh.Sum,
+	})
+	return fh
+}
+
+func (c *Cache) lookup(key string, info os.FileInfo) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.records[key]
+	if !ok {
+		return nil, false
+	}
+	r.used = true
+
+	switch {
+	case c.opts.Rehash:
+		return nil, false
+	case r.Size != info.Size() || r.ModTime != info.ModTime().UnixNano():
+		return nil, false
+	case c.opts.MaxAge > 0 && time.Since(time.Unix(r.HashedAt, 0)) > c.opts.MaxAge:
+		return nil, false
+	}
+	if !c.opts.IgnoreInode {
+		if dev, ino := fileid.Of(info); dev != r.Device || ino != r.Inode {
+			return nil, false
+		}
+	}
+	return r.sum, true
+}
+
+func (c *Cache) store(key string, r *cacheRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r.used = true
+	c.records[key] = r
+	c.dirty = true
+}
+
+func sameVersion(a, b os.FileInfo) bool {
+	return a.Size() == b.Size() && a.ModTime().Equal(b.ModTime()) && os.SameFile(a, b)
+}
+
+// Stats returns the hits and misses since the Cache was opened.
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// Save writes the cache file if anything changed, replacing it atomically.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.opts.Prune {
+		for key, r := range c.records {
+			if !r.used {
+				delete(c.records, key)
+				c.dirty = true
+			}
+		}
+	}
+	if !c.dirty {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, key := range slices.Sorted(maps.Keys(c.records)) {
+		data, _ := json.Marshal(c.records[key])
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	if err := errors.Join(w.Flush(), tmp.Sync()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,122 @@
+package hashing
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// StdinPath is the path that stands for standard input.
+const StdinPath = "-"
+
+// DefaultBufferSize is the read buffer size used when Options.BufferSize is not set.
+const DefaultBufferSize = 256 << 10
+
+// Options tunes how files are read. Files are always streamed through a fixed buffer,
+// never loaded into memory whole.
+type Options struct {
+	// BufferSize is the size of the read buffer of each file or chunk being hashed,
+	// DefaultBufferSize if not positive.
+	BufferSize int
+	// Budget, if set, is shared by all hashing in progress: each reader holds its buffer
+	// from the Budget while reading, and waits when the Budget is spent.
+	Budget *Budget
+}
+
+func optionsOf(opts []Options) Options {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = DefaultBufferSize
+	}
+	return o
+}
+
+// FileHash is the result of hashing one file. Err is set instead of Sum if the
+// file could not be read, so that one bad file does not stop the others.
+type FileHash struct {
+	Path      string
+	Algorithm Algorithm
+	Sum       []byte
+	Size      int64
+	Err       error
+}
+
+// HashFile hashes the file at path, or standard input for StdinPath. Reading stops
+// early when ctx is canceled.
+func HashFile(ctx context.Context, path string, alg Algorithm, opts ...Options) FileHash {
+	fh := FileHash{Path: path, Algorithm: alg}
+
+	var r io.Reader = os.Stdin
+	if path != StdinPath {
+		f, err := os.Open(path)
+		if err != nil {
+			fh.Err = err
+			return fh
+		}
+		defer f.Close()
+		r = f
+	}
+
+	h := alg.New()
+	fh.Size, fh.Err = copyBuffered(ctx, h, r, optionsOf(opts))
+	if fh.Err == nil {
+		fh.Sum = h.Sum(nil)
+	}
+	return fh
+}
+
+// HashTarget hashes t, reading it with t.Open if set and with HashFile otherwise.
+// The error of a failed Target is returned as the error of the FileHash.
+func HashTarget(ctx context.Context, t Target, alg Algorithm, opts ...Options) FileHash {
+	switch {
+	case t.Err != nil:
+		return FileHash{Path: t.Path, Algorithm: alg, Err: t.Err}
+	case t.Open == nil:
+		return HashFile(ctx, t.Path, alg, opts...)
+	}
+
+	fh := FileHash{Path: t.Path, Algorithm: alg}
+	r, err := t.Open()
+	if err != nil {
+		fh.Err = err
+		return fh
+	}
+	defer r.Close()
+
+	h := alg.New()
+	fh.Size, fh.Err = copyBuffered(ctx, h, r, optionsOf(opts))
+	if fh.Err == nil {
+		fh.Sum = h.Sum(nil)
+	}
+	return fh
+}
+
+// copyBuffered copies r to w through a buffer of o.BufferSize bytes taken from o.Budget.
+func copyBuffered(ctx context.Context, w io.Writer, r io.Reader, o Options) (int64, error) {
+	size := int64(o.BufferSize)
+	if o.Budget != nil {
+		var err error
+		if size, err = o.Budget.Acquire(ctx, size); err != nil {
+			return 0, err
+		}
+		defer o.Budget.Release(size)
+	}
+
+	return io.CopyBuffer(w, &contextReader{ctx: ctx, r: r}, make([]byte, size))
+}
+
+// contextReader fails reads once its context is canceled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,99 @@
+package hashing
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Format is an output format for FileHash results.
+type Format int
+
+const (
+	// FormatGNU is the md5sum/sha256sum style: "<hex>  <path>".
+	FormatGNU Format = iota
+	// FormatBSD is the BSD tag style: "SHA256 (<path>) = <hex>".
+	FormatBSD
+	// FormatJSONL is one JSON object per line with path, algorithm, hash and size.
+	FormatJSONL
+)
+
+var formatNames = []string{"gnu", "bsd", "jsonl"}
+
+func (f Format) String() string {
+	if int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return "unknown"
+}
+
+// ParseFormat returns the format named s: gnu, bsd or jsonl.
+func ParseFormat(s string) (Format, error) {
+	for i, name := range formatNames {
+		if strings.EqualFold(s, name) {
+			return Format(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown output format %q, want one of %s", s, strings.Join(formatNames, ", "))
+}
+
+type jsonRecord struct {
+	Path      string `json:"path"`
+	Algorithm string `json:"algorithm"`
+	Hash      string `json:"hash"`
+	Size      int64  `json:"size"`
+}
+
+// Line formats a successful result as one output line without the line terminator.
+func (f Format) Line(fh FileHash) string {
+	sum := hex.EncodeToString(fh.Sum)
+
+	switch f {
+	case FormatBSD:
+		path, escaped := escapePath(fh.Path)
+		return escaped + fmt.Sprintf("%s (%s) = %s", fh.Algorithm.Tag, path, sum)
+	case FormatJSONL:
+		data, _ := json.Marshal(jsonRecord{
+			Path:      fh.Path,
+			Algorithm: fh.Algorithm.Name,
+			Hash:      sum,
+			Size:      fh.Size,
+		})
+		return string(data)
+	default:
+		path, escaped := escapePath(fh.Path)
+		return escaped + sum + "  " + path
+	}
+}
+
+// RemovedLine formats the report of a file that no longer exists, as printed by watch mode:
+// "DELETED  <path>" for FormatGNU, "DELETED (<path>)" for FormatBSD and a JSON object with
+// "deleted": true for FormatJSONL.
+func (f Format) RemovedLine(path string) string {
+	switch f {
+	case FormatBSD:
+		path, escaped := escapePath(path)
+		return escaped + "DELETED (" + path + ")"
+	case FormatJSONL:
+		data, _ := json.Marshal(struct {
+			Path    string `json:"path"`
+			Deleted bool   `json:"deleted"`
+		}{path, true})
+		return string(data)
+	default:
+		path, escaped := escapePath(path)
+		return escaped + "DELETED  " + path
+	}
+}
+
+var pathEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// escapePath escapes backslashes and line breaks like coreutils does; such lines
+// start with a backslash, returned as prefix.
+func escapePath(path string) (escaped string, prefix string) {
+	if !strings.ContainsAny(path, "\\\n\r") {
+		return path, ""
+	}
+	return pathEscaper.Replace(path), `\`
+}
@@ -0,0 +1,54 @@
+// Package hashing provides the building blocks of the hashsum command: hash algorithms,
+// a file walker that reports errors per path, streaming file hashing with a shared memory
+// budget, chunk-parallel tree hashing, a persistent digest cache, output formats and
+// checksum manifests.
+package hashing
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"hash/crc32"
+	"slices"
+	"strings"
+)
+
+// Algorithm is a named hash function.
+type Algorithm struct {
+	// Name is the lower-case name used on the command line, e.g. "sha256".
+	Name string
+	// Tag is the name used by BSD-style output, e.g. "SHA256".
+	Tag string
+	New func() hash.Hash
+}
+
+var algorithms = []Algorithm{
+	{Name: "md5", Tag: "MD5", New: md5.New},
+	{Name: "sha1", Tag: "SHA1", New: sha1.New},
+	{Name: "sha256", Tag: "SHA256", New: sha256.New},
+	{Name: "sha512", Tag: "SHA512", New: sha512.New},
+	{Name: "blake2b", Tag: "BLAKE2b", New: newBLAKE2b512},
+	{Name: "crc32", Tag: "CRC32", New: func() hash.Hash { return crc32.NewIEEE() }},
+}
+
+// Lookup returns the algorithm with the given name, ignoring case.
+func Lookup(name string) (Algorithm, bool) {
+	i := slices.IndexFunc(algorithms, func(a Algorithm) bool {
+		return strings.EqualFold(a.Name, name)
+	})
+	if i < 0 {
+		return Algorithm{}, false
+	}
+	return algorithms[i], true
+}
+
+// Algorithms returns the names of all supported algorithms.
+func Algorithms() []string {
+	names := make([]string, len(algorithms))
+	for i, a := range algorithms {
+		names[i] = a.Name
+	}
+	return names
+}
@@ -0,0 +1,422 @@
+package hashing_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/hashing"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/sinks"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+func TestAlgorithms(t *testing.T) {
+	// Эталонные значения для строки "abc"
+	want := map[string]string{
+		"md5":     "900150983cd24fb0d6963f7d28e17f72",
+		"sha1":    "a9993e364706816aba3e25717850c26c9cd0d89d",
+		"sha256":  "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"sha512":  "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f",
+		"blake2b": "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+		"crc32":   "352441c2",
+	}
+
+	for _, name := range hashing.Algorithms() {
+		alg, ok := hashing.Lookup(strings.ToUpper(name))
+		if !ok {
+			t.Fatalf("Lookup(%q) failed", name)
+		}
+		h := alg.New()
+		h.Write([]byte("abc"))
+		if got := hex.EncodeToString(h.Sum(nil)); got != want[name] {
+			t.Errorf("%s(abc) = %s, want %s", name, got, want[name])
+		}
+	}
+}
+
+func TestBLAKE2bBlocks(t *testing.T) {
+	alg, _ := hashing.Lookup("blake2b")
+
+	empty := alg.New()
+	if got := hex.EncodeToString(empty.Sum(nil)); got != "786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce" {
+		t.Fatalf("blake2b of empty input = %s", got)
+	}
+
+	// Результат не зависит от разбиения входа, в том числе на границах блоков в 128 байт
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64)
+	whole := alg.New()
+	whole.Write(data)
+	want := whole.Sum(nil)
+
+	for _, chunk := range []int{1, 7, 127, 128, 129, 1000} {
+		h := alg.New()
+		for rest := data; len(rest) > 0; {
+			n := min(chunk, len(rest))
+			h.Write(rest[:n])
+			rest = rest[n:]
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("chunks of %d: got %x, want %x", chunk, got, want)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	alg, _ := hashing.Lookup("sha1")
+	fh := hashing.FileHash{Path: "dir/a\nb", Algorithm: alg, Sum: []byte{0xab, 0xcd}, Size: 3}
+
+	tests := []struct {
+		format hashing.Format
+		want   string
+	}{
+		{hashing.FormatGNU, `\abcd  dir/a\nb`},
+		{hashing.FormatBSD, `\SHA1 (dir/a\nb) = abcd`},
+		{hashing.FormatJSONL, `{"path":"dir/a\nb","algorithm":"sha1","hash":"abcd","size":3}`},
+	}
+	for _, tt := range tests {
+		if got := tt.format.Line(fh); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestParseManifestLine(t *testing.T) {
+	md5, _ := hashing.Lookup("md5")
+
+	for _, tc := range []struct {
+		line, path, alg string
+	}{
+		{"900150983cd24fb0d6963f7d28e17f72  a.txt", "a.txt", "md5"},
+		{"900150983cd24fb0d6963f7d28e17f72 *dir/b (1).bin", "dir/b (1).bin", "md5"},
+		{`\900150983cd24fb0d6963f7d28e17f72  a\\b\nc`, "a\\b\nc", "md5"},
+		{"SHA1 (x (y).txt) = a9993e364706816aba3e25717850c26c9cd0d89d", "x (y).txt", "sha1"},
+		{`{"path":"j.txt","algorithm":"crc32","hash":"352441c2"}`, "j.txt", "crc32"},
+	} {
+		e, err := hashing.ParseManifestLine(tc.line, md5)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.line, err)
+		}
+		if e.Path != tc.path || e.Algorithm.Name != tc.alg {
+			t.Fatalf("%q: got path %q, algorithm %s", tc.line, e.Path, e.Algorithm.Name)
+		}
+	}
+
+	// Хэш неверной длины, неизвестный тег и строка без пути не разбираются
+	for _, line := range []string{
+		"900150983cd24fb0  a.txt",
+		"MD4 (a.txt) = 900150983cd24fb0d6963f7d28e17f72",
+		"900150983cd24fb0d6963f7d28e17f72",
+		"garbage",
+	} {
+		if _, err := hashing.ParseManifestLine(line, md5); !errors.Is(err, hashing.ErrBadManifestLine) {
+			t.Fatalf("%q: expected ErrBadManifestLine, got %v", line, err)
+		}
+	}
+}
+
+func TestTreeHash(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// Эталонные значения посчитаны Python hashlib (blake2b с параметрами дерева) и по RFC 6962
+	for name, want := range map[string]string{
+		"blake2b": "0125575e4cd690f0bcdc1af59e3f0d4b9b27b49dcb0817f89112ed73c6eff08175db6918287e5fa7e7a943765ff2d41f2812828522333462cc6700e63c7bd4fc",
+		"sha256":  "e2a42c0f01be5915f70b7c715425b78f43634b65a57e3424976f4e82e4d72c3d",
+	} {
+		alg, _ := hashing.Lookup(name)
+		tree := hashing.NewTree(alg, 300)
+		opts := hashing.Options{BufferSize: 64, Budget: hashing.NewBudget(128)}
+
+		chunks := nodes.NewGenerator(tree.Chunks(hashing.Targets([]string{path}, sources.WalkOptions{})))
+		hasher := nodes.NewContextWorkerPool(func(ctx context.Context, c hashing.Chunk) (hashing.ChunkSum, error) {
+			return tree.HashChunk(ctx, c, opts), nil
+		}, nodes.Config{Workers: 4})
+		combiner := hashing.NewTreeCombiner(tree)
+		results := sinks.Collect[hashing.FileHash]()
+		output := nodes.NewWriterAggregator(results)
+
+		if err := pipelines.Connect(chunks, hasher); err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		if err := pipelines.Connect(hasher, combiner); err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		if err := pipelines.Connect(combiner, output); err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		p := pipelines.New()
+		p.Add(chunks, hasher, combiner, output)
+		if err := p.Run(context.Background()); err != nil {
+			t.Fatalf("%s: Run failed: %v", name, err)
+		}
+
+		got := results.Items()
+		if len(got) != 1 || got[0].Err != nil || got[0].Size != 1000 {
+			t.Fatalf("%s: unexpected results %+v", name, got)
+		}
+		if sum := hex.EncodeToString(got[0].Sum); sum != want {
+			t.Fatalf("%s: got %s, want %s", name, sum, want)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := hashing.NewBudget(100)
+
+	n, err := b.Acquire(context.Background(), 1000)
+	if err != nil || n != 100 {
+		t.Fatalf("expected 100 bytes granted, got %d, %v", n, err)
+	}
+
+	// Пока бюджет исчерпан, Acquire ждёт и прерывается по контексту
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := b.Acquire(ctx, 10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	granted := make(chan int64)
+	go func() {
+		n, _ := b.Acquire(context.Background(), 60)
+		granted <- n
+	}()
+	b.Release(100)
+	if n := <-granted; n != 60 {
+		t.Fatalf("expected 60 bytes granted, got %d", n)
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data")
+	cachePath := filepath.Join(dir, "cache.jsonl")
+	md5, _ := hashing.Lookup("md5")
+
+	// Время изменения в прошлом: свежие файлы не кэшируются (их можно изменить в ту же секунду)
+	write := func(content string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+	hashOnce := func(opts hashing.CacheOptions) (string, hashing.CacheStats) {
+		t.Helper()
+		c, err := hashing.OpenCache(cachePath, opts)
+		if err != nil {
+			t.Fatalf("OpenCache failed: %v", err)
+		}
+		fh := c.HashFile(context.Background(), path, md5)
+		if fh.Err != nil {
+			t.Fatalf("HashFile failed: %v", fh.Err)
+		}
+		if err := c.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		return hex.EncodeToString(fh.Sum), c.Stats()
+	}
+
+	hour := time.Now().Add(-time.Hour)
+	write("abc", hour)
+	if sum, st := hashOnce(hashing.CacheOptions{}); sum != "900150983cd24fb0d6963f7d28e17f72" || st.Misses != 1 {
+		t.Fatalf("first run: %s, %+v", sum, st)
+	}
+	if _, st := hashOnce(hashing.CacheOptions{}); st.Hits != 1 {
+		t.Fatalf("expected a hit for an unchanged file, got %+v", st)
+	}
+	if _, st := hashOnce(hashing.CacheOptions{Rehash: true}); st.Misses != 1 {
+		t.Fatalf("expected a miss with Rehash, got %+v", st)
+	}
+
+	// Тот же размер, другое время изменения — хеш пересчитывается
+	write("abd", hour.Add(time.Minute))
+	if sum, st := hashOnce(hashing.CacheOptions{}); sum != "4911e516e5aa21d327512e0c8b197616" || st.Misses != 1 {
+		t.Fatalf("changed file: %s, %+v", sum, st)
+	}
+}
+
+// archive собирает zip, tar или tar.gz в памяти из пар имя, содержимое.
+func archive(t *testing.T, kind string, files ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if kind == "zip" {
+		zw := zip.NewWriter(&buf)
+		for i := 0; i < len(files); i += 2 {
+			w, err := zw.Create(files[i])
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+			w.Write([]byte(files[i+1]))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	var w io.WriteCloser = nopWriteCloser{&buf}
+	if kind == "tgz" {
+		w = gzip.NewWriter(&buf)
+	}
+	tw := tar.NewWriter(w)
+	for i := 0; i < len(files); i += 2 {
+		hdr := &tar.Header{Name: files[i], Mode: 0o644, Size: int64(len(files[i+1])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+		tw.Write([]byte(files[i+1]))
+	}
+	if err := errors.Join(tw.Close(), w.Close()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestExpandArchives(t *testing.T) {
+	deep := archive(t, "zip", "c.txt", "c")
+	inner := archive(t, "tgz", "b.txt", "b", "deep.zip", string(deep))
+	outer := archive(t, "zip", "dir/a.txt", "abc", "inner.tar.gz", string(inner))
+	plain := archive(t, "tar", "x", "x", "y", "y", "z", "z")
+
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{"outer.zip": outer, "plain.tar": plain, "file.txt": []byte("abc")} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	md5, _ := hashing.Lookup("md5")
+	run := func(depth int) map[string]string {
+		targets := hashing.ExpandArchives(hashing.Targets([]string{dir}, sources.WalkOptions{}), hashing.ArchiveOptions{MaxDepth: depth})
+		gen := nodes.NewGenerator(targets)
+		// Несколько воркеров: члены tar отдаются по одному, zip читаются параллельно
+		hasher := nodes.NewContextWorkerPool(func(ctx context.Context, tg hashing.Target) (hashing.FileHash, error) {
+			return hashing.HashTarget(ctx, tg, md5), nil
+		}, nodes.Config{Workers: 4})
+		results := sinks.Collect[hashing.FileHash]()
+		output := nodes.NewWriterAggregator(results)
+
+		if err := pipelines.Connect(gen, hasher); err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		if err := pipelines.Connect(hasher, output); err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		p := pipelines.New()
+		p.Add(gen, hasher, output)
+		if err := p.Run(context.Background()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		sums := make(map[string]string)
+		for _, fh := range results.Items() {
+			rel, _ := filepath.Rel(dir, fh.Path)
+			if fh.Err != nil {
+				sums[rel] = "error"
+				continue
+			}
+			sums[rel] = hex.EncodeToString(fh.Sum)
+		}
+		return sums
+	}
+
+	got := run(0)
+	for rel, want := range map[string]string{
+		"file.txt":                                 "900150983cd24fb0d6963f7d28e17f72",
+		"outer.zip!/dir/a.txt":                     "900150983cd24fb0d6963f7d28e17f72",
+		"outer.zip!/inner.tar.gz!/b.txt":           "92eb5ffee6ae2fec3ad71c777531578f",
+		"outer.zip!/inner.tar.gz!/deep.zip!/c.txt": "4a8a08f09d37b73795649038408b5f33",
+		"plain.tar!/y":                             "415290769594460e2e485922904f345d",
+	} {
+		if got[rel] != want {
+			t.Errorf("%s: got %q, want %s", rel, got[rel], want)
+		}
+	}
+	// 3 файла на диске, 2 члена outer.zip, 2 члена inner.tar.gz, 1 член deep.zip, 3 члена plain.tar
+	if len(got) != 11 {
+		t.Errorf("got %d results: %v", len(got), got)
+	}
+
+	// С глубиной 2 вложенный deep.zip хешируется, но не раскрывается
+	got = run(2)
+	if _, ok := got["outer.zip!/inner.tar.gz!/deep.zip"]; !ok {
+		t.Errorf("deep.zip not hashed: %v", got)
+	}
+	if _, ok := got["outer.zip!/inner.tar.gz!/deep.zip!/c.txt"]; ok {
+		t.Errorf("deep.zip expanded beyond MaxDepth: %v", got)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.log", "sub/c.txt", "skip/d.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	missing := filepath.Join(dir, "missing")
+	explicit := filepath.Join(dir, "b.log")
+
+	gen := nodes.NewGenerator(hashing.Targets([]string{dir, explicit, missing, hashing.StdinPath},
+		sources.WalkOptions{Include: []string{"*.txt"}, Exclude: []string{"skip"}}))
+	results := sinks.Collect[hashing.Target]()
+	output := nodes.NewWriterAggregator(results)
+	if err := pipelines.Connect(gen, output); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	p := pipelines.New()
+	p.Add(gen, output)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Явно указанный файл не фильтруется, отсутствующий корень приходит ошибкой, а обход продолжается
+	got := make(map[string]bool)
+	for _, tg := range results.Items() {
+		got[tg.Path] = tg.Err != nil
+	}
+	want := map[string]bool{
+		filepath.Join(dir, "a.txt"):     false,
+		filepath.Join(dir, "sub/c.txt"): false,
+		explicit:                        false,
+		missing:                         true,
+		hashing.StdinPath:               false,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for path, failed := range want {
+		if f, ok := got[path]; !ok || f != failed {
+			t.Errorf("%s: got error %v (present %v), want error %v", path, f, ok, failed)
+		}
+	}
+}
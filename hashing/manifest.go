@@ -0,0 +1,208 @@
+package hashing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// ErrBadManifestLine is the error of an Entry whose manifest line could not be parsed.
+var ErrBadManifestLine = errors.New("improperly formatted checksum line")
+
+// Entry is one line of a checksum manifest: the expected hash of a file.
+// Err is set for lines that could not be parsed and for unreadable manifests.
+type Entry struct {
+	Manifest string
+	Line     int
+
+	Path      string
+	Algorithm Algorithm
+	Sum       []byte
+
+	Err error
+}
+
+// Manifests returns a Generator of the entries of checksum manifests; StdinPath reads
+// standard input. Each line may be in any Format: GNU lines use alg, BSD and JSON Lines
+// name their algorithm. Blank lines and lines starting with '#' are skipped.
+func Manifests(paths []string, alg Algorithm) nodes.Generator[Entry] {
+	return func(ctx context.Context) (<-chan Entry, error) {
+		return nodes.Produce(ctx, func(emit func(Entry) bool) error {
+			for _, path := range paths {
+				if !readManifest(path, alg, emit) {
+					return nil
+				}
+			}
+			return nil
+		}), nil
+	}
+}
+
+func readManifest(path string, alg Algorithm, emit func(Entry) bool) bool {
+	var r io.Reader = os.Stdin
+	if path != StdinPath {
+		f, err := os.Open(path)
+		if err != nil {
+			return emit(Entry{Manifest: path, Err: err})
+		}
+		defer f.Close()
+		r = f
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSuffix(sc.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		e, err := ParseManifestLine(text, alg)
+		e.Manifest, e.Line, e.Err = path, line, err
+		if !emit(e) {
+			return false
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return emit(Entry{Manifest: path, Err: err})
+	}
+	return true
+}
+
+// ParseManifestLine parses one line written in any Format. GNU lines use alg.
+func ParseManifestLine(line string, alg Algorithm) (Entry, error) {
+	if strings.HasPrefix(line, "{") {
+		return parseJSONLine(line)
+	}
+
+	// a leading backslash marks an escaped path
+	escaped := strings.HasPrefix(line, `\`)
+	if escaped {
+		line = line[1:]
+	}
+
+	var e Entry
+	if sum, path, ok := strings.Cut(line, " "); ok && isHex(sum) {
+		// GNU: hex, then two spaces or " *" for binary mode, then path
+		if len(path) < 2 || path[0] != ' ' && path[0] != '*' {
+			return e, ErrBadManifestLine
+		}
+		e.Algorithm, e.Path = alg, path[1:]
+		line = sum
+	} else {
+		// BSD: TAG (path) = hex
+		tag, rest, ok := strings.Cut(line, " (")
+		i := strings.LastIndex(rest, ") = ")
+		if !ok || i < 0 {
+			return e, ErrBadManifestLine
+		}
+		a, found := lookupTag(tag)
+		if !found {
+			return e, fmt.Errorf("%w: unknown algorithm %q", ErrBadManifestLine, tag)
+		}
+		e.Algorithm, e.Path = a, rest[:i]
+		line = rest[i+len(") = "):]
+	}
+
+	if escaped {
+		e.Path = unescapePath(e.Path)
+	}
+	sum, err := hex.DecodeString(line)
+	if err != nil || len(sum) != e.Algorithm.New().Size() || e.Path == "" {
+		return e, ErrBadManifestLine
+	}
+	e.Sum = sum
+	return e, nil
+}
+
+func parseJSONLine(line string) (Entry, error) {
+	var (
+		e   Entry
+		rec jsonRecord
+	)
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return e, ErrBadManifestLine
+	}
+	a, ok := Lookup(rec.Algorithm)
+	if !ok {
+		return e, fmt.Errorf("%w: unknown algorithm %q", ErrBadManifestLine, rec.Algorithm)
+	}
+	sum, err := hex.DecodeString(rec.Hash)
+	if err != nil || len(sum) != a.New().Size() || rec.Path == "" {
+		return e, ErrBadManifestLine
+	}
+	return Entry{Path: rec.Path, Algorithm: a, Sum: sum}, nil
+}
+
+func lookupTag(tag string) (Algorithm, bool) {
+	for _, a := range algorithms {
+		if a.Tag == tag {
+			return a, true
+		}
+	}
+	return Lookup(tag)
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && s != ""
+}
+
+var pathUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+
+func unescapePath(path string) string {
+	return pathUnescaper.Replace(path)
+}
+
+// Status is the outcome of verifying an Entry.
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusFailed
+	StatusMissing
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "OK"
+	case StatusMissing:
+		return "MISSING"
+	default:
+		return "FAILED"
+	}
+}
+
+// Verification is the result of re-hashing the file of an Entry. Err is set when the
+// file could not be read, in which case Status is StatusMissing or StatusFailed.
+type Verification struct {
+	Entry  Entry
+	Status Status
+	Err    error
+}
+
+// Verify hashes the file of e and compares it with the expected sum.
+func Verify(ctx context.Context, e Entry, opts ...Options) Verification {
+	fh := HashFile(ctx, e.Path, e.Algorithm, opts...)
+	switch {
+	case errors.Is(fh.Err, fs.ErrNotExist):
+		return Verification{Entry: e, Status: StatusMissing, Err: fh.Err}
+	case fh.Err != nil:
+		return Verification{Entry: e, Status: StatusFailed, Err: fh.Err}
+	case !bytes.Equal(fh.Sum, e.Sum):
+		return Verification{Entry: e, Status: StatusFailed}
+	default:
+		return Verification{Entry: e, Status: StatusOK}
+	}
+}
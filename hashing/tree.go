@@ -0,0 +1,325 @@
+package hashing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
+)
+
+var _ pipelines.Node[ChunkSum, FileHash] = &treeCombiner{}
+
+// DefaultChunkSize is the chunk size used when NewTree is given a non-positive size.
+const DefaultChunkSize = 64 << 20
+
+// ErrNotSplittable is the error of chunks of standard input and of archive members,
+// which cannot be read by ranges.
+var ErrNotSplittable = errors.New("file cannot be split into chunks")
+
+// Tree describes chunk-parallel hashing: files are split into chunks of ChunkSize bytes,
+// the chunks are hashed independently, and the chunk digests are combined into the digest
+// of the file. The result is a tree hash, which differs from the sequential hash of the
+// same algorithm and depends on ChunkSize.
+type Tree struct {
+	// Algorithm names the tree digest after the chunk algorithm, e.g. "sha256-tree".
+	Algorithm Algorithm
+	ChunkSize int64
+
+	leaf func(index, count int) hash.Hash
+	root func(leaves [][]byte) []byte
+}
+
+// NewTree returns the Tree of alg. BLAKE2b uses its own tree hashing mode with unlimited
+// fanout and depth 2 (the chunks are the leaves, the root hashes their digests). Other
+// algorithms use a binary Merkle tree as in RFC 6962: a leaf is the hash of 0x00 and the chunk,
+// an inner node the hash of 0x01 and its children.
+func NewTree(alg Algorithm, chunkSize int64) Tree {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	t := Tree{
+		Algorithm: Algorithm{Name: alg.Name + "-tree", Tag: alg.Tag + "-TREE"},
+		ChunkSize: chunkSize,
+	}
+
+	if alg.Name == "blake2b" {
+		// leaf length 0 means unlimited, for chunks that do not fit the 32-bit field
+		var leafLength uint32
+		if chunkSize <= math.MaxUint32 {
+			leafLength = uint32(chunkSize)
+		}
+		params := blake2bParams{depth: 2, leafLength: leafLength, innerLength: blake2bSize512}
+
+		t.leaf = func(index, count int) hash.Hash {
+			p := params
+			p.nodeOffset = uint64(index)
+			p.lastNode = index == count-1
+			return newBLAKE2bNode(p)
+		}
+		t.root = func(leaves [][]byte) []byte {
+			p := params
+			p.nodeDepth = 1
+			p.lastNode = true
+			h := newBLAKE2bNode(p)
+			for _, l := range leaves {
+				h.Write(l)
+			}
+			return h.Sum(nil)
+		}
+		return t
+	}
+
+	t.leaf = func(int, int) hash.Hash {
+		h := alg.New()
+		h.Write([]byte{0x00})
+		return h
+	}
+	t.root = func(leaves [][]byte) []byte {
+		return merkleRoot(alg, leaves)
+	}
+	return t
+}
+
+// merkleRoot combines leaf digests as RFC 6962 does: the left subtree holds the largest
+// power of two smaller than the number of leaves.
+func merkleRoot(alg Algorithm, leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := 1
+	for k*2 < len(leaves) {
+		k *= 2
+	}
+
+	h := alg.New()
+	h.Write([]byte{0x01})
+	h.Write(merkleRoot(alg, leaves[:k]))
+	h.Write(merkleRoot(alg, leaves[k:]))
+	return h.Sum(nil)
+}
+
+// Chunk is a byte range of a file. Every file has at least one chunk, even when empty.
+// Err is set for files that cannot be hashed; such files have a single chunk.
+type Chunk struct {
+	Path   string
+	Index  int
+	Count  int
+	Offset int64
+	Length int64
+	// Size is the size of the whole file.
+	Size int64
+	Err  error
+}
+
+// ChunkSum is the leaf digest of a Chunk, or the error that prevented computing it.
+type ChunkSum struct {
+	Chunk Chunk
+	Sum   []byte
+	Err   error
+}
+
+// Chunks returns a Generator that splits the files emitted by targets into chunks.
+// Targets with errors, standard input and files that cannot be inspected are emitted
+// as a single Chunk with Err set.
+func (t Tree) Chunks(targets nodes.Generator[Target]) nodes.Generator[Chunk] {
+	return func(ctx context.Context) (<-chan Chunk, error) {
+		in, err := targets(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return nodes.Produce(ctx, func(emit func(Chunk) bool) error {
+			for target := range in {
+				for _, c := range t.split(target) {
+					if !emit(c) {
+						return nil
+					}
+				}
+			}
+			return nil
+		}), nil
+	}
+}
+
+func (t Tree) split(target Target) []Chunk {
+	fail := func(err error) []Chunk {
+		return []Chunk{{Path: target.Path, Count: 1, Err: err}}
+	}
+	switch {
+	case target.Err != nil:
+		return fail(target.Err)
+	case target.Path == StdinPath || target.Open != nil:
+		return fail(ErrNotSplittable)
+	}
+
+	info, err := os.Stat(target.Path)
+	if err != nil {
+		return fail(err)
+	}
+
+	size := info.Size()
+	count := int(max((size+t.ChunkSize-1)/t.ChunkSize, 1))
+	chunks := make([]Chunk, count)
+	for i := range chunks {
+		offset := int64(i) * t.ChunkSize
+		chunks[i] = Chunk{
+			Path:   target.Path,
+			Index:  i,
+			Count:  count,
+			Offset: offset,
+			Length: min(t.ChunkSize, size-offset),
+			Size:   size,
+		}
+	}
+	return chunks
+}
+
+// HashChunk computes the leaf digest of c, reading only its byte range.
+func (t Tree) HashChunk(ctx context.Context, c Chunk, opts ...Options) ChunkSum {
+	if c.Err != nil {
+		return ChunkSum{Chunk: c, Err: c.Err}
+	}
+
+	f, err := os.Open(c.Path)
+	if err != nil {
+		return ChunkSum{Chunk: c, Err: err}
+	}
+	defer f.Close()
+
+	h := t.leaf(c.Index, c.Count)
+	n, err := copyBuffered(ctx, h, io.NewSectionReader(f, c.Offset, c.Length), optionsOf(opts))
+	if err == nil && n != c.Length {
+		// the file shrank after it was split
+		err = fmt.Errorf("%s: %w", c.Path, io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return ChunkSum{Chunk: c, Err: err}
+	}
+	return ChunkSum{Chunk: c, Sum: h.Sum(nil)}
+}
+
+var combinerCounter atomic.Uint64
+
+type treeCombiner struct {
+	id uint64
+
+	in   []<-chan ChunkSum
+	out  []chan<- FileHash
+	tree Tree
+
+	config nodes.Config
+}
+
+// pendingFile collects the chunk digests of one file until all of them have arrived.
+type pendingFile struct {
+	leaves [][]byte
+	got    int
+	failed bool
+}
+
+// NewTreeCombiner creates a node that combines the chunk digests of each file into a FileHash
+// once all chunks of the file have arrived, in any order. The first chunk error of a file is
+// emitted as its FileHash at once, and the remaining chunks of the file are dropped. Like
+// nodes.NewNode it fans in all inputs and broadcasts to every output, each created with
+// buffer size cfg.Buffer.
+func NewTreeCombiner(t Tree, cfg ...nodes.Config) pipelines.Node[ChunkSum, FileHash] {
+	config := nodes.DefaultConfig()
+	if len(cfg) > 0 {
+		config = cfg[0]
+	}
+
+	return &treeCombiner{
+		id:     combinerCounter.Add(1),
+		tree:   t,
+		config: config,
+	}
+}
+
+func (n *treeCombiner) ID() string {
+	return fmt.Sprintf("tree-combiner-node-%d", n.id)
+}
+
+func (n *treeCombiner) SetInput(in ...<-chan ChunkSum) error {
+	n.in = append(n.in, in...)
+	return nil
+}
+
+func (n *treeCombiner) Output() (chan FileHash, error) {
+	out := make(chan FileHash, n.config.Buffer)
+	n.out = append(n.out, out)
+	return out, nil
+}
+
+func (n *treeCombiner) Run(ctx context.Context) error {
+	input, err := utils.FanIn(ctx, n.in, n.config.InBuffer)
+	if err != nil {
+		return err
+	}
+	defer utils.CloseChannels(n.out)
+
+	pending := make(map[string]*pendingFile)
+	for {
+		select {
+		case cs, open := <-input:
+			if !open {
+				if len(pending) > 0 && ctx.Err() == nil {
+					return fmt.Errorf("%s: input closed with chunks missing for %s",
+						n.ID(), strings.Join(slices.Sorted(maps.Keys(pending)), ", "))
+				}
+				return ctx.Err()
+			}
+
+			fh, done := n.add(pending, cs)
+			if !done {
+				continue
+			}
+			if err := utils.Broadcast(ctx, n.out, fh); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// add records cs and returns the FileHash of its file once it is known.
+func (n *treeCombiner) add(pending map[string]*pendingFile, cs ChunkSum) (FileHash, bool) {
+	c := cs.Chunk
+	p, ok := pending[c.Path]
+	if !ok {
+		p = &pendingFile{leaves: make([][]byte, c.Count)}
+		pending[c.Path] = p
+	}
+	p.got++
+	if p.got == c.Count {
+		delete(pending, c.Path)
+	}
+
+	fh := FileHash{Path: c.Path, Algorithm: n.tree.Algorithm, Size: c.Size}
+	switch {
+	case p.failed:
+		return fh, false
+	case cs.Err != nil:
+		p.failed = true
+		fh.Err = cs.Err
+		return fh, true
+	}
+
+	p.leaves[c.Index] = cs.Sum
+	if p.got < c.Count {
+		return fh, false
+	}
+	fh.Sum = n.tree.root(p.leaves)
+	return fh, true
+}
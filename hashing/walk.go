@@ -0,0 +1,59 @@
+package hashing
+
+import (
+	"context"
+	"io"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+// Target is a file to hash, or an error found while looking for files.
+type Target struct {
+	Path string
+	// Open, if set, reads a file that is not on disk, such as an archive member, whose Path
+	// is then virtual. See ExpandArchives for when it must be called.
+	Open func() (io.ReadCloser, error)
+	Err  error
+}
+
+// Targets returns a Generator of the regular files under roots, walked with sources.ParallelWalk,
+// so files arrive in no particular order. A root may be a file, a directory or StdinPath.
+// Unreadable roots and directories are emitted as Targets with Err set, and the walk goes on.
+// Include and Exclude of opts filter files as in sources.Walk; opts.SkipErrors drops error Targets.
+func Targets(roots []string, opts sources.WalkOptions) nodes.Generator[Target] {
+	var paths []string
+	stdin := 0
+	for _, root := range roots {
+		if root == StdinPath {
+			stdin++
+		} else {
+			paths = append(paths, root)
+		}
+	}
+	walk := sources.ParallelWalk(paths, sources.ParallelWalkOptions{WalkOptions: opts})
+
+	return func(ctx context.Context) (<-chan Target, error) {
+		return nodes.Produce(ctx, func(emit func(Target) bool) error {
+			for range stdin {
+				if !emit(Target{Path: StdinPath}) {
+					return nil
+				}
+			}
+			if len(paths) == 0 {
+				return nil
+			}
+
+			entries, err := walk(ctx)
+			if err != nil {
+				return err
+			}
+			for e := range entries {
+				if !emit(Target{Path: e.Path, Err: e.Err}) {
+					return nil
+				}
+			}
+			return nil
+		}), nil
+	}
+}
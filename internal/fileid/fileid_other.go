@@ -0,0 +1,12 @@
+//go:build !unix
+
+// Package fileid identifies files by device and inode numbers where the platform has them.
+package fileid
+
+import "os"
+
+// Of returns zeros: inode numbers are not available on this platform, so callers fall back
+// to other ways of telling files apart, such as os.SameFile.
+func Of(os.FileInfo) (dev, ino uint64) {
+	return 0, 0
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+// Package fileid identifies files by device and inode numbers where the platform has them.
+package fileid
+
+import (
+	"os"
+	"syscall"
+)
+
+// Of returns the device and inode numbers of a file.
+func Of(info os.FileInfo) (dev, ino uint64) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), uint64(st.Ino)
+	}
+	return 0, 0
+}
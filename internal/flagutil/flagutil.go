@@ -0,0 +1,40 @@
+// Package flagutil provides flag.Value types shared by the commands in cmd.
+package flagutil
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// StringList is a repeatable string flag.
+type StringList []string
+
+func (l *StringList) String() string { return strings.Join(*l, ",") }
+
+func (l *StringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// Size is a byte size flag accepting K, M and G suffixes (powers of 1024).
+type Size int64
+
+func (s *Size) String() string { return strconv.FormatInt(int64(*s), 10) }
+
+func (s *Size) Set(v string) error {
+	mult := int64(1)
+	if v != "" {
+		if i := strings.IndexByte("KMG", v[len(v)-1]&^0x20); i >= 0 {
+			mult = 1 << (10 * (i + 1))
+			v = v[:len(v)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return errors.New("invalid size")
+	}
+	*s = Size(n * mult)
+	return nil
+}
@@ -0,0 +1,57 @@
+package flagutil_test
+
+import (
+	"flag"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/Sergey-Polishchenko/pipelines/internal/flagutil"
+)
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"512", 512, true},
+		{"4k", 4 << 10, true},
+		{"64M", 64 << 20, true},
+		{"2G", 2 << 30, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"1T", 0, false},
+		{"K", 0, false},
+	}
+
+	for _, tt := range tests {
+		var s flagutil.Size
+		err := s.Set(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(%q): got error %v", tt.in, err)
+			continue
+		}
+		if tt.ok && int64(s) != tt.want {
+			t.Errorf("Set(%q) = %d, want %d", tt.in, s, tt.want)
+		}
+	}
+}
+
+func TestStringList(t *testing.T) {
+	// Флаг можно повторять, значения накапливаются по порядку
+	var l flagutil.StringList
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&l, "include", "")
+	if err := fs.Parse([]string{"-include", "*.go", "-include", "*.md"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if want := []string{"*.go", "*.md"}; !slices.Equal(l, want) {
+		t.Fatalf("got %v, want %v", l, want)
+	}
+	if got := l.String(); got != "*.go,*.md" {
+		t.Errorf("String() = %q", got)
+	}
+}
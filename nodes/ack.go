@@ -0,0 +1,255 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
+)
+
+// AckResult is the final outcome of an acknowledged item across all downstream paths.
+type AckResult int
+
+const (
+	// AckDropped means every path discarded the item on purpose (e.g. a dedup node).
+	AckDropped AckResult = iota
+	// AckSunk means at least one path consumed the item and none failed.
+	AckSunk
+	// AckFailed means at least one path reported an error with Nack.
+	AckFailed
+)
+
+func (r AckResult) String() string {
+	switch r {
+	case AckDropped:
+		return "dropped"
+	case AckSunk:
+		return "sunk"
+	case AckFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Message wraps an item together with an acknowledgement handle. The handle is shared by all
+// copies of the Message and reference-counted: a node retains it once per extra output, and
+// every path must finish with exactly one of Ack, Drop or Nack. Copies a node could not send
+// because the run was canceled are Nack'ed with the context error. When the last path finishes,
+// the callback given to NewMessage is called once with the combined result.
+//
+// A zero Message has no handle; its Ack, Drop and Nack methods do nothing.
+type Message[T any] struct {
+	Value T
+
+	handle *ackHandle
+}
+
+type ackHandle struct {
+	mu     sync.Mutex
+	refs   int
+	result AckResult
+	errs   []error
+	done   func(AckResult, error)
+}
+
+// NewMessage wraps v into a Message whose done callback is called once every
+// downstream path has finished with it. done may be nil.
+func NewMessage[T any](v T, done func(AckResult, error)) Message[T] {
+	return Message[T]{
+		Value:  v,
+		handle: &ackHandle{refs: 1, done: done},
+	}
+}
+
+// WithValue returns a Message carrying v and the same acknowledgement handle as m.
+// Processors use it to pass the handle along with their result.
+func WithValue[In, Out any](m Message[In], v Out) Message[Out] {
+	return Message[Out]{Value: v, handle: m.handle}
+}
+
+// Retain registers n more paths that will each finish the Message.
+func (m Message[T]) Retain(n int) {
+	if m.handle == nil || n <= 0 {
+		return
+	}
+	m.handle.mu.Lock()
+	m.handle.refs += n
+	m.handle.mu.Unlock()
+}
+
+// Ack finishes one path with the item successfully consumed.
+func (m Message[T]) Ack() {
+	m.finish(AckSunk, nil)
+}
+
+// Drop finishes one path with the item intentionally discarded.
+func (m Message[T]) Drop() {
+	m.finish(AckDropped, nil)
+}
+
+// Nack finishes one path with the item failed because of err.
+func (m Message[T]) Nack(err error) {
+	m.finish(AckFailed, err)
+}
+
+func (m Message[T]) finish(res AckResult, err error) {
+	m.handle.finish(res, err)
+}
+
+func (h *ackHandle) finish(res AckResult, err error) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	if h.refs <= 0 {
+		h.mu.Unlock()
+		return
+	}
+	h.refs--
+	h.result = max(h.result, res)
+	if err != nil {
+		h.errs = append(h.errs, err)
+	}
+	last := h.refs == 0
+	h.mu.Unlock()
+
+	if last && h.done != nil {
+		h.done(h.result, errors.Join(h.errs...))
+	}
+}
+
+// dropper is implemented by items that must be told when a node discards them.
+type dropper interface {
+	Drop()
+}
+
+// nacker is implemented by items that must be told when a canceled run loses them.
+type nacker interface {
+	Nack(err error)
+}
+
+// retainer is implemented by items that count the outputs holding them.
+type retainer interface {
+	Retain(n int)
+	nacker
+}
+
+// broadcast is utils.Broadcast for items that may be Messages: the handle is retained once per
+// extra output, and the copies left unsent when ctx is canceled are Nack'ed, so the source still
+// hears about the item exactly once. With no outputs the item is dropped.
+func broadcast[T any](ctx context.Context, outs []chan<- T, data T) error {
+	r, ok := any(data).(retainer)
+	if !ok {
+		return utils.Broadcast(ctx, outs, data)
+	}
+	if len(outs) == 0 {
+		if d, ok := r.(dropper); ok {
+			d.Drop()
+		}
+		return nil
+	}
+
+	r.Retain(len(outs) - 1)
+	for i, out := range outs {
+		select {
+		case out <- data:
+		case <-ctx.Done():
+			for range outs[i:] {
+				r.Nack(ctx.Err())
+			}
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// nack tells v, if it is a Message, that it was lost because of err.
+func nack(v any, err error) {
+	if m, ok := v.(nacker); ok {
+		m.Nack(err)
+	}
+}
+
+// ackCarrier is implemented by Message. Its handle cannot be encoded, so nodes that serialize
+// items, such as the spill buffer, keep it aside and reattach it to the decoded item.
+type ackCarrier interface {
+	ackRef() *ackHandle
+	withAckRef(h *ackHandle) any
+}
+
+func (m Message[T]) ackRef() *ackHandle { return m.handle }
+
+func (m Message[T]) withAckRef(h *ackHandle) any {
+	m.handle = h
+	return m
+}
+
+// AckGenerator wraps gen so that every item is sent as a Message. done, if not nil, is called for
+// each item once all downstream paths have finished with it, which lets the source delete input
+// files or commit offsets only after successful processing. An item a node was holding when the
+// pipeline was canceled is reported as AckFailed with the context error; items still queued in
+// channels between nodes at that moment are not reported.
+func AckGenerator[T any](gen Generator[T], done func(item T, res AckResult, err error)) Generator[Message[T]] {
+	return func(ctx context.Context) (<-chan Message[T], error) {
+		src, err := gen(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make(chan Message[T])
+		go func() {
+			defer close(out)
+			for v := range src {
+				var finished func(AckResult, error)
+				if done != nil {
+					finished = func(res AckResult, err error) { done(v, res, err) }
+				}
+				msg := NewMessage(v, finished)
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+					msg.Nack(ctx.Err())
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// AckProcessor lifts proc to Messages: the result keeps the handle of the input, and a failed
+// item is Nack'ed before the error is returned. If proc panics, the item is Nack'ed with a
+// *PanicError and the panic continues to the node.
+func AckProcessor[In, Out any](proc ContextProcessor[In, Out]) ContextProcessor[Message[In], Message[Out]] {
+	return func(ctx context.Context, m Message[In]) (Message[Out], error) {
+		defer func() {
+			if r := recover(); r != nil {
+				m.Nack(&PanicError{Value: r})
+				panic(r)
+			}
+		}()
+
+		res, err := proc(ctx, m.Value)
+		if err != nil {
+			m.Nack(err)
+			return Message[Out]{}, err
+		}
+		return WithValue(m, res), nil
+	}
+}
+
+// AckSink lifts sink to Messages: the item is Ack'ed after sink succeeds
+// and Nack'ed if it fails.
+func AckSink[In any](sink ContextSink[In]) ContextSink[Message[In]] {
+	return func(ctx context.Context, m Message[In]) error {
+		if err := sink(ctx, m.Value); err != nil {
+			m.Nack(err)
+			return err
+		}
+		m.Ack()
+		return nil
+	}
+}
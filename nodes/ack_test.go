@@ -0,0 +1,112 @@
+package nodes_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+type ackOutcome struct {
+	res nodes.AckResult
+	err error
+}
+
+// expectOutcome ждёт единственного вызова обработчика подтверждения.
+func expectOutcome(t *testing.T, results <-chan ackOutcome) ackOutcome {
+	t.Helper()
+	select {
+	case o := <-results:
+		return o
+	case <-time.After(5 * time.Second):
+		t.Fatalf("acknowledgement callback was not called")
+		return ackOutcome{}
+	}
+}
+
+func TestAckCancelNacksUnsentCopies(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	results := make(chan ackOutcome, 2)
+	gen := nodes.NewGenerator(nodes.AckGenerator(sources.Slice([]int{1, 2}), func(x int, res nodes.AckResult, err error) {
+		if x != 2 {
+			t.Errorf("callback called for item %d", x)
+		}
+		results <- ackOutcome{res, err}
+	}))
+
+	// Первый выход читается, второй нет: элемент 1 остаётся в его буфере,
+	// а отмена застаёт элемент 2 в рассылке
+	read, err := gen.Output()
+	if err != nil {
+		t.Fatalf("Output failed: %v", err)
+	}
+	if _, err := gen.Output(); err != nil {
+		t.Fatalf("Output failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errc := make(chan error, 1)
+	go func() { errc <- gen.Run(ctx) }()
+
+	for range 2 {
+		m := <-read
+		m.Ack()
+	}
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	o := expectOutcome(t, results)
+	if o.res != nodes.AckFailed || !errors.Is(o.err, context.Canceled) {
+		t.Fatalf("got %s (%v), want failed with context.Canceled", o.res, o.err)
+	}
+	select {
+	case o := <-results:
+		t.Fatalf("callback called twice, second time with %s", o.res)
+	default:
+	}
+}
+
+func TestAckProcessorPanic(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	results := make(chan ackOutcome, 1)
+	gen := nodes.NewGenerator(nodes.AckGenerator(sources.Slice([]int{1}), func(_ int, res nodes.AckResult, err error) {
+		results <- ackOutcome{res, err}
+	}))
+	node := nodes.NewContextNode(nodes.AckProcessor(func(context.Context, int) (int, error) {
+		panic("boom")
+	}))
+	got, sink := pipelinetest.Collect[nodes.Message[int]]()
+	pipelinetest.Connect(t, gen, node)
+	pipelinetest.Connect(t, node, sink)
+
+	// Паника процессора завершает ноду, а элемент получает Nack
+	var panicErr *nodes.PanicError
+	if err := pipelinetest.RunError(t, gen, node, sink); !errors.As(err, &panicErr) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+	o := expectOutcome(t, results)
+	if o.res != nodes.AckFailed || !errors.As(o.err, &panicErr) {
+		t.Fatalf("got %s (%v), want failed with *PanicError", o.res, o.err)
+	}
+	if n := len(got.Items()); n != 0 {
+		t.Errorf("%d items reached the sink", n)
+	}
+}
+
+func TestAckGeneratorWithoutCallback(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	// done не обязателен: подтверждения просто никуда не сообщаются
+	gen := nodes.NewGenerator(nodes.AckGenerator(sources.Slice([]int{1, 2}), nil))
+	sink := nodes.NewContextResultAggregator(nodes.AckSink(func(context.Context, int) error { return nil }))
+	pipelinetest.Connect(t, gen, sink)
+	pipelinetest.Run(t, gen, sink)
+}
@@ -2,19 +2,28 @@ package nodes
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/Sergey-Polishchenko/pipelines"
 	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
 )
 
-var _ pipelines.Node[any, any] = &aggregator[any]{}
+var (
+	_ pipelines.Node[any, any] = &aggregator[any]{}
+	_ StatsProvider            = &aggregator[any]{}
+)
 
 type aggregator[In any] struct {
 	id uint64
 
-	in   []<-chan In
-	sink Sink[In]
+	in    []<-chan In
+	sink  ContextSink[In]
+	close func() error
+
+	processed atomic.Uint64
 
 	config Config
 }
@@ -23,6 +32,12 @@ type aggregator[In any] struct {
 // to each element. The node has no output channels; once all inputs are closed, Run returns. cfg.InBuffer
 // controls the buffer size for the internal fan-in operation.
 func NewResultAggregator[In any](sink Sink[In], cfg ...Config) pipelines.Node[In, any] {
+	return NewContextResultAggregator(sink.withContext(), cfg...)
+}
+
+// NewContextResultAggregator is like NewResultAggregator, but the ContextSink receives a per-item
+// context that is canceled when the pipeline stops or cfg.Timeout elapses.
+func NewContextResultAggregator[In any](sink ContextSink[In], cfg ...Config) pipelines.Node[In, any] {
 	config := DefaultConfig()
 	if len(cfg) > 0 {
 		config = cfg[0]
@@ -35,6 +50,34 @@ func NewResultAggregator[In any](sink Sink[In], cfg ...Config) pipelines.Node[In
 	}
 }
 
+// SinkWriter is a stateful sink, such as a buffered file writer, that must be closed
+// once no more items will be written.
+type SinkWriter[In any] interface {
+	Write(ctx context.Context, in In) error
+	Close() error
+}
+
+// NewWriterAggregator is like NewContextResultAggregator, but consumes items with w.Write
+// and calls w.Close when Run returns, both on completion and on error. A Close error is
+// returned from Run together with the error that stopped it, if any.
+//
+// The node also implements io.Closer, so that w can be released if the node is never run,
+// e.g. because building the rest of the pipeline failed. w.Close is called at most once.
+func NewWriterAggregator[In any](w SinkWriter[In], cfg ...Config) pipelines.Node[In, any] {
+	n := NewContextResultAggregator(w.Write, cfg...).(*aggregator[In])
+	n.close = sync.OnceValue(w.Close)
+	return n
+}
+
+// Close closes the writer of a node created by NewWriterAggregator; for other aggregators it
+// does nothing.
+func (n *aggregator[In]) Close() error {
+	if n.close == nil {
+		return nil
+	}
+	return n.close()
+}
+
 func (n *aggregator[In]) ID() string {
 	return fmt.Sprintf("result-aggregator-node-%d", n.id)
 }
@@ -48,7 +91,20 @@ func (n *aggregator[In]) Output() (chan any, error) {
 	return nil, ErrHasNoOutput
 }
 
-func (n *aggregator[In]) Run(ctx context.Context) error {
+// Stats reports the consumed items; an aggregator has no outputs.
+func (n *aggregator[In]) Stats() Stats {
+	return Stats{Processed: n.processed.Load()}
+}
+
+func (n *aggregator[In]) Run(ctx context.Context) (err error) {
+	if n.close != nil {
+		defer func() {
+			if cerr := n.close(); cerr != nil {
+				err = errors.Join(err, fmt.Errorf("%s: close: %w", n.ID(), cerr))
+			}
+		}()
+	}
+
 	input, err := utils.FanIn(ctx, n.in, n.config.InBuffer)
 	if err != nil {
 		return err
@@ -60,8 +116,12 @@ func (n *aggregator[In]) Run(ctx context.Context) error {
 			if !open {
 				return nil
 			}
+			n.processed.Add(1)
 
-			if err := n.sink(data); err != nil {
+			_, err := call(ctx, n.ID(), n.config, func(ctx context.Context) (struct{}, error) {
+				return struct{}{}, n.sink(ctx, data)
+			})
+			if err != nil {
 				return fmt.Errorf("%s: %w", n.ID(), err)
 			}
 		case <-ctx.Done():
@@ -0,0 +1,54 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// call invokes fn with a per-item context derived from ctx. If cfg.Timeout is set and elapses
+// while the parent context is still alive, the result is discarded and ErrItemTimeout is returned.
+// fn is not abandoned on timeout: call returns only once fn does.
+// A panic in fn is converted into a *PanicError for node id unless cfg.RePanic is set.
+func call[Out any](
+	ctx context.Context,
+	id string,
+	cfg Config,
+	fn func(context.Context) (Out, error),
+) (res Out, err error) {
+	if !cfg.RePanic {
+		defer recoverPanic(id, &err)
+	}
+
+	if cfg.Timeout <= 0 {
+		return fn(ctx)
+	}
+
+	itemCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	res, err = fn(itemCtx)
+	if ctx.Err() == nil && errors.Is(itemCtx.Err(), context.DeadlineExceeded) {
+		var zero Out
+		return zero, fmt.Errorf("%w after %s", ErrItemTimeout, cfg.Timeout)
+	}
+	return res, err
+}
+
+// recoverPanic must be deferred directly. It stores a recovered panic into *err as a *PanicError.
+func recoverPanic(id string, err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{NodeID: id, Value: r, Stack: debug.Stack()}
+	}
+}
+
+// apply invokes a pure function of an item, such as a key extractor, converting a panic into a
+// *PanicError for node id unless cfg.RePanic is set. Unlike call it sets no per-item timeout,
+// which a function that cannot block has no use for.
+func apply[T, R any](id string, cfg Config, fn func(T) R, v T) (r R, err error) {
+	if !cfg.RePanic {
+		defer recoverPanic(id, &err)
+	}
+	return fn(v), nil
+}
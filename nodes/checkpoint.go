@@ -0,0 +1,226 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Offset is an opaque position inside a resumable source. A source passed an Offset
+// must continue right after the item the Offset was reported with.
+type Offset []byte
+
+// Positioned is an item of a resumable source together with its Offset.
+type Positioned[T any] struct {
+	Item   T
+	Offset Offset
+}
+
+// ResumableGenerator is a Generator that can start from a previously saved Offset.
+// from is nil on the first run.
+type ResumableGenerator[Out any] func(ctx context.Context, from Offset) (<-chan Positioned[Out], error)
+
+// CheckpointStore persists the last committed Offset of a source.
+// Load returns a nil Offset if nothing has been saved yet.
+type CheckpointStore interface {
+	Load() (Offset, error)
+	Save(Offset) error
+}
+
+type memoryCheckpointStore struct {
+	mu     sync.Mutex
+	offset Offset
+}
+
+// NewMemoryCheckpointStore returns a CheckpointStore that keeps the offset in memory.
+// It survives pipeline restarts within one process.
+func NewMemoryCheckpointStore() CheckpointStore {
+	return &memoryCheckpointStore{}
+}
+
+func (s *memoryCheckpointStore) Load() (Offset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset, nil
+}
+
+func (s *memoryCheckpointStore) Save(off Offset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = append(Offset(nil), off...)
+	return nil
+}
+
+type fileCheckpointStore struct {
+	path string
+}
+
+// NewFileCheckpointStore returns a CheckpointStore that keeps the offset in the file at path.
+// The file is replaced atomically on every Save.
+func NewFileCheckpointStore(path string) CheckpointStore {
+	return &fileCheckpointStore{path: path}
+}
+
+func (s *fileCheckpointStore) Load() (Offset, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *fileCheckpointStore) Save(off Offset) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(off); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// DefaultCheckpointInterval is the minimal time between two saves of a Checkpoint.
+const DefaultCheckpointInterval = time.Second
+
+// Checkpoint connects a resumable generator with the sink that finishes its items.
+// The generator tracks the Offset of every emitted item under its key; the sink reports
+// finished keys with Done. The Offset of the last item before which everything is done
+// is saved to the store at most once per checkpoint interval. Once the generator has exited, because
+// the source is exhausted or on an error or cancellation, the offset committed so far is saved at
+// once, and so is every later commit, since the pipeline may stop at any moment.
+//
+// A Checkpoint may serve several runs of the pipeline, e.g. with a memory store across restarts;
+// every run of the generator starts tracking afresh, so items left pending by a failed run,
+// which the next run replays, do not hold the checkpoint back. The interval is measured by the
+// Clock of the generator's Config.
+//
+// Every tracked item must eventually be reported with Done, including items dropped or
+// filtered out between the generator and the sink, otherwise the checkpoint stops advancing.
+type Checkpoint[K comparable] struct {
+	store    CheckpointStore
+	interval time.Duration
+
+	mu      sync.Mutex
+	clock   Clock
+	pending []*checkpointItem
+	byKey   map[K][]*checkpointItem
+	last    Offset
+	dirty   bool
+	saved   time.Time
+	stopped bool // the generator has exited
+}
+
+type checkpointItem struct {
+	offset Offset
+	done   bool
+}
+
+// NewCheckpoint creates a Checkpoint backed by store. An optional interval overrides
+// DefaultCheckpointInterval.
+func NewCheckpoint[K comparable](store CheckpointStore, interval ...time.Duration) *Checkpoint[K] {
+	cp := &Checkpoint[K]{
+		store:    store,
+		interval: DefaultCheckpointInterval,
+		clock:    systemClock{},
+		byKey:    make(map[K][]*checkpointItem),
+	}
+	if len(interval) > 0 {
+		cp.interval = interval[0]
+	}
+	return cp
+}
+
+// Done marks the oldest pending item with key k as fully processed.
+// Unknown keys are ignored. It returns the error of the store, if a save was attempted.
+func (c *Checkpoint[K]) Done(k K) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := c.byKey[k]
+	if len(items) == 0 {
+		return nil
+	}
+	items[0].done = true
+	if len(items) == 1 {
+		delete(c.byKey, k)
+	} else {
+		c.byKey[k] = items[1:]
+	}
+
+	for len(c.pending) > 0 && c.pending[0].done {
+		c.last = c.pending[0].offset
+		c.dirty = true
+		c.pending = c.pending[1:]
+	}
+	return c.saveLocked()
+}
+
+func (c *Checkpoint[K]) track(k K, off Offset) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := &checkpointItem{offset: off}
+	c.pending = append(c.pending, item)
+	c.byKey[k] = append(c.byKey[k], item)
+}
+
+// start forgets the items tracked by a previous run and measures the interval with clock.
+func (c *Checkpoint[K]) start(clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clock = clockOr(clock)
+	c.pending = nil
+	c.byKey = make(map[K][]*checkpointItem)
+	c.stopped = false
+}
+
+// stop records that the generator has exited and saves the offset committed so far.
+func (c *Checkpoint[K]) stop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopped = true
+	return c.saveLocked()
+}
+
+func (c *Checkpoint[K]) saveLocked() error {
+	if !c.dirty {
+		return nil
+	}
+	if !c.stopped && c.clock.Now().Sub(c.saved) < c.interval {
+		return nil
+	}
+
+	if err := c.store.Save(c.last); err != nil {
+		return err
+	}
+	c.dirty = false
+	c.saved = c.clock.Now()
+	return nil
+}
+
+// CheckpointSink wraps sink so that every successfully consumed item is reported to cp
+// under key(item).
+func CheckpointSink[In any, K comparable](cp *Checkpoint[K], key func(In) K, sink ContextSink[In]) ContextSink[In] {
+	return func(ctx context.Context, in In) error {
+		if err := sink(ctx, in); err != nil {
+			return err
+		}
+		return cp.Done(key(in))
+	}
+}
@@ -0,0 +1,206 @@
+package nodes_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+)
+
+// numbers выдаёт числа 0..n-1, смещение — следующее число в десятичной записи
+func numbers(n int) nodes.ResumableGenerator[int] {
+	return func(ctx context.Context, from nodes.Offset) (<-chan nodes.Positioned[int], error) {
+		start := 0
+		if from != nil {
+			var err error
+			if start, err = strconv.Atoi(string(from)); err != nil {
+				return nil, err
+			}
+		}
+
+		out := make(chan nodes.Positioned[int])
+		go func() {
+			defer close(out)
+			for i := start; i < n; i++ {
+				select {
+				case out <- nodes.Positioned[int]{Item: i, Offset: nodes.Offset(strconv.Itoa(i + 1))}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+func runNumbers(t *testing.T, cp *nodes.Checkpoint[int], sink nodes.Sink[int]) error {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	gen := nodes.NewResumableGenerator(numbers(10), cp, func(x int) int { return x })
+	pool := nodes.NewWorkerPool(func(x int) (int, error) { return x, nil }, nodes.Config{Workers: 3})
+	agg := nodes.NewContextResultAggregator(nodes.CheckpointSink(cp, func(x int) int { return x },
+		func(_ context.Context, x int) error { return sink(x) }))
+
+	if err := pipelines.Connect(gen, pool); err != nil {
+		t.Fatalf("Connect(gen, pool) failed: %v", err)
+	}
+	if err := pipelines.Connect(pool, agg); err != nil {
+		t.Fatalf("Connect(pool, agg) failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(gen, pool, agg)
+	return p.Run(ctx)
+}
+
+func TestResumableGeneratorResumes(t *testing.T) {
+	store := nodes.NewFileCheckpointStore(filepath.Join(t.TempDir(), "offset"))
+	errStop := errors.New("stop")
+
+	// 1. Первый запуск падает на элементе 5
+	var first []int
+	err := runNumbers(t, nodes.NewCheckpoint[int](store, 0), func(x int) error {
+		if x == 5 {
+			return errStop
+		}
+		first = append(first, x)
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected errStop, got %v", err)
+	}
+
+	off, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	saved, _ := strconv.Atoi(string(off))
+	if saved > 5 {
+		t.Fatalf("checkpoint %d is past the failed item", saved)
+	}
+
+	// 2. Второй запуск продолжает с сохранённого смещения
+	seen := map[int]bool{}
+	for _, x := range first {
+		seen[x] = true
+	}
+	err = runNumbers(t, nodes.NewCheckpoint[int](store, 0), func(x int) error {
+		if x < saved {
+			t.Errorf("item %d was processed again before offset %d", x, saved)
+		}
+		seen[x] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	for i := range 10 {
+		if !seen[i] {
+			t.Errorf("item %d was never processed", i)
+		}
+	}
+
+	if off, _ := store.Load(); string(off) != "10" {
+		t.Errorf("final checkpoint = %q, want 10", off)
+	}
+}
+
+// runDirect соединяет генератор прямо со стоком, чтобы элементы завершались строго по порядку.
+func runDirect(t *testing.T, cp *nodes.Checkpoint[int], sink nodes.Sink[int]) error {
+	t.Helper()
+
+	gen := nodes.NewResumableGenerator(numbers(10), cp, func(x int) int { return x })
+	agg := nodes.NewContextResultAggregator(nodes.CheckpointSink(cp, func(x int) int { return x },
+		func(_ context.Context, x int) error { return sink(x) }))
+	pipelinetest.Connect(t, gen, agg)
+	return pipelinetest.RunError(t, gen, agg)
+}
+
+func TestCheckpointFlushOnFailure(t *testing.T) {
+	store := nodes.NewMemoryCheckpointStore()
+	errStop := errors.New("stop")
+
+	// Интервал в час: без сохранения при остановке уцелел бы только первый коммит
+	cp := nodes.NewCheckpoint[int](store, time.Hour)
+	err := runDirect(t, cp, func(x int) error {
+		if x == 5 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected errStop, got %v", err)
+	}
+	if off, _ := store.Load(); string(off) != "5" {
+		t.Fatalf("checkpoint after failure = %q, want 5", off)
+	}
+
+	// Тот же Checkpoint во втором запуске: элементы, зависшие в первом, не держат его
+	var replayed []int
+	if err := runDirect(t, cp, func(x int) error {
+		replayed = append(replayed, x)
+		return nil
+	}); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	pipelinetest.Equal(t, replayed, []int{5, 6, 7, 8, 9})
+	if off, _ := store.Load(); string(off) != "10" {
+		t.Errorf("final checkpoint = %q, want 10", off)
+	}
+}
+
+func TestCheckpointIntervalUsesClock(t *testing.T) {
+	store := nodes.NewMemoryCheckpointStore()
+	clock := pipelinetest.NewFakeClock(time.Now())
+	cp := nodes.NewCheckpoint[int](store, time.Hour)
+
+	gen := nodes.NewResumableGenerator(numbers(10), cp, func(x int) int { return x }, nodes.Config{Clock: clock})
+	agg := nodes.NewContextResultAggregator(nodes.CheckpointSink(cp, func(x int) int { return x },
+		func(_ context.Context, x int) error {
+			switch x {
+			case 4:
+				// Интервал истёк по часам генератора: коммит элемента 4 сохраняется сразу
+				clock.Advance(time.Hour)
+			case 7:
+				if off, _ := store.Load(); string(off) != "5" {
+					t.Errorf("checkpoint before item 7 = %q, want 5", off)
+				}
+			}
+			return nil
+		}))
+	pipelinetest.Connect(t, gen, agg)
+	pipelinetest.Run(t, gen, agg)
+
+	if off, _ := store.Load(); string(off) != "10" {
+		t.Errorf("final checkpoint = %q, want 10", off)
+	}
+}
+
+func TestResumableGeneratorKeyPanic(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	cp := nodes.NewCheckpoint[int](nodes.NewMemoryCheckpointStore())
+	gen := nodes.NewResumableGenerator(numbers(10), cp, func(x int) int {
+		if x == 3 {
+			panic("bad key")
+		}
+		return x
+	})
+	_, sink := pipelinetest.Collect[int]()
+	pipelinetest.Connect(t, gen, sink)
+
+	// Паника функции ключа завершает ноду ошибкой, а не процесс
+	var panicErr *nodes.PanicError
+	if err := pipelinetest.RunError(t, gen, sink); !errors.As(err, &panicErr) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+}
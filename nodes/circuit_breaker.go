@@ -0,0 +1,347 @@
+package nodes
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through and records its outcome.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects calls until BreakerConfig.OpenTimeout elapses.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe call through to decide whether to close again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerMode selects what a CircuitBreaker does with items it does not let through.
+type BreakerMode int
+
+const (
+	// BreakerFailFast returns ErrCircuitOpen for rejected items and the original error for failed ones.
+	BreakerFailFast BreakerMode = iota
+	// BreakerFallback routes rejected and failed items to BreakerConfig.Fallback.
+	BreakerFallback
+	// BreakerPark blocks rejected items until a half-open probe succeeds and retries failed ones
+	// after BreakerConfig.RetryBackoff. It assumes failures are transient; an item still failing
+	// after BreakerConfig.MaxAttempts tries fails with its last error.
+	BreakerPark
+)
+
+// BreakerEvent describes a state transition of a CircuitBreaker.
+type BreakerEvent struct {
+	From BreakerState
+	To   BreakerState
+	At   time.Time
+}
+
+// BreakerConfig configures a CircuitBreaker.
+type BreakerConfig[In, Out any] struct {
+	// FailureRatio opens the breaker when failures/calls within Window reach it (default 0.5).
+	FailureRatio float64
+	// MinCalls is the number of calls within Window required before the ratio is evaluated (default 10).
+	MinCalls int
+	// Window is the length of the rolling window (default 10s).
+	Window time.Duration
+	// OpenTimeout is how long the breaker stays open before a half-open probe (default 5s).
+	OpenTimeout time.Duration
+
+	Mode BreakerMode
+	// Fallback handles items in BreakerFallback mode.
+	Fallback ContextProcessor[In, Out]
+	// MaxAttempts limits how many times an item is tried in BreakerPark mode before its error
+	// is returned (default 5). A negative value means no limit.
+	MaxAttempts int
+	// RetryBackoff is the pause before the second try of a failed item in BreakerPark mode
+	// (default 100ms). It doubles with every further try, up to OpenTimeout.
+	RetryBackoff time.Duration
+
+	// OnStateChange, if set, is called on every state transition from the goroutine that caused it,
+	// or from the breaker's timer for the transition from open to half-open.
+	OnStateChange func(BreakerEvent)
+
+	// Clock measures Window and OpenTimeout; nil means the system clock.
+	Clock Clock
+}
+
+const breakerBuckets = 10
+
+type breakerBucket struct {
+	slot     int64
+	calls    int
+	failures int
+}
+
+// CircuitBreaker wraps a ContextProcessor and stops calling it while it keeps failing.
+// Its Process method is itself a ContextProcessor and can be passed to any node constructor:
+//
+//	cb := nodes.NewCircuitBreaker(fetch, nodes.BreakerConfig[string, Page]{Mode: nodes.BreakerPark})
+//	pool := nodes.NewContextWorkerPool(cb.Process)
+type CircuitBreaker[In, Out any] struct {
+	proc ContextProcessor[In, Out]
+	cfg  BreakerConfig[In, Out]
+
+	mu       sync.Mutex
+	state    BreakerState
+	openedAt time.Time
+	probing  bool
+	buckets  [breakerBuckets]breakerBucket
+	changed  chan struct{} // closed and replaced on every transition
+	events   []BreakerEvent
+}
+
+// NewCircuitBreaker wraps proc into a CircuitBreaker configured by cfg.
+func NewCircuitBreaker[In, Out any](
+	proc ContextProcessor[In, Out],
+	cfg BreakerConfig[In, Out],
+) *CircuitBreaker[In, Out] {
+	if cfg.FailureRatio <= 0 {
+		cfg.FailureRatio = 0.5
+	}
+	if cfg.MinCalls <= 0 {
+		cfg.MinCalls = 10
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 5 * time.Second
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 100 * time.Millisecond
+	}
+	cfg.Clock = clockOr(cfg.Clock)
+
+	return &CircuitBreaker[In, Out]{
+		proc:    proc,
+		cfg:     cfg,
+		changed: make(chan struct{}),
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker[In, Out]) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Process applies the wrapped processor to in, subject to the breaker state and mode.
+func (b *CircuitBreaker[In, Out]) Process(ctx context.Context, in In) (Out, error) {
+	var zero Out
+	backoff := b.cfg.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		allowed, probe, wait := b.acquire()
+		if !allowed {
+			switch b.cfg.Mode {
+			case BreakerFallback:
+				return b.fallback(ctx, in, ErrCircuitOpen)
+			case BreakerPark:
+				select {
+				case <-wait:
+					continue
+				case <-ctx.Done():
+					return zero, ctx.Err()
+				}
+			default:
+				return zero, ErrCircuitOpen
+			}
+		}
+
+		res, err := b.call(ctx, in, probe)
+		if err == nil || ctx.Err() != nil {
+			return res, err
+		}
+
+		switch b.cfg.Mode {
+		case BreakerFallback:
+			return b.fallback(ctx, in, err)
+		case BreakerPark:
+			if b.cfg.MaxAttempts > 0 && attempt >= b.cfg.MaxAttempts {
+				return zero, err
+			}
+			select {
+			case <-b.cfg.Clock.After(backoff):
+			case <-ctx.Done():
+				return zero, ctx.Err()
+			}
+			backoff = min(2*backoff, b.cfg.OpenTimeout)
+		default:
+			return zero, err
+		}
+	}
+}
+
+// call runs the wrapped processor and records its outcome. If the pipeline is stopping, the
+// dependency is not to blame and nothing is recorded; if the processor panics, the panic goes on
+// to the node. Either way a probe is released, so that another caller can probe instead.
+func (b *CircuitBreaker[In, Out]) call(ctx context.Context, in In, probe bool) (res Out, err error) {
+	recorded := false
+	defer func() {
+		if !recorded {
+			b.release(probe)
+		}
+	}()
+
+	res, err = b.proc(ctx, in)
+	if ctx.Err() != nil {
+		return res, err
+	}
+	b.record(probe, err)
+	recorded = true
+	return res, err
+}
+
+func (b *CircuitBreaker[In, Out]) fallback(ctx context.Context, in In, cause error) (Out, error) {
+	if b.cfg.Fallback == nil {
+		var zero Out
+		return zero, cause
+	}
+	return b.cfg.Fallback(ctx, in)
+}
+
+// acquire decides whether a call may proceed. If it may not, wait is closed on the next transition,
+// which for an open breaker is made by the timer started in openLocked at the latest.
+func (b *CircuitBreaker[In, Out]) acquire() (allowed, probe bool, wait <-chan struct{}) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return true, false, nil
+	case BreakerOpen:
+		if b.cfg.Clock.Now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return false, false, b.changed
+		}
+		b.setStateLocked(BreakerHalfOpen)
+	}
+
+	if b.probing {
+		return false, false, b.changed
+	}
+	b.probing = true
+	return true, true, nil
+}
+
+func (b *CircuitBreaker[In, Out]) release(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	b.notifyLocked()
+}
+
+func (b *CircuitBreaker[In, Out]) record(probe bool, err error) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if probe {
+		b.probing = false
+		if err != nil {
+			b.openLocked()
+			return
+		}
+		b.buckets = [breakerBuckets]breakerBucket{}
+		b.setStateLocked(BreakerClosed)
+		return
+	}
+
+	if b.state != BreakerClosed {
+		return
+	}
+
+	width := max(int64(b.cfg.Window/breakerBuckets), 1)
+	slot := b.cfg.Clock.Now().UnixNano() / width
+	bucket := &b.buckets[slot%breakerBuckets]
+	if bucket.slot != slot {
+		*bucket = breakerBucket{slot: slot}
+	}
+	bucket.calls++
+	if err != nil {
+		bucket.failures++
+	}
+
+	calls, failures := 0, 0
+	for _, bk := range b.buckets {
+		if slot-bk.slot < breakerBuckets {
+			calls += bk.calls
+			failures += bk.failures
+		}
+	}
+	if calls >= b.cfg.MinCalls && float64(failures)/float64(calls) >= b.cfg.FailureRatio {
+		b.openLocked()
+	}
+}
+
+// openLocked opens the breaker and starts the single timer that moves it to half-open once
+// OpenTimeout elapses, unless another transition comes first.
+func (b *CircuitBreaker[In, Out]) openLocked() {
+	openedAt := b.cfg.Clock.Now()
+	b.openedAt = openedAt
+	b.setStateLocked(BreakerOpen)
+
+	changed := b.changed
+	after := b.cfg.Clock.After(b.cfg.OpenTimeout)
+	go func() {
+		select {
+		case <-after:
+		case <-changed:
+			return
+		}
+
+		b.mu.Lock()
+		defer b.unlock()
+		if b.state == BreakerOpen && b.openedAt.Equal(openedAt) {
+			b.setStateLocked(BreakerHalfOpen)
+		}
+	}()
+}
+
+func (b *CircuitBreaker[In, Out]) setStateLocked(to BreakerState) {
+	from := b.state
+	b.state = to
+	b.notifyLocked()
+	if from != to && b.cfg.OnStateChange != nil {
+		b.events = append(b.events, BreakerEvent{From: from, To: to, At: b.cfg.Clock.Now()})
+	}
+}
+
+// unlock releases the mutex and then publishes the transitions made while it was held,
+// so OnStateChange may safely call back into the breaker.
+func (b *CircuitBreaker[In, Out]) unlock() {
+	events := b.events
+	b.events = nil
+	b.mu.Unlock()
+
+	for _, ev := range events {
+		b.cfg.OnStateChange(ev)
+	}
+}
+
+func (b *CircuitBreaker[In, Out]) notifyLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
@@ -0,0 +1,190 @@
+package nodes_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+)
+
+var errDown = errors.New("dependency is down")
+
+// expectTransition ждёт очередного события брейкера: переход в half-open делает таймер в своей горутине.
+func expectTransition(t *testing.T, events <-chan nodes.BreakerEvent, from, to nodes.BreakerState) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		if ev.From != from || ev.To != to {
+			t.Fatalf("got transition %s -> %s, want %s -> %s", ev.From, ev.To, from, to)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no transition %s -> %s", from, to)
+	}
+}
+
+func TestCircuitBreakerStates(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	events := make(chan nodes.BreakerEvent, 10)
+	var calls atomic.Int64
+	var down atomic.Bool
+
+	cb := nodes.NewCircuitBreaker(func(_ context.Context, x int) (int, error) {
+		calls.Add(1)
+		if down.Load() {
+			return 0, errDown
+		}
+		return x, nil
+	}, nodes.BreakerConfig[int, int]{
+		MinCalls:      4,
+		OpenTimeout:   5 * time.Second,
+		Clock:         clock,
+		OnStateChange: func(ev nodes.BreakerEvent) { events <- ev },
+	})
+	ctx := t.Context()
+
+	// Одна удача и три ошибки из четырёх вызовов открывают брейкер
+	if _, err := cb.Process(ctx, 1); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	down.Store(true)
+	for range 3 {
+		if _, err := cb.Process(ctx, 1); !errors.Is(err, errDown) {
+			t.Fatalf("expected errDown, got %v", err)
+		}
+	}
+	expectTransition(t, events, nodes.BreakerClosed, nodes.BreakerOpen)
+
+	// Открытый брейкер не зовёт зависимость
+	if _, err := cb.Process(ctx, 1); !errors.Is(err, nodes.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Fatalf("dependency called %d times, want 4", n)
+	}
+
+	// Неудачная проба открывает его снова
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	expectTransition(t, events, nodes.BreakerOpen, nodes.BreakerHalfOpen)
+	if _, err := cb.Process(ctx, 1); !errors.Is(err, errDown) {
+		t.Fatalf("expected errDown from the probe, got %v", err)
+	}
+	expectTransition(t, events, nodes.BreakerHalfOpen, nodes.BreakerOpen)
+
+	// Удачная проба закрывает
+	down.Store(false)
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	expectTransition(t, events, nodes.BreakerOpen, nodes.BreakerHalfOpen)
+	if _, err := cb.Process(ctx, 1); err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	expectTransition(t, events, nodes.BreakerHalfOpen, nodes.BreakerClosed)
+	if s := cb.State(); s != nodes.BreakerClosed {
+		t.Fatalf("got state %s, want closed", s)
+	}
+}
+
+func TestCircuitBreakerParkBackoff(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	// Брейкер остаётся закрытым, а элемент повторяется с растущей паузой и MaxAttempts=5 по умолчанию
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	var calls atomic.Int64
+	cb := nodes.NewCircuitBreaker(func(context.Context, int) (int, error) {
+		calls.Add(1)
+		return 0, errDown
+	}, nodes.BreakerConfig[int, int]{Mode: nodes.BreakerPark, MinCalls: 100, Clock: clock})
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := cb.Process(t.Context(), 1)
+		errc <- err
+	}()
+
+	for i, backoff := range []time.Duration{100, 200, 400, 800} {
+		backoff *= time.Millisecond
+
+		// Если бы повтор случился раньше паузы, BlockUntil дождался бы следующего вызова
+		clock.BlockUntil(1)
+		clock.Advance(backoff - time.Millisecond)
+		clock.BlockUntil(1)
+		if n := calls.Load(); n != int64(i+1) {
+			t.Fatalf("retry %d: dependency called %d times before the backoff of %s", i+1, n, backoff)
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	if err := <-errc; !errors.Is(err, errDown) {
+		t.Fatalf("expected errDown after the last attempt, got %v", err)
+	}
+	if n := calls.Load(); n != 5 {
+		t.Fatalf("dependency called %d times, want 5", n)
+	}
+}
+
+func TestCircuitBreakerProbePanic(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	events := make(chan nodes.BreakerEvent, 10)
+	var panics atomic.Bool
+	panics.Store(true)
+	cb := nodes.NewCircuitBreaker(func(_ context.Context, x int) (int, error) {
+		if x == 0 {
+			return 0, errDown
+		}
+		if panics.Load() {
+			panic("boom")
+		}
+		return x, nil
+	}, nodes.BreakerConfig[int, int]{
+		Mode:          nodes.BreakerPark,
+		MinCalls:      1,
+		MaxAttempts:   1,
+		OpenTimeout:   time.Second,
+		Clock:         clock,
+		OnStateChange: func(ev nodes.BreakerEvent) { events <- ev },
+	})
+
+	if _, err := cb.Process(t.Context(), 0); !errors.Is(err, errDown) {
+		t.Fatalf("expected errDown, got %v", err)
+	}
+	expectTransition(t, events, nodes.BreakerClosed, nodes.BreakerOpen)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	expectTransition(t, events, nodes.BreakerOpen, nodes.BreakerHalfOpen)
+
+	// Паника пробы уходит в ноду, но проба освобождается
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected panic boom, got %v", r)
+			}
+		}()
+		cb.Process(t.Context(), 1)
+	}()
+
+	// Следующий элемент сам становится пробой, а не ждёт вечно
+	panics.Store(false)
+	done := make(chan error, 1)
+	go func() {
+		_, err := cb.Process(t.Context(), 2)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("probe failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("item parked forever after a panicking probe")
+	}
+	expectTransition(t, events, nodes.BreakerHalfOpen, nodes.BreakerClosed)
+}
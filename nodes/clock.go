@@ -0,0 +1,25 @@
+package nodes
+
+import "time"
+
+// Clock is the source of time of the nodes whose behavior depends on it, such as a dedup node
+// with a TTL or a CircuitBreaker, so that tests can control time (see pipelinetest.FakeClock).
+// A nil Clock stands for the system clock.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel that receives the current time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clockOr returns c, or the system clock if c is nil.
+func clockOr(c Clock) Clock {
+	if c == nil {
+		return systemClock{}
+	}
+	return c
+}
@@ -1,10 +1,42 @@
 package nodes
 
+import "time"
+
+const defaultScaleCooldown = time.Second
+
 // Config holds configuration parameters for nodes, such as buffer sizes and worker counts.
 type Config struct {
 	InBuffer int
 	Buffer   int
 	Workers  int
+
+	// MinWorkers and MaxWorkers enable the adaptive mode of the worker pool when MaxWorkers is set.
+	// The pool starts with Workers goroutines (clamped to the range) and adds workers when the
+	// input backs up or the mean item latency exceeds ScaleLatency, and retires idle workers.
+	// Scaling decisions are made at most once per ScaleCooldown (defaultScaleCooldown if zero).
+	MinWorkers    int
+	MaxWorkers    int
+	ScaleCooldown time.Duration
+	ScaleLatency  time.Duration
+
+	// Ordered makes the worker pool emit results in the order of its input while still
+	// processing items concurrently. At most 2*max(Workers, MaxWorkers) items are in flight,
+	// so an item that takes long stalls the pool once the later results fill that window.
+	Ordered bool
+
+	// Clock measures ScaleCooldown and item latency for the worker pool and the checkpoint interval
+	// for a resumable generator; nil means the system clock.
+	Clock Clock
+
+	// Timeout limits the time spent on a single item. When it elapses, the per-item context
+	// is canceled and the node fails with ErrItemTimeout. Zero disables the limit.
+	// The timeout is observed when the processor returns: a processor that ignores its context
+	// keeps running, and holds its worker, until it finishes on its own.
+	Timeout time.Duration
+
+	// RePanic disables panic recovery, so a panicking Processor or Sink crashes the process
+	// with its original stack. Useful for debugging.
+	RePanic bool
 }
 
 // DefaultConfig returns a Config with default values: InBuffer=0, Buffer=10, Workers=10.
@@ -14,3 +46,20 @@ func DefaultConfig() Config {
 		Workers: 10,
 	}
 }
+
+func (c Config) normalizeWorkers() Config {
+	if c.MaxWorkers <= 0 {
+		return c
+	}
+
+	c.MinWorkers = max(c.MinWorkers, 1)
+	c.MaxWorkers = max(c.MaxWorkers, c.MinWorkers)
+	if c.Workers == 0 {
+		c.Workers = c.MinWorkers
+	}
+	c.Workers = min(max(c.Workers, c.MinWorkers), c.MaxWorkers)
+	if c.ScaleCooldown <= 0 {
+		c.ScaleCooldown = defaultScaleCooldown
+	}
+	return c
+}
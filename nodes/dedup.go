@@ -0,0 +1,292 @@
+package nodes
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
+)
+
+var (
+	_ pipelines.Node[any, any] = &dedup[any, int]{}
+	_ StatsProvider            = &dedup[any, int]{}
+)
+
+// DedupStrategy selects how a dedup node remembers the keys it has already seen.
+type DedupStrategy int
+
+const (
+	// DedupLRU keeps the DedupConfig.MaxEntries most recently seen keys (exact).
+	DedupLRU DedupStrategy = iota
+	// DedupTTL remembers every key for DedupConfig.TTL after it was first seen (exact).
+	DedupTTL
+	// DedupBloom uses a Bloom filter sized for DedupConfig.ExpectedItems keys. It never forgets,
+	// uses a fixed amount of memory, but may drop unique items at DedupConfig.FalsePositiveRate.
+	DedupBloom
+)
+
+// DedupConfig configures a dedup node. Zero fields take the defaults listed below.
+type DedupConfig struct {
+	Strategy DedupStrategy
+
+	// MaxEntries bounds the number of remembered keys for DedupLRU and DedupTTL (default 100000).
+	// When DedupTTL reaches it, the oldest keys are forgotten before their TTL ends.
+	MaxEntries int
+	// TTL is the time a key is remembered for DedupTTL (default 1m). With DedupLRU it optionally
+	// expires entries as well.
+	TTL time.Duration
+
+	// ExpectedItems and FalsePositiveRate size the filter for DedupBloom
+	// (defaults 1000000 and 0.01).
+	ExpectedItems     uint64
+	FalsePositiveRate float64
+
+	// Clock measures TTL; nil means the system clock.
+	Clock Clock
+}
+
+type dedup[T any, K comparable] struct {
+	id uint64
+
+	in  []<-chan T
+	out []chan<- T
+	key func(T) K
+
+	seen      seenSet[K]
+	processed atomic.Uint64
+	emitted   atomic.Uint64
+	dropped   atomic.Uint64
+
+	config Config
+}
+
+// NewDedup creates a node that forwards only the first element for each key returned by key,
+// dropping later duplicates. How keys are remembered is controlled by dcfg, see DedupStrategy.
+// Like NewNode, it fans in all inputs and broadcasts to every output, each created with buffer
+// size cfg.Buffer. Dropped elements are counted in Stats, and dropped Messages are Drop'ed.
+func NewDedup[T any, K comparable](key func(T) K, dcfg DedupConfig, cfg ...Config) pipelines.Node[T, T] {
+	config := DefaultConfig()
+	if len(cfg) > 0 {
+		config = cfg[0]
+	}
+
+	return &dedup[T, K]{
+		id:     nextNodeID(),
+		key:    key,
+		seen:   newSeenSet[K](dcfg),
+		config: config,
+	}
+}
+
+func (n *dedup[T, K]) ID() string {
+	return fmt.Sprintf("dedup-node-%d", n.id)
+}
+
+func (n *dedup[T, K]) SetInput(in ...<-chan T) error {
+	n.in = append(n.in, in...)
+	return nil
+}
+
+func (n *dedup[T, K]) Output() (chan T, error) {
+	out := make(chan T, n.config.Buffer)
+	n.out = append(n.out, out)
+	return out, nil
+}
+
+func (n *dedup[T, K]) Stats() Stats {
+	return Stats{
+		Processed: n.processed.Load(),
+		Emitted:   n.emitted.Load(),
+		Dropped:   n.dropped.Load(),
+	}
+}
+
+func (n *dedup[T, K]) Run(ctx context.Context) error {
+	inChan, err := utils.FanIn(ctx, n.in, n.config.InBuffer)
+	if err != nil {
+		return err
+	}
+	defer utils.CloseChannels(n.out)
+
+	for {
+		select {
+		case data, open := <-inChan:
+			if !open {
+				return nil
+			}
+			n.processed.Add(1)
+
+			k, err := apply(n.ID(), n.config, n.key, data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", n.ID(), err)
+			}
+
+			if n.seen.testAndAdd(k) {
+				if d, ok := any(data).(dropper); ok {
+					d.Drop()
+				}
+				n.dropped.Add(1)
+				continue
+			}
+
+			if err := broadcast(ctx, n.out, data); err != nil {
+				return err
+			}
+			n.emitted.Add(1)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// seenSet remembers keys. testAndAdd reports whether k was already present and records it.
+// Implementations are used from a single goroutine.
+type seenSet[K comparable] interface {
+	testAndAdd(k K) bool
+}
+
+func newSeenSet[K comparable](cfg DedupConfig) seenSet[K] {
+	if cfg.Strategy == DedupBloom {
+		n := cfg.ExpectedItems
+		if n == 0 {
+			n = 1_000_000
+		}
+		p := cfg.FalsePositiveRate
+		if p <= 0 || p >= 1 {
+			p = 0.01
+		}
+		return newBloomSet[K](n, p)
+	}
+
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 100_000
+	}
+	if cfg.Strategy == DedupTTL {
+		ttl := cfg.TTL
+		if ttl <= 0 {
+			ttl = time.Minute
+		}
+		return newLRUSet[K](maxEntries, ttl, false, clockOr(cfg.Clock))
+	}
+	return newLRUSet[K](maxEntries, cfg.TTL, true, clockOr(cfg.Clock))
+}
+
+type lruEntry[K comparable] struct {
+	key     K
+	expires time.Time
+}
+
+// lruSet is an exact set bounded by size and/or age. With refresh, hits move a key to the front,
+// giving LRU eviction; without it the list stays in insertion order, which is also expiry order.
+type lruSet[K comparable] struct {
+	maxEntries int
+	ttl        time.Duration
+	refresh    bool
+	clock      Clock
+
+	order *list.List
+	items map[K]*list.Element
+}
+
+func newLRUSet[K comparable](maxEntries int, ttl time.Duration, refresh bool, clock Clock) *lruSet[K] {
+	return &lruSet[K]{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		refresh:    refresh,
+		clock:      clock,
+		order:      list.New(),
+		items:      make(map[K]*list.Element),
+	}
+}
+
+func (s *lruSet[K]) testAndAdd(k K) bool {
+	now := s.clock.Now()
+	s.expire(now)
+
+	if el, ok := s.items[k]; ok {
+		entry := el.Value.(*lruEntry[K])
+		if s.ttl <= 0 || now.Before(entry.expires) {
+			if s.refresh {
+				s.order.MoveToFront(el)
+			}
+			return true
+		}
+		s.remove(el)
+	}
+
+	entry := &lruEntry[K]{key: k}
+	if s.ttl > 0 {
+		entry.expires = now.Add(s.ttl)
+	}
+	s.items[k] = s.order.PushFront(entry)
+
+	if s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+	return false
+}
+
+// expire drops expired entries from the back of the list.
+func (s *lruSet[K]) expire(now time.Time) {
+	if s.ttl <= 0 {
+		return
+	}
+	for el := s.order.Back(); el != nil; el = s.order.Back() {
+		if now.Before(el.Value.(*lruEntry[K]).expires) {
+			return
+		}
+		s.remove(el)
+	}
+}
+
+func (s *lruSet[K]) remove(el *list.Element) {
+	delete(s.items, el.Value.(*lruEntry[K]).key)
+	s.order.Remove(el)
+}
+
+// bloomSet is a Bloom filter using double hashing over two maphash seeds.
+type bloomSet[K comparable] struct {
+	bits  []uint64
+	m     uint64
+	k     uint64
+	seed1 maphash.Seed
+	seed2 maphash.Seed
+}
+
+func newBloomSet[K comparable](n uint64, p float64) *bloomSet[K] {
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	k = max(k, 1)
+
+	return &bloomSet[K]{
+		bits:  make([]uint64, (m+63)/64),
+		m:     m,
+		k:     k,
+		seed1: maphash.MakeSeed(),
+		seed2: maphash.MakeSeed(),
+	}
+}
+
+func (s *bloomSet[K]) testAndAdd(key K) bool {
+	h1 := maphash.Comparable(s.seed1, key)
+	h2 := maphash.Comparable(s.seed2, key) | 1
+
+	present := true
+	for i := range s.k {
+		bit := (h1 + i*h2) % s.m
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if s.bits[word]&mask == 0 {
+			present = false
+			s.bits[word] |= mask
+		}
+	}
+	return present
+}
@@ -0,0 +1,91 @@
+package nodes_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+)
+
+// dedup прогоняет items через узел дедупликации и возвращает прошедшие элементы и статистику.
+func dedup[T comparable](t *testing.T, dcfg nodes.DedupConfig, items ...T) ([]T, nodes.Stats) {
+	t.Helper()
+
+	src := pipelinetest.Source(items...)
+	node := nodes.NewDedup(func(x T) T { return x }, dcfg)
+	got, sink := pipelinetest.Collect[T]()
+	pipelinetest.Connect(t, src, node)
+	pipelinetest.Connect(t, node, sink)
+	pipelinetest.Run(t, src, node, sink)
+	return got.Items(), node.(nodes.StatsProvider).Stats()
+}
+
+func TestDedupLRUEviction(t *testing.T) {
+	// Повтор "a" освежает его, поэтому вытесняется "b", а затем и "a"
+	got, stats := dedup(t, nodes.DedupConfig{Strategy: nodes.DedupLRU, MaxEntries: 2},
+		"a", "b", "a", "c", "b", "a")
+	pipelinetest.Equal(t, got, []string{"a", "b", "c", "b", "a"})
+	if stats.Processed != 6 || stats.Emitted != 5 || stats.Dropped != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestDedupTTLBounded(t *testing.T) {
+	// Даже до истечения TTL память ограничена MaxEntries: "a" вытеснен и проходит снова
+	got, _ := dedup(t, nodes.DedupConfig{Strategy: nodes.DedupTTL, MaxEntries: 2},
+		"a", "b", "b", "c", "a")
+	pipelinetest.Equal(t, got, []string{"a", "b", "c", "a"})
+}
+
+func TestDedupBloom(t *testing.T) {
+	const n = 1000
+
+	var items []string
+	for i := range n {
+		items = append(items, fmt.Sprint("seen-", i))
+	}
+	for i := range n {
+		items = append(items, fmt.Sprint("seen-", i))
+	}
+	for i := range 10 * n {
+		items = append(items, fmt.Sprint("new-", i))
+	}
+
+	got, stats := dedup(t, nodes.DedupConfig{Strategy: nodes.DedupBloom, ExpectedItems: 11 * n, FalsePositiveRate: 0.01}, items...)
+
+	// Ложноотрицательных срабатываний у фильтра Блума не бывает: повторов на выходе нет
+	seen := make(map[string]bool)
+	for _, s := range got {
+		if seen[s] {
+			t.Fatalf("repeat %q passed the filter", s)
+		}
+		seen[s] = true
+	}
+	// Ложноположительные — около 1% уникальных ключей, с запасом на случайные сиды
+	if fp := 11*n - len(got); fp > 3*11*n/100 {
+		t.Fatalf("%d of %d unique keys dropped as false positives", fp, 11*n)
+	}
+	if stats.Dropped != uint64(len(items)-len(got)) {
+		t.Errorf("Stats().Dropped = %d, want %d", stats.Dropped, len(items)-len(got))
+	}
+}
+
+func TestDedupKeyPanic(t *testing.T) {
+	src := pipelinetest.Source(1, 2)
+	node := nodes.NewDedup(func(x int) int {
+		if x == 2 {
+			panic("boom")
+		}
+		return x
+	}, nodes.DedupConfig{})
+	_, sink := pipelinetest.Collect[int]()
+	pipelinetest.Connect(t, src, node)
+	pipelinetest.Connect(t, node, sink)
+
+	var panicErr *nodes.PanicError
+	if err := pipelinetest.RunError(t, src, node, sink); !errors.As(err, &panicErr) || panicErr.NodeID != node.ID() {
+		t.Fatalf("expected *PanicError of %s, got %v", node.ID(), err)
+	}
+}
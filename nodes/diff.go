@@ -0,0 +1,225 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
+)
+
+var (
+	_ pipelines.Node[any, Diff[any]] = &diff[any, int]{}
+	_ StatsProvider                  = &diff[any, int]{}
+)
+
+// DiffKind tells how an element differs from the baseline, see NewDiff.
+type DiffKind int
+
+const (
+	// DiffAdded is an element whose key is not in the baseline.
+	DiffAdded DiffKind = iota
+	// DiffRemoved is a baseline element whose key did not appear in the stream.
+	DiffRemoved
+	// DiffChanged is an element that differs from the baseline element with its key.
+	DiffChanged
+	// DiffUnchanged is an element equal to the baseline element with its key.
+	DiffUnchanged
+)
+
+var diffKindNames = []string{"added", "removed", "changed", "unchanged"}
+
+func (k DiffKind) String() string {
+	if int(k) < len(diffKindNames) {
+		return diffKindNames[k]
+	}
+	return fmt.Sprintf("DiffKind(%d)", int(k))
+}
+
+// Diff is an event emitted by NewDiff. Old is the baseline element and New the element of
+// the stream; the one that does not exist is the zero value.
+type Diff[T any] struct {
+	Kind DiffKind
+	Old  T
+	New  T
+}
+
+type diff[T any, K comparable] struct {
+	id uint64
+
+	in       []<-chan T
+	out      []chan<- Diff[T]
+	baseline Generator[T]
+	key      func(T) K
+	equal    func(old, new T) bool
+
+	processed atomic.Uint64
+	emitted   atomic.Uint64
+	dropped   atomic.Uint64
+
+	config Config
+}
+
+// NewDiff creates a node that compares its input with a baseline snapshot by the key returned by
+// key, e.g. today's file digests with last night's manifest. The baseline Generator, which may read
+// a file or wrap the output channel of another node, is read completely before the first input
+// element; its elements are held in memory. Then each input element is emitted as DiffAdded, or as
+// DiffChanged or DiffUnchanged as reported by equal, and once all inputs are closed every baseline
+// element not matched is emitted as DiffRemoved, in baseline order.
+//
+// Keys are matched once: only the first input element with a key is emitted, later ones are
+// dropped, and a repeated key in the baseline is ignored. Like NewNode it fans in all inputs and broadcasts to every output, each
+// created with buffer size cfg.Buffer. Errors reported by the baseline with ReportError fail Run.
+func NewDiff[T any, K comparable](baseline Generator[T], key func(T) K, equal func(old, new T) bool, cfg ...Config) pipelines.Node[T, Diff[T]] {
+	config := DefaultConfig()
+	if len(cfg) > 0 {
+		config = cfg[0]
+	}
+
+	return &diff[T, K]{
+		id:       nextNodeID(),
+		baseline: baseline,
+		key:      key,
+		equal:    equal,
+		config:   config,
+	}
+}
+
+func (n *diff[T, K]) ID() string {
+	return fmt.Sprintf("diff-node-%d", n.id)
+}
+
+func (n *diff[T, K]) SetInput(in ...<-chan T) error {
+	n.in = append(n.in, in...)
+	return nil
+}
+
+func (n *diff[T, K]) Output() (chan Diff[T], error) {
+	out := make(chan Diff[T], n.config.Buffer)
+	n.out = append(n.out, out)
+	return out, nil
+}
+
+// Stats counts input elements as Processed, all events as Emitted and input elements with
+// a repeated key as Dropped.
+func (n *diff[T, K]) Stats() Stats {
+	return Stats{
+		Processed: n.processed.Load(),
+		Emitted:   n.emitted.Load(),
+		Dropped:   n.dropped.Load(),
+	}
+}
+
+func (n *diff[T, K]) Run(ctx context.Context) error {
+	defer utils.CloseChannels(n.out)
+
+	order, base, err := n.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	inChan, err := utils.FanIn(ctx, n.in, n.config.InBuffer)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[K]struct{})
+	for {
+		select {
+		case data, open := <-inChan:
+			if !open {
+				return n.removed(ctx, order, base)
+			}
+			n.processed.Add(1)
+
+			k, err := apply(n.ID(), n.config, n.key, data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", n.ID(), err)
+			}
+			if _, dup := seen[k]; dup {
+				n.dropped.Add(1)
+				if d, ok := any(data).(dropper); ok {
+					d.Drop()
+				}
+				continue
+			}
+			seen[k] = struct{}{}
+
+			d := Diff[T]{Kind: DiffAdded, New: data}
+			if old, ok := base[k]; ok {
+				delete(base, k)
+				same, err := apply(n.ID(), n.config, func(v T) bool { return n.equal(old, v) }, data)
+				if err != nil {
+					return fmt.Errorf("%s: %w", n.ID(), err)
+				}
+				d = Diff[T]{Kind: DiffChanged, Old: old, New: data}
+				if same {
+					d.Kind = DiffUnchanged
+				}
+			}
+
+			if err := broadcast(ctx, n.out, d); err != nil {
+				return err
+			}
+			n.emitted.Add(1)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// load reads the baseline into a map by key, keeping the order of the keys.
+func (n *diff[T, K]) load(ctx context.Context) (order []K, base map[K]T, err error) {
+	genCtx, report := withErrorReport(ctx, n.ID(), n.config)
+	ch, err := n.startBaseline(genCtx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: baseline: %w", n.ID(), err)
+	}
+
+	base = make(map[K]T)
+	for {
+		select {
+		case data, open := <-ch:
+			if !open {
+				if err := report.get(); err != nil {
+					return nil, nil, fmt.Errorf("%s: baseline: %w", n.ID(), err)
+				}
+				return order, base, nil
+			}
+
+			k, err := apply(n.ID(), n.config, n.key, data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", n.ID(), err)
+			}
+			if _, dup := base[k]; !dup {
+				base[k] = data
+				order = append(order, k)
+			}
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+func (n *diff[T, K]) startBaseline(ctx context.Context) (ch <-chan T, err error) {
+	if !n.config.RePanic {
+		defer recoverPanic(n.ID(), &err)
+	}
+	return n.baseline(ctx)
+}
+
+// removed emits the baseline elements left unmatched.
+func (n *diff[T, K]) removed(ctx context.Context, order []K, base map[K]T) error {
+	for _, k := range order {
+		old, ok := base[k]
+		if !ok {
+			continue
+		}
+		if err := broadcast(ctx, n.out, Diff[T]{Kind: DiffRemoved, Old: old}); err != nil {
+			return err
+		}
+		n.emitted.Add(1)
+	}
+	return nil
+}
@@ -1,15 +1,42 @@
 package nodes
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrAccessRunningNode = errors.New("access attempt to running node")
 	ErrNodeRunning       = errors.New("node is already running")
 	ErrOnlyOneInput      = errors.New("worker pool requires exactly one input")
+	ErrOnlyOneOutput     = errors.New("node supports only one output")
 
 	ErrHasNoOutput = errors.New("this node has not outputs")
 	ErrHasNoInput  = errors.New("this node has not inputs")
 
 	ErrZipNodeNoInput     = errors.New("zipNode: no input channels")
 	ErrZipNodeClosedInput = errors.New("zipNode: one of the input channels was closed")
+
+	ErrItemTimeout = errors.New("item processing timed out")
+	ErrCircuitOpen = errors.New("circuit breaker is open")
 )
+
+// PanicError is returned by a node whose Processor, Sink or Generator panicked.
+// Set Config.RePanic to let the panic propagate instead.
+type PanicError struct {
+	NodeID string
+	Value  any
+	Stack  []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
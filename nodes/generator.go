@@ -2,28 +2,46 @@ package nodes
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/Sergey-Polishchenko/pipelines"
 	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
 )
 
-var _ pipelines.Node[any, any] = &generator[any]{}
+var (
+	_ pipelines.Node[any, any] = &generator[any]{}
+	_ StatsProvider            = &generator[any]{}
+)
 
 type generator[Out any] struct {
 	id uint64
 
 	out      []chan<- Out
 	generate Generator[Out]
+	emitted  atomic.Uint64
+
+	config Config
 }
 
 // NewGenerator creates a node that produces elements using the provided Generator function.
-// The Generator receives a context for cancellation and returns a receive-only channel of outputs.
-// The node can have multiple output channels; each output is created with buffer size cfg.Buffer.
-func NewGenerator[Out any](gen Generator[Out]) pipelines.Node[any, Out] {
+// The Generator receives a context for cancellation and returns a receive-only channel of outputs;
+// errors that happen while streaming can be passed to ReportError and are returned by Run.
+// The node can have multiple output channels; each output buffers one element.
+// A panic raised while starting the Generator, or inside a goroutine it started with Produce,
+// is reported as a *PanicError unless cfg.RePanic is set. Panics in other goroutines the
+// Generator starts cannot be recovered and crash the process.
+func NewGenerator[Out any](gen Generator[Out], cfg ...Config) pipelines.Node[any, Out] {
+	config := DefaultConfig()
+	if len(cfg) > 0 {
+		config = cfg[0]
+	}
+
 	return &generator[Out]{
 		id:       nextNodeID(),
 		generate: gen,
+		config:   config,
 	}
 }
 
@@ -41,18 +59,81 @@ func (n *generator[Out]) Output() (chan Out, error) {
 	return out, nil
 }
 
+// Stats reports the emitted elements; a generator has no inputs.
+func (n *generator[Out]) Stats() Stats {
+	return Stats{Emitted: n.emitted.Load()}
+}
+
 func (n *generator[Out]) Run(ctx context.Context) error {
 	defer utils.CloseChannels(n.out)
 
-	ch, err := n.generate(ctx)
+	ctx, report := withErrorReport(ctx, n.ID(), n.config)
+	ch, err := n.start(ctx)
 	if err != nil {
 		return fmt.Errorf("%s: %w", n.ID(), err)
 	}
 
-	for data := range ch {
-		if err := utils.Broadcast(ctx, n.out, data); err != nil {
-			return err
+	// the producer may be blocked outside of ctx (e.g. in a read), so the node does not wait for
+	// it to close ch once ctx is canceled
+	for {
+		select {
+		case data, ok := <-ch:
+			if !ok {
+				if err := report.get(); err != nil {
+					return fmt.Errorf("%s: %w", n.ID(), err)
+				}
+				return nil
+			}
+			if err := broadcast(ctx, n.out, data); err != nil {
+				return err
+			}
+			n.emitted.Add(1)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (n *generator[Out]) start(ctx context.Context) (ch <-chan Out, err error) {
+	if !n.config.RePanic {
+		defer recoverPanic(n.ID(), &err)
+	}
+	return n.generate(ctx)
+}
+
+// Produce runs fn in a new goroutine that feeds the returned channel; Generators use it to stream
+// their elements. emit sends one element and reports false once ctx is canceled, after which fn
+// should return. A non-nil error from fn is passed to ReportError unless ctx is already canceled.
+// Inside a generator node a panic in fn is recovered and reported as a *PanicError of that node,
+// unless its cfg.RePanic is set.
+func Produce[T any](ctx context.Context, fn func(emit func(T) bool) error) <-chan T {
+	out := make(chan T)
+
+	go func() {
+		defer close(out)
+
+		emit := func(v T) bool {
+			select {
+			case out <- v:
+				return true
+			case <-ctx.Done():
+				return false
+			}
 		}
+
+		err := produce(ctx, fn, emit)
+		var panicErr *PanicError
+		if err != nil && (ctx.Err() == nil || errors.As(err, &panicErr)) {
+			ReportError(ctx, err)
+		}
+	}()
+
+	return out
+}
+
+func produce[T any](ctx context.Context, fn func(emit func(T) bool) error, emit func(T) bool) (err error) {
+	if r, ok := ctx.Value(reportKey{}).(*errorReport); ok && !r.rePanic {
+		defer recoverPanic(r.id, &err)
 	}
-	return nil
+	return fn(emit)
 }
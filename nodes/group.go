@@ -0,0 +1,228 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
+)
+
+var (
+	_ pipelines.Node[any, Group[int, any]] = &groupBy[any, int]{}
+	_ StatsProvider                        = &groupBy[any, int]{}
+	_ pipelines.Node[any, any]             = &flatMap[any, any]{}
+	_ StatsProvider                        = &flatMap[any, any]{}
+)
+
+// Group is a set of elements sharing a key, as emitted by NewGroupBy.
+type Group[K comparable, T any] struct {
+	Key   K
+	Items []T
+}
+
+// GroupConfig configures NewGroupBy.
+type GroupConfig struct {
+	// MinItems drops groups with fewer elements, e.g. 2 keeps only keys seen more than once.
+	MinItems int
+}
+
+type groupBy[T any, K comparable] struct {
+	id uint64
+
+	in  []<-chan T
+	out []chan<- Group[K, T]
+	key func(T) K
+
+	processed atomic.Uint64
+	emitted   atomic.Uint64
+	dropped   atomic.Uint64
+
+	group  GroupConfig
+	config Config
+}
+
+// NewGroupBy creates a node that collects its whole input into groups by the key returned by key.
+// Once all inputs are closed it emits the groups in the order their keys first appeared, skipping
+// those smaller than gcfg.MinItems; since nothing is emitted before that, every element is held
+// in memory. Like NewNode it fans in all inputs and broadcasts to every output, each created with
+// buffer size cfg.Buffer. Dropped groups are counted in Stats by their elements.
+func NewGroupBy[T any, K comparable](key func(T) K, gcfg GroupConfig, cfg ...Config) pipelines.Node[T, Group[K, T]] {
+	config := DefaultConfig()
+	if len(cfg) > 0 {
+		config = cfg[0]
+	}
+
+	return &groupBy[T, K]{
+		id:     nextNodeID(),
+		key:    key,
+		group:  gcfg,
+		config: config,
+	}
+}
+
+func (n *groupBy[T, K]) ID() string {
+	return fmt.Sprintf("group-by-node-%d", n.id)
+}
+
+func (n *groupBy[T, K]) SetInput(in ...<-chan T) error {
+	n.in = append(n.in, in...)
+	return nil
+}
+
+func (n *groupBy[T, K]) Output() (chan Group[K, T], error) {
+	out := make(chan Group[K, T], n.config.Buffer)
+	n.out = append(n.out, out)
+	return out, nil
+}
+
+func (n *groupBy[T, K]) Stats() Stats {
+	return Stats{
+		Processed: n.processed.Load(),
+		Emitted:   n.emitted.Load(),
+		Dropped:   n.dropped.Load(),
+	}
+}
+
+func (n *groupBy[T, K]) Run(ctx context.Context) error {
+	inChan, err := utils.FanIn(ctx, n.in, n.config.InBuffer)
+	if err != nil {
+		return err
+	}
+	defer utils.CloseChannels(n.out)
+
+	var (
+		order  []K
+		groups = make(map[K][]T)
+	)
+	for {
+		select {
+		case data, open := <-inChan:
+			if !open {
+				return n.emit(ctx, order, groups)
+			}
+			n.processed.Add(1)
+
+			k, err := apply(n.ID(), n.config, n.key, data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", n.ID(), err)
+			}
+
+			if _, seen := groups[k]; !seen {
+				order = append(order, k)
+			}
+			groups[k] = append(groups[k], data)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (n *groupBy[T, K]) emit(ctx context.Context, order []K, groups map[K][]T) error {
+	for _, k := range order {
+		items := groups[k]
+		if len(items) < n.group.MinItems {
+			n.dropped.Add(uint64(len(items)))
+			continue
+		}
+
+		if err := broadcast(ctx, n.out, Group[K, T]{Key: k, Items: items}); err != nil {
+			return err
+		}
+		n.emitted.Add(1)
+	}
+	return nil
+}
+
+type flatMap[In, Out any] struct {
+	id uint64
+
+	in      []<-chan In
+	out     []chan<- Out
+	process ContextProcessor[In, []Out]
+
+	processed atomic.Uint64
+	emitted   atomic.Uint64
+
+	config Config
+}
+
+// NewFlatMap creates a node that applies proc to each input element and emits every element of
+// the returned slice, in order. Like NewNode it fans in all inputs and broadcasts to every output,
+// each created with buffer size cfg.Buffer.
+func NewFlatMap[In, Out any](proc Processor[In, []Out], cfg ...Config) pipelines.Node[In, Out] {
+	return NewContextFlatMap(proc.withContext(), cfg...)
+}
+
+// NewContextFlatMap is like NewFlatMap, but the ContextProcessor receives a per-item context that is
+// canceled when the pipeline stops or cfg.Timeout elapses.
+func NewContextFlatMap[In, Out any](proc ContextProcessor[In, []Out], cfg ...Config) pipelines.Node[In, Out] {
+	config := DefaultConfig()
+	if len(cfg) > 0 {
+		config = cfg[0]
+	}
+
+	return &flatMap[In, Out]{
+		id:      nextNodeID(),
+		process: proc,
+		config:  config,
+	}
+}
+
+func (n *flatMap[In, Out]) ID() string {
+	return fmt.Sprintf("flat-map-node-%d", n.id)
+}
+
+func (n *flatMap[In, Out]) SetInput(in ...<-chan In) error {
+	n.in = append(n.in, in...)
+	return nil
+}
+
+func (n *flatMap[In, Out]) Output() (chan Out, error) {
+	out := make(chan Out, n.config.Buffer)
+	n.out = append(n.out, out)
+	return out, nil
+}
+
+// Stats counts every element of the returned slices as emitted.
+func (n *flatMap[In, Out]) Stats() Stats {
+	return Stats{
+		Processed: n.processed.Load(),
+		Emitted:   n.emitted.Load(),
+	}
+}
+
+func (n *flatMap[In, Out]) Run(ctx context.Context) error {
+	inChan, err := utils.FanIn(ctx, n.in, n.config.InBuffer)
+	if err != nil {
+		return err
+	}
+	defer utils.CloseChannels(n.out)
+
+	for {
+		select {
+		case data, open := <-inChan:
+			if !open {
+				return nil
+			}
+			n.processed.Add(1)
+
+			results, err := call(ctx, n.ID(), n.config, func(ctx context.Context) ([]Out, error) {
+				return n.process(ctx, data)
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", n.ID(), err)
+			}
+
+			for _, result := range results {
+				if err := broadcast(ctx, n.out, result); err != nil {
+					return err
+				}
+				n.emitted.Add(1)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
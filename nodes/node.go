@@ -11,16 +11,21 @@ import (
 	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
 )
 
-var _ pipelines.Node[any, any] = &node[any, any]{}
+var (
+	_ pipelines.Node[any, any] = &node[any, any]{}
+	_ StatsProvider            = &node[any, any]{}
+)
 
 type node[In, Out any] struct {
 	id uint64
 
 	in      []<-chan In
 	out     []chan<- Out
-	process Processor[In, Out]
+	process ContextProcessor[In, Out]
 
 	isRunning atomic.Bool
+	processed atomic.Uint64
+	emitted   atomic.Uint64
 	config    Config
 }
 
@@ -29,6 +34,12 @@ type node[In, Out any] struct {
 // Each output channel is created with a buffer size defined in cfg.Buffer (or using DefaultConfig if none provided).
 // The Processor function is called for each element received via a fan-in of the inputs.
 func NewNode[In, Out any](proc Processor[In, Out], cfg ...Config) pipelines.Node[In, Out] {
+	return NewContextNode(proc.withContext(), cfg...)
+}
+
+// NewContextNode is like NewNode, but the ContextProcessor receives a per-item context that is
+// canceled when the pipeline stops or cfg.Timeout elapses.
+func NewContextNode[In, Out any](proc ContextProcessor[In, Out], cfg ...Config) pipelines.Node[In, Out] {
 	config := DefaultConfig()
 	if len(cfg) > 0 {
 		config = cfg[0]
@@ -66,6 +77,13 @@ func (n *node[In, Out]) Output() (chan Out, error) {
 	return out, nil
 }
 
+func (n *node[In, Out]) Stats() Stats {
+	return Stats{
+		Processed: n.processed.Load(),
+		Emitted:   n.emitted.Load(),
+	}
+}
+
 // node[In, Out].Run(ctx) error
 func (n *node[In, Out]) Run(ctx context.Context) error {
 	if n.isRunning.Load() {
@@ -88,15 +106,19 @@ func (n *node[In, Out]) Run(ctx context.Context) error {
 			if !open {
 				return nil
 			}
+			n.processed.Add(1)
 
-			result, err := n.process(data)
+			result, err := call(ctx, n.ID(), n.config, func(ctx context.Context) (Out, error) {
+				return n.process(ctx, data)
+			})
 			if err != nil {
 				return fmt.Errorf("%s: %w", n.ID(), err)
 			}
 
-			if err := utils.Broadcast(ctx, n.out, result); err != nil {
+			if err := broadcast(ctx, n.out, result); err != nil {
 				return err
 			}
+			n.emitted.Add(1)
 		case <-ctx.Done():
 			return ctx.Err()
 		}
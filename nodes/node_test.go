@@ -0,0 +1,388 @@
+package nodes_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+func TestContextNodeTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	gen := nodes.NewGenerator(func(ctx context.Context) (<-chan int, error) {
+		out := make(chan int, 1)
+		out <- 1
+		close(out)
+		return out, nil
+	})
+
+	// Процессор ждёт отмены контекста элемента — сработать должен Config.Timeout
+	slow := nodes.NewContextNode(func(ctx context.Context, x int) (int, error) {
+		<-ctx.Done()
+		return x, ctx.Err()
+	}, nodes.Config{Buffer: 1, Timeout: 10 * time.Millisecond})
+
+	sink := nodes.NewResultAggregator(func(int) error { return nil })
+
+	if err := pipelines.Connect(gen, slow); err != nil {
+		t.Fatalf("Connect(gen, slow) failed: %v", err)
+	}
+	if err := pipelines.Connect(slow, sink); err != nil {
+		t.Fatalf("Connect(slow, sink) failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(gen, slow, sink)
+
+	err := p.Run(ctx)
+	if !errors.Is(err, nodes.ErrItemTimeout) {
+		t.Fatalf("expected ErrItemTimeout, got %v", err)
+	}
+	if !strings.Contains(err.Error(), slow.ID()) {
+		t.Errorf("error %q does not name node %s", err, slow.ID())
+	}
+}
+
+func TestWorkerPoolTimeoutWithoutWorkers(t *testing.T) {
+	// Config без Workers не должна терять Timeout: пул берёт число воркеров по умолчанию
+	src := pipelinetest.Source(1, 2, 3)
+	pool := nodes.NewContextWorkerPool(func(ctx context.Context, x int) (int, error) {
+		<-ctx.Done()
+		return x, ctx.Err()
+	}, nodes.Config{Timeout: 10 * time.Millisecond})
+	_, sink := pipelinetest.Collect[int]()
+	pipelinetest.Connect(t, src, pool)
+	pipelinetest.Connect(t, pool, sink)
+
+	if err := pipelinetest.RunError(t, src, pool, sink); !errors.Is(err, nodes.ErrItemTimeout) {
+		t.Fatalf("expected ErrItemTimeout, got %v", err)
+	}
+}
+
+func TestWorkerPoolPanicRecovery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	gen := nodes.NewGenerator(func(ctx context.Context) (<-chan int, error) {
+		out := make(chan int, 3)
+		for i := range 3 {
+			out <- i
+		}
+		close(out)
+		return out, nil
+	})
+
+	pool := nodes.NewWorkerPool(func(x int) (int, error) {
+		if x == 1 {
+			panic("boom")
+		}
+		return x, nil
+	}, nodes.Config{Workers: 2})
+
+	sink := nodes.NewResultAggregator(func(int) error { return nil })
+
+	if err := pipelines.Connect(gen, pool); err != nil {
+		t.Fatalf("Connect(gen, pool) failed: %v", err)
+	}
+	if err := pipelines.Connect(pool, sink); err != nil {
+		t.Fatalf("Connect(pool, sink) failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(gen, pool, sink)
+
+	var panicErr *nodes.PanicError
+	if err := p.Run(ctx); !errors.As(err, &panicErr) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+	if panicErr.NodeID != pool.ID() || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Errorf("unexpected panic error: %+v", panicErr)
+	}
+}
+
+func TestGeneratorProducerPanic(t *testing.T) {
+	// Паника в горутине, запущенной через Produce, должна завершить ноду, а не процесс
+	gen := nodes.NewGenerator(func(ctx context.Context) (<-chan int, error) {
+		return nodes.Produce(ctx, func(emit func(int) bool) error {
+			emit(1)
+			panic("boom")
+		}), nil
+	})
+	_, sink := pipelinetest.Collect[int]()
+	pipelinetest.Connect(t, gen, sink)
+
+	var panicErr *nodes.PanicError
+	if err := pipelinetest.RunError(t, gen, sink); !errors.As(err, &panicErr) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+	if panicErr.NodeID != gen.ID() || panicErr.Value != "boom" {
+		t.Errorf("unexpected panic error: %+v", panicErr)
+	}
+}
+
+func TestAckFanOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	type outcome struct {
+		res nodes.AckResult
+		err error
+	}
+	results := make(chan outcome, 3)
+
+	gen := nodes.NewGenerator(nodes.AckGenerator(func(ctx context.Context) (<-chan int, error) {
+		out := make(chan int, 3)
+		for i := range 3 {
+			out <- i
+		}
+		close(out)
+		return out, nil
+	}, func(_ int, res nodes.AckResult, err error) {
+		results <- outcome{res, err}
+	}))
+
+	// Две ветки: первая всегда принимает элемент, вторая отклоняет 2. Отказ ждёт, пока первая ветка
+	// примет 2, иначе отмена пайплайна может оставить элемент незавершённым.
+	errOdd := errors.New("rejected")
+	sunk2 := make(chan struct{})
+	ok := nodes.NewContextResultAggregator(func(ctx context.Context, m nodes.Message[int]) error {
+		err := nodes.AckSink(func(context.Context, int) error { return nil })(ctx, m)
+		if m.Value == 2 {
+			close(sunk2)
+		}
+		return err
+	})
+	picky := nodes.NewContextResultAggregator(nodes.AckSink(func(ctx context.Context, x int) error {
+		if x == 2 {
+			select {
+			case <-sunk2:
+			case <-ctx.Done():
+			}
+			return errOdd
+		}
+		return nil
+	}))
+
+	if err := pipelines.ConnectToMany(gen, ok, picky); err != nil {
+		t.Fatalf("ConnectToMany failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(gen, ok, picky)
+	if err := p.Run(ctx); !errors.Is(err, errOdd) {
+		t.Fatalf("expected errOdd, got %v", err)
+	}
+
+	close(results)
+	var sunk, failed int
+	for o := range results {
+		switch o.res {
+		case nodes.AckSunk:
+			sunk++
+		case nodes.AckFailed:
+			failed++
+			if !errors.Is(o.err, errOdd) {
+				t.Errorf("failed item reported %v", o.err)
+			}
+		}
+	}
+	if failed != 1 || sunk > 2 {
+		t.Errorf("got %d sunk and %d failed items", sunk, failed)
+	}
+}
+
+func TestGroupByAndFlatMap(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	gen := nodes.NewGenerator(func(ctx context.Context) (<-chan string, error) {
+		out := make(chan string, 6)
+		for _, s := range []string{"ab", "c", "de", "fg", "h", "ijk"} {
+			out <- s
+		}
+		close(out)
+		return out, nil
+	})
+
+	// Группы по длине строки; группа из одного элемента ("ijk") отбрасывается
+	group := nodes.NewGroupBy(func(s string) int { return len(s) }, nodes.GroupConfig{MinItems: 2})
+	flatten := nodes.NewFlatMap(func(g nodes.Group[int, string]) ([]string, error) {
+		return []string{fmt.Sprint(g.Key, ":", strings.Join(g.Items, ","))}, nil
+	})
+
+	var got []string
+	sink := nodes.NewResultAggregator(func(s string) error {
+		got = append(got, s)
+		return nil
+	})
+
+	if err := pipelines.Connect(gen, group); err != nil {
+		t.Fatalf("Connect(gen, group) failed: %v", err)
+	}
+	if err := pipelines.Connect(group, flatten); err != nil {
+		t.Fatalf("Connect(group, flatten) failed: %v", err)
+	}
+	if err := pipelines.Connect(flatten, sink); err != nil {
+		t.Fatalf("Connect(flatten, sink) failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(gen, group, flatten, sink)
+	if err := p.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Группы выходят в порядке первого появления ключа
+	if want := []string{"2:ab,de,fg", "1:c,h"}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if st := group.(nodes.StatsProvider).Stats(); st.Processed != 6 || st.Emitted != 2 || st.Dropped != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	type file struct{ name, sum string }
+	slice := func(items ...file) nodes.Generator[file] {
+		return func(ctx context.Context) (<-chan file, error) {
+			out := make(chan file, len(items))
+			for _, it := range items {
+				out <- it
+			}
+			close(out)
+			return out, nil
+		}
+	}
+
+	// Снимок «вчера» и поток «сегодня»: b изменился, c удалён, d добавлен
+	gen := nodes.NewGenerator(slice(file{"a", "1"}, file{"b", "3"}, file{"d", "4"}))
+	diff := nodes.NewDiff(slice(file{"a", "1"}, file{"b", "2"}, file{"c", "5"}),
+		func(f file) string { return f.name },
+		func(old, new file) bool { return old.sum == new.sum })
+
+	var got []string
+	sink := nodes.NewResultAggregator(func(d nodes.Diff[file]) error {
+		got = append(got, d.Kind.String()+" "+d.Old.sum+">"+d.New.sum)
+		return nil
+	})
+
+	if err := pipelines.Connect(gen, diff); err != nil {
+		t.Fatalf("Connect(gen, diff) failed: %v", err)
+	}
+	if err := pipelines.Connect(diff, sink); err != nil {
+		t.Fatalf("Connect(diff, sink) failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(gen, diff, sink)
+	if err := p.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Удалённые выдаются последними, после закрытия входа
+	want := []string{"unchanged 1>1", "changed 2>3", "added >4", "removed 5>"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestFlatMapAckFanOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results := make(chan nodes.AckResult, 2)
+	gen := nodes.NewGenerator(nodes.AckGenerator(sources.Slice([]int{1}), func(_ int, res nodes.AckResult, _ error) {
+		results <- res
+	}))
+	// Сообщение уходит в обе ветки; итог известен только после ответа второй
+	flat := nodes.NewFlatMap(func(m nodes.Message[int]) ([]nodes.Message[int], error) {
+		return []nodes.Message[int]{m}, nil
+	})
+
+	errRejected := errors.New("rejected")
+	sunk := make(chan struct{})
+	ok := nodes.NewContextResultAggregator(func(ctx context.Context, m nodes.Message[int]) error {
+		defer close(sunk)
+		return nodes.AckSink(func(context.Context, int) error { return nil })(ctx, m)
+	})
+	reject := nodes.NewContextResultAggregator(nodes.AckSink(func(ctx context.Context, _ int) error {
+		select {
+		case <-sunk:
+		case <-ctx.Done():
+		}
+		return errRejected
+	}))
+
+	if err := pipelines.Connect(gen, flat); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := pipelines.ConnectToMany(flat, ok, reject); err != nil {
+		t.Fatalf("ConnectToMany failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(gen, flat, ok, reject)
+	if err := p.Run(ctx); !errors.Is(err, errRejected) {
+		t.Fatalf("expected errRejected, got %v", err)
+	}
+
+	close(results)
+	var got []nodes.AckResult
+	for res := range results {
+		got = append(got, res)
+	}
+	if !slices.Equal(got, []nodes.AckResult{nodes.AckFailed}) {
+		t.Fatalf("got outcomes %v, want a single failed", got)
+	}
+}
+
+func TestDiffRepeatedKeys(t *testing.T) {
+	type file struct{ name, sum string }
+
+	// Повтор ключа во входе выдаётся один раз, по первому элементу, даже если ключа нет в снимке
+	gen := nodes.NewGenerator(sources.Slice([]file{{"a", "1"}, {"a", "2"}, {"e", "3"}, {"e", "3"}}))
+	diff := nodes.NewDiff(sources.Slice([]file{{"a", "1"}}),
+		func(f file) string { return f.name },
+		func(old, new file) bool { return old.sum == new.sum })
+	got, sink := pipelinetest.Collect[nodes.Diff[file]]()
+	pipelinetest.Connect(t, gen, diff)
+	pipelinetest.Connect(t, diff, sink)
+	pipelinetest.Run(t, gen, diff, sink)
+
+	var kinds []string
+	for _, d := range got.Items() {
+		kinds = append(kinds, d.Kind.String()+" "+d.New.name+d.New.sum)
+	}
+	if want := []string{"unchanged a1", "added e3"}; !slices.Equal(kinds, want) {
+		t.Fatalf("got %v, want %v", kinds, want)
+	}
+	if st := diff.(nodes.StatsProvider).Stats(); st.Processed != 4 || st.Emitted != 2 || st.Dropped != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	// Паника в функции сравнения завершает ноду с *PanicError
+	gen = nodes.NewGenerator(sources.Slice([]file{{"a", "2"}}))
+	diff = nodes.NewDiff(sources.Slice([]file{{"a", "1"}}),
+		func(f file) string { return f.name },
+		func(old, new file) bool { panic("boom") })
+	_, sink = pipelinetest.Collect[nodes.Diff[file]]()
+	pipelinetest.Connect(t, gen, diff)
+	pipelinetest.Connect(t, diff, sink)
+	var panicErr *nodes.PanicError
+	if err := pipelinetest.RunError(t, gen, diff, sink); !errors.As(err, &panicErr) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+}
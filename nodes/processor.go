@@ -6,14 +6,42 @@ import "context"
 // Returns an error if processing fails.
 type Processor[In, Out any] func(In) (Out, error)
 
+// ContextProcessor is a Processor that also receives a per-item context derived from the context
+// passed to Run. The context is canceled when the pipeline stops or when Config.Timeout elapses.
+type ContextProcessor[In, Out any] func(context.Context, In) (Out, error)
+
 // ZipProcessor defines a function that processes a slice of inputs (one from each input channel)
 // and produces a single output of type Out. Returns an error if processing fails.
 type ZipProcessor[In, Out any] func([]In) (Out, error)
 
-// Sink defines a function that consumes an input of type In, typically producing a side effect
-// (e.g., writing to a file or printing). Returns an error if consumption fails.
-type Generator[Out any] func(context.Context) (<-chan Out, error)
+// ContextZipProcessor is a ZipProcessor that also receives a per-item context, see ContextProcessor.
+type ContextZipProcessor[In, Out any] func(context.Context, []In) (Out, error)
 
 // Generator defines a function that produces a channel of outputs of type Out.
 // It receives a context for cancellation. Returns an error if generation fails.
+type Generator[Out any] func(context.Context) (<-chan Out, error)
+
+// Sink defines a function that consumes an input of type In, typically producing a side effect
+// (e.g., writing to a file or printing). Returns an error if consumption fails.
 type Sink[In any] func(In) error
+
+// ContextSink is a Sink that also receives a per-item context, see ContextProcessor.
+type ContextSink[In any] func(context.Context, In) error
+
+func (p Processor[In, Out]) withContext() ContextProcessor[In, Out] {
+	return func(_ context.Context, in In) (Out, error) {
+		return p(in)
+	}
+}
+
+func (p ZipProcessor[In, Out]) withContext() ContextZipProcessor[In, Out] {
+	return func(_ context.Context, in []In) (Out, error) {
+		return p(in)
+	}
+}
+
+func (s Sink[In]) withContext() ContextSink[In] {
+	return func(_ context.Context, in In) error {
+		return s(in)
+	}
+}
@@ -0,0 +1,46 @@
+package nodes
+
+import (
+	"context"
+	"sync"
+)
+
+type reportKey struct{}
+
+// errorReport collects errors reported by a running Generator. id and rePanic describe the node
+// running it, for panics recovered by Produce.
+type errorReport struct {
+	id      string
+	rePanic bool
+
+	mu  sync.Mutex
+	err error
+}
+
+func withErrorReport(ctx context.Context, id string, cfg Config) (context.Context, *errorReport) {
+	r := &errorReport{id: id, rePanic: cfg.RePanic}
+	return context.WithValue(ctx, reportKey{}, r), r
+}
+
+func (r *errorReport) get() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// ReportError lets a Generator report an error that happens after it has returned its channel,
+// e.g. a failed read in the middle of a stream. ctx must be the context the Generator received.
+// The generator should then close its channel; the node's Run returns the first reported error.
+// Outside of a generator node ReportError does nothing.
+func ReportError(ctx context.Context, err error) {
+	r, ok := ctx.Value(reportKey{}).(*errorReport)
+	if !ok || err == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
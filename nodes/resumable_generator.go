@@ -0,0 +1,128 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
+)
+
+var (
+	_ pipelines.Node[any, any] = &resumableGenerator[any, int]{}
+	_ StatsProvider            = &resumableGenerator[any, int]{}
+)
+
+type resumableGenerator[Out any, K comparable] struct {
+	id uint64
+
+	out        []chan<- Out
+	generate   ResumableGenerator[Out]
+	checkpoint *Checkpoint[K]
+	key        func(Out) K
+	emitted    atomic.Uint64
+
+	config Config
+}
+
+// NewResumableGenerator creates a generator node that resumes from the Offset saved in cp.
+// Each emitted item is tracked in cp under key(item); once the downstream sink reports it
+// with Checkpoint.Done (see CheckpointSink), its Offset becomes eligible for saving.
+// Outputs are created with buffer size cfg.Buffer, as in NewGenerator.
+func NewResumableGenerator[Out any, K comparable](
+	gen ResumableGenerator[Out],
+	cp *Checkpoint[K],
+	key func(Out) K,
+	cfg ...Config,
+) pipelines.Node[any, Out] {
+	config := DefaultConfig()
+	if len(cfg) > 0 {
+		config = cfg[0]
+	}
+
+	return &resumableGenerator[Out, K]{
+		id:         nextNodeID(),
+		generate:   gen,
+		checkpoint: cp,
+		key:        key,
+		config:     config,
+	}
+}
+
+func (n *resumableGenerator[Out, K]) ID() string {
+	return fmt.Sprintf("resumable-generator-node-%d", n.id)
+}
+
+func (n *resumableGenerator[Out, K]) SetInput(in ...<-chan any) error {
+	return ErrHasNoInput
+}
+
+func (n *resumableGenerator[Out, K]) Output() (chan Out, error) {
+	out := make(chan Out, n.config.Buffer)
+	n.out = append(n.out, out)
+	return out, nil
+}
+
+func (n *resumableGenerator[Out, K]) Stats() Stats {
+	return Stats{Emitted: n.emitted.Load()}
+}
+
+func (n *resumableGenerator[Out, K]) Run(ctx context.Context) error {
+	defer utils.CloseChannels(n.out)
+
+	from, err := n.checkpoint.store.Load()
+	if err != nil {
+		return fmt.Errorf("%s: load checkpoint: %w", n.ID(), err)
+	}
+	n.checkpoint.start(n.config.Clock)
+
+	ctx, report := withErrorReport(ctx, n.ID(), n.config)
+	ch, err := n.start(ctx, from)
+	if err != nil {
+		return n.stop(fmt.Errorf("%s: %w", n.ID(), err))
+	}
+
+	for {
+		select {
+		case rec, open := <-ch:
+			if !open {
+				if err := report.get(); err != nil {
+					return n.stop(fmt.Errorf("%s: %w", n.ID(), err))
+				}
+				if ctx.Err() != nil {
+					return n.stop(ctx.Err())
+				}
+				return n.stop(nil)
+			}
+
+			k, err := apply(n.ID(), n.config, n.key, rec.Item)
+			if err != nil {
+				return n.stop(fmt.Errorf("%s: %w", n.ID(), err))
+			}
+			n.checkpoint.track(k, rec.Offset)
+			if err := broadcast(ctx, n.out, rec.Item); err != nil {
+				return n.stop(err)
+			}
+			n.emitted.Add(1)
+		case <-ctx.Done():
+			return n.stop(ctx.Err())
+		}
+	}
+}
+
+// stop saves the offset committed so far once the run is over, with err if it failed.
+func (n *resumableGenerator[Out, K]) stop(err error) error {
+	if serr := n.checkpoint.stop(); serr != nil {
+		return errors.Join(err, fmt.Errorf("%s: save checkpoint: %w", n.ID(), serr))
+	}
+	return err
+}
+
+func (n *resumableGenerator[Out, K]) start(ctx context.Context, from Offset) (ch <-chan Positioned[Out], err error) {
+	if !n.config.RePanic {
+		defer recoverPanic(n.ID(), &err)
+	}
+	return n.generate(ctx, from)
+}
@@ -0,0 +1,327 @@
+package nodes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/codec"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
+)
+
+var (
+	_ pipelines.Node[any, any] = &spillBuffer[any]{}
+	_ StatsProvider            = &spillBuffer[any]{}
+)
+
+// SpillConfig configures a spill buffer. Zero fields take the defaults listed below.
+type SpillConfig struct {
+	// MemoryItems is the number of items kept in memory before spilling to disk (default 1000).
+	MemoryItems int
+	// SegmentItems is the number of items per segment file (default 10000).
+	SegmentItems int
+	// Dir is the parent directory for segment files (default os.TempDir()).
+	Dir string
+	// Codec encodes spilled items (default codec.Gob).
+	Codec codec.Codec
+}
+
+type spillBuffer[T any] struct {
+	id uint64
+
+	in  []<-chan T
+	out chan<- T
+
+	spill SpillConfig
+
+	processed atomic.Uint64
+	emitted   atomic.Uint64
+	dropped   atomic.Uint64
+
+	config Config
+}
+
+// NewSpillBuffer creates a node that decouples a fast producer from a slow consumer without
+// blocking the producer. It keeps up to scfg.MemoryItems items in memory and spills the
+// overflow to temporary segment files encoded with scfg.Codec, replaying them in order.
+// The node has a single output; its segment directory is removed when Run returns. Without
+// an output it discards its input, dropping Messages.
+//
+// Codecs only encode exported fields, so the acknowledgement handle of a Message is kept in
+// memory while its value is on disk, at the cost of one pointer per spilled Message.
+func NewSpillBuffer[T any](scfg SpillConfig, cfg ...Config) pipelines.Node[T, T] {
+	config := DefaultConfig()
+	if len(cfg) > 0 {
+		config = cfg[0]
+	}
+
+	if scfg.MemoryItems <= 0 {
+		scfg.MemoryItems = 1000
+	}
+	if scfg.SegmentItems <= 0 {
+		scfg.SegmentItems = 10000
+	}
+	if scfg.Codec == nil {
+		scfg.Codec = codec.Gob
+	}
+
+	return &spillBuffer[T]{
+		id:     nextNodeID(),
+		spill:  scfg,
+		config: config,
+	}
+}
+
+func (n *spillBuffer[T]) ID() string {
+	return fmt.Sprintf("spill-buffer-node-%d", n.id)
+}
+
+func (n *spillBuffer[T]) SetInput(in ...<-chan T) error {
+	n.in = append(n.in, in...)
+	return nil
+}
+
+func (n *spillBuffer[T]) Output() (chan T, error) {
+	if n.out != nil {
+		return nil, ErrOnlyOneOutput
+	}
+	out := make(chan T, n.config.Buffer)
+	n.out = out
+	return out, nil
+}
+
+// Stats counts the items discarded without an output as dropped.
+func (n *spillBuffer[T]) Stats() Stats {
+	return Stats{
+		Processed: n.processed.Load(),
+		Emitted:   n.emitted.Load(),
+		Dropped:   n.dropped.Load(),
+	}
+}
+
+func (n *spillBuffer[T]) Run(ctx context.Context) (err error) {
+	in, err := utils.FanIn(ctx, n.in, n.config.InBuffer)
+	if err != nil {
+		return err
+	}
+
+	if n.out == nil {
+		for data := range in {
+			n.processed.Add(1)
+			n.dropped.Add(1)
+			if d, ok := any(data).(dropper); ok {
+				d.Drop()
+			}
+		}
+		return ctx.Err()
+	}
+	defer close(n.out)
+
+	dir, err := os.MkdirTemp(n.spill.Dir, "pipelines-spill-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", n.ID(), err)
+	}
+	disk := &spillQueue[T]{dir: dir, cfg: n.spill}
+	defer func() {
+		if cerr := disk.close(); cerr != nil && err == nil {
+			err = fmt.Errorf("%s: %w", n.ID(), cerr)
+		}
+	}()
+
+	// items are ordered as memory, then disk; once something is on disk,
+	// new items go to disk as well until it is drained
+	var (
+		memory  []T
+		next    T
+		hasNext bool
+	)
+
+	for {
+		if !hasNext {
+			switch {
+			case len(memory) > 0:
+				next, memory = memory[0], memory[1:]
+				hasNext = true
+			case disk.len() > 0:
+				if next, err = disk.pop(); err != nil {
+					return fmt.Errorf("%s: %w", n.ID(), err)
+				}
+				hasNext = true
+			}
+		}
+
+		if in == nil && !hasNext {
+			return nil
+		}
+
+		var out chan<- T
+		if hasNext {
+			out = n.out
+		}
+
+		select {
+		case data, open := <-in:
+			if !open {
+				in = nil
+				continue
+			}
+			n.processed.Add(1)
+			if disk.len() == 0 && len(memory) < n.spill.MemoryItems {
+				memory = append(memory, data)
+				continue
+			}
+			if err := disk.push(data); err != nil {
+				return fmt.Errorf("%s: %w", n.ID(), err)
+			}
+		case out <- next:
+			hasNext = false
+			n.emitted.Add(1)
+		case <-ctx.Done():
+			if hasNext {
+				nack(next, ctx.Err())
+			}
+			for _, v := range memory {
+				nack(v, ctx.Err())
+			}
+			disk.nackAll(ctx.Err())
+			return ctx.Err()
+		}
+	}
+}
+
+// spillSegment is one file of the on-disk queue.
+type spillSegment struct {
+	path  string
+	file  *os.File
+	buf   *bufio.Writer
+	enc   codec.Encoder
+	dec   codec.Decoder
+	count int
+}
+
+// spillQueue is a FIFO of items stored in segment files. Items are appended to the
+// active segment; full segments are queued for reading and deleted once consumed.
+type spillQueue[T any] struct {
+	dir string
+	cfg SpillConfig
+
+	writer  *spillSegment
+	closed  []*spillSegment
+	reader  *spillSegment
+	seq     int
+	size    int
+	handles []*ackHandle // of the spilled Messages, in queue order
+}
+
+func (q *spillQueue[T]) len() int {
+	return q.size
+}
+
+func (q *spillQueue[T]) push(v T) error {
+	if q.writer == nil {
+		q.seq++
+		path := filepath.Join(q.dir, fmt.Sprintf("segment-%08d", q.seq))
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		buf := bufio.NewWriter(f)
+		q.writer = &spillSegment{path: path, file: f, buf: buf, enc: q.cfg.Codec.NewEncoder(buf)}
+	}
+
+	if err := q.writer.enc.Encode(v); err != nil {
+		return err
+	}
+	if m, ok := any(v).(ackCarrier); ok {
+		q.handles = append(q.handles, m.ackRef())
+	}
+	q.writer.count++
+	q.size++
+
+	if q.writer.count >= q.cfg.SegmentItems {
+		return q.rotate()
+	}
+	return nil
+}
+
+// nackAll Nacks the spilled Messages without reading them back.
+func (q *spillQueue[T]) nackAll(err error) {
+	for _, h := range q.handles {
+		h.finish(AckFailed, err)
+	}
+	q.handles = nil
+}
+
+// rotate finishes the active segment and queues it for reading.
+func (q *spillQueue[T]) rotate() error {
+	seg := q.writer
+	q.writer = nil
+
+	if err := seg.buf.Flush(); err != nil {
+		return err
+	}
+	if err := seg.file.Close(); err != nil {
+		return err
+	}
+	seg.file, seg.buf, seg.enc = nil, nil, nil
+	q.closed = append(q.closed, seg)
+	return nil
+}
+
+func (q *spillQueue[T]) pop() (T, error) {
+	var v T
+
+	if q.reader == nil {
+		if len(q.closed) == 0 {
+			// only the active segment holds items: finish it so it can be read
+			if err := q.rotate(); err != nil {
+				return v, err
+			}
+		}
+		seg := q.closed[0]
+		q.closed = q.closed[1:]
+
+		f, err := os.Open(seg.path)
+		if err != nil {
+			return v, err
+		}
+		seg.file = f
+		seg.dec = q.cfg.Codec.NewDecoder(bufio.NewReader(f))
+		q.reader = seg
+	}
+
+	if err := q.reader.dec.Decode(&v); err != nil {
+		return v, err
+	}
+	if m, ok := any(v).(ackCarrier); ok {
+		v = m.withAckRef(q.handles[0]).(T)
+		q.handles[0] = nil
+		q.handles = q.handles[1:]
+	}
+	q.reader.count--
+	q.size--
+
+	if q.reader.count == 0 {
+		seg := q.reader
+		q.reader = nil
+		seg.file.Close()
+		if err := os.Remove(seg.path); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// close releases open files and removes the segment directory.
+func (q *spillQueue[T]) close() error {
+	for _, seg := range []*spillSegment{q.writer, q.reader} {
+		if seg != nil && seg.file != nil {
+			seg.file.Close()
+		}
+	}
+	return os.RemoveAll(q.dir)
+}
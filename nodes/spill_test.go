@@ -0,0 +1,110 @@
+package nodes_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/codec"
+)
+
+// startSpill запускает буфер вручную: тест пишет во вход, не читая выход, чтобы буфер ушёл на диск.
+func startSpill[T any](t *testing.T, node pipelines.Node[T, T]) (in chan T, out chan T, errc chan error) {
+	t.Helper()
+
+	in = make(chan T)
+	if err := node.SetInput(in); err != nil {
+		t.Fatalf("SetInput failed: %v", err)
+	}
+	out, err := node.Output()
+	if err != nil {
+		t.Fatalf("Output failed: %v", err)
+	}
+	errc = make(chan error, 1)
+	go func() { errc <- node.Run(t.Context()) }()
+	return in, out, errc
+}
+
+func TestSpillBufferReplaysInOrder(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	dir := t.TempDir()
+	node := nodes.NewSpillBuffer[int](nodes.SpillConfig{MemoryItems: 5, SegmentItems: 7, Dir: dir}, nodes.Config{})
+	in, out, errc := startSpill(t, node)
+
+	// Производитель не ждёт потребителя: всё, что не влезло в память, лежит в сегментах
+	var want []int
+	for i := range 100 {
+		in <- i
+		want = append(want, i)
+	}
+	close(in)
+	if segments, _ := filepath.Glob(filepath.Join(dir, "*", "segment-*")); len(segments) == 0 {
+		t.Fatalf("nothing was spilled to %s", dir)
+	}
+
+	var got []int
+	for x := range out {
+		got = append(got, x)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	pipelinetest.Equal(t, got, want)
+
+	// После Run временные файлы удалены
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("spill directory left behind: %v", entries)
+	}
+}
+
+func TestSpillBufferKeepsAckHandles(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	var acked atomic.Int64
+	node := nodes.NewSpillBuffer[nodes.Message[string]](nodes.SpillConfig{MemoryItems: 1, SegmentItems: 2, Dir: t.TempDir(), Codec: codec.JSON}, nodes.Config{})
+	in, out, errc := startSpill(t, node)
+
+	// Обработчик подтверждения не кодируется, но должен пережить путь через диск
+	words := []string{"a", "b", "c", "d", "e"}
+	for _, w := range words {
+		in <- nodes.NewMessage(w, func(res nodes.AckResult, err error) {
+			if res == nodes.AckSunk {
+				acked.Add(1)
+			}
+		})
+	}
+	close(in)
+
+	var got []string
+	for m := range out {
+		got = append(got, m.Value)
+		m.Ack()
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	pipelinetest.Equal(t, got, words)
+	if n := acked.Load(); n != int64(len(words)) {
+		t.Errorf("%d of %d messages acknowledged", n, len(words))
+	}
+}
+
+func TestSpillBufferWithoutOutput(t *testing.T) {
+	// Без Output буфер просто потребляет вход, а не паникует на закрытии nil-канала
+	node := nodes.NewSpillBuffer[int](nodes.SpillConfig{Dir: t.TempDir()})
+	in := make(chan int, 3)
+	in <- 1
+	in <- 2
+	close(in)
+	if err := node.SetInput(in); err != nil {
+		t.Fatalf("SetInput failed: %v", err)
+	}
+	if err := node.Run(t.Context()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
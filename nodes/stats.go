@@ -0,0 +1,19 @@
+package nodes
+
+// Stats is a point-in-time snapshot of a node's runtime counters.
+type Stats struct {
+	// Processed is the number of items taken from the node inputs.
+	Processed uint64
+	// Emitted is the number of results sent to the node outputs.
+	Emitted uint64
+	// Dropped is the number of items intentionally discarded by the node (e.g. duplicates).
+	Dropped uint64
+	// Workers is the number of currently running worker goroutines.
+	Workers int
+}
+
+// StatsProvider is implemented by nodes that expose runtime statistics.
+// Stats is safe to call concurrently with Run.
+type StatsProvider interface {
+	Stats() Stats
+}
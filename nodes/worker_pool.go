@@ -4,34 +4,95 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Sergey-Polishchenko/pipelines"
 )
 
-var _ pipelines.Node[any, any] = &workerPool[any, any]{}
+var (
+	_ pipelines.Node[any, any] = &workerPool[any, any]{}
+	_ StatsProvider            = &workerPool[any, any]{}
+)
 
 type workerPool[In, Out any] struct {
 	id uint64
 
 	in      <-chan In
 	out     chan<- Out
-	process Processor[In, Out]
+	process ContextProcessor[In, Out]
+
+	// runtime counters, see Stats and autoscale
+	workers   atomic.Int64
+	busy      atomic.Int64
+	processed atomic.Uint64
+	emitted   atomic.Uint64
+	completed atomic.Uint64 // items whose processing has finished
+	latency   atomic.Int64  // summed processing time of completed items since the last autoscale tick
 
 	config Config
 }
 
+// poolRun is the state of a single Run, so that a pool can be run again.
+type poolRun[Out any] struct {
+	ctx     context.Context
+	errChan chan error
+	wg      sync.WaitGroup
+
+	quit      chan struct{}
+	drained   chan struct{}
+	drainOnce sync.Once
+
+	// Ordered mode: take numbers items under recvMu and holds a slot for each until emit sends
+	// it, which bounds the results waiting in pending for an earlier, slower item.
+	recvMu   sync.Mutex
+	next     uint64
+	slots    chan struct{}
+	emitMu   sync.Mutex
+	emitNext uint64
+	pending  map[uint64]Out
+}
+
+// releasePending Nacks the results that a canceled run will never send. emitMu must be held.
+func (r *poolRun[Out]) releasePending() {
+	for seq, result := range r.pending {
+		nack(result, r.ctx.Err())
+		delete(r.pending, seq)
+	}
+}
+
 // NewWorkerPool creates a node that processes inputs using a pool of worker goroutines.
 // It accepts exactly one input channel, and spawns cfg.Workers concurrent goroutines,
 // each applying the Processor function to incoming elements. Results are sent to a single output channel
 // buffered with size cfg.Workers. If more than one input channel is set via SetInput, returns an error.
+//
+// If cfg.MaxWorkers is set, the pool scales between cfg.MinWorkers and cfg.MaxWorkers
+// depending on the input backlog and processing latency, see Config. If cfg.Ordered is set,
+// results are emitted in input order.
 func NewWorkerPool[In, Out any](
 	proc Processor[In, Out],
 	cfg ...Config,
+) pipelines.Node[In, Out] {
+	return NewContextWorkerPool(proc.withContext(), cfg...)
+}
+
+// NewContextWorkerPool is like NewWorkerPool, but the ContextProcessor receives a per-item context
+// that is canceled when the pipeline stops or cfg.Timeout elapses. If cfg.Workers and cfg.MaxWorkers
+// are both zero, the pool runs DefaultConfig().Workers workers; the other fields of cfg apply as given.
+func NewContextWorkerPool[In, Out any](
+	proc ContextProcessor[In, Out],
+	cfg ...Config,
 ) pipelines.Node[In, Out] {
 	config := DefaultConfig()
-	if len(cfg) > 0 && cfg[0].Workers > 0 {
+	if len(cfg) > 0 {
 		config = cfg[0]
+		if config.Workers <= 0 && config.MaxWorkers <= 0 {
+			config.Workers = DefaultConfig().Workers
+		}
+		config = config.normalizeWorkers()
 	}
+	config.Clock = clockOr(config.Clock)
+
 	return &workerPool[In, Out]{
 		id:      nextNodeID(),
 		process: proc,
@@ -57,65 +118,234 @@ func (n *workerPool[In, Out]) Output() (chan Out, error) {
 	return out, nil
 }
 
+func (n *workerPool[In, Out]) Stats() Stats {
+	return Stats{
+		Processed: n.processed.Load(),
+		Emitted:   n.emitted.Load(),
+		Workers:   int(n.workers.Load()),
+	}
+}
+
+// Run returns once the pool has finished, failed or ctx is canceled. Its output is closed when
+// the last worker exits, which for a processor that ignores its context may happen later.
 func (n *workerPool[In, Out]) Run(ctx context.Context) error {
-	defer close(n.out)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r := &poolRun[Out]{
+		ctx:     ctx,
+		errChan: make(chan error, 1),
+		quit:    make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+	if n.config.Ordered {
+		r.slots = make(chan struct{}, 2*max(n.config.Workers, n.config.MaxWorkers))
+		r.pending = make(map[uint64]Out)
+	}
 
-	errChan := make(chan error, n.config.Workers)
+	n.latency.Store(0)
+	completed := n.completed.Load()
 
-	var wg sync.WaitGroup
-	wg.Add(n.config.Workers)
+	for range n.config.Workers {
+		n.spawn(r)
+	}
 
-	for i := 0; i < n.config.Workers; i++ {
-		go n.runWorker(ctx, errChan, &wg)
+	if n.config.MaxWorkers > 0 {
+		// the autoscaler holds its own slot, so wg.Add in spawn never races with wg.Wait
+		r.wg.Add(1)
+		go n.autoscale(r, completed)
 	}
 
+	// workers may still send until they exit, so only the last one out closes the output
 	done := make(chan struct{})
 	go func() {
-		wg.Wait()
+		r.wg.Wait()
+		close(n.out)
 		close(done)
 	}()
 
 	select {
-	case err := <-errChan:
+	case err := <-r.errChan:
 		return fmt.Errorf("%s: %w", n.ID(), err)
+	case <-done:
 	case <-ctx.Done():
+	}
+
+	select {
+	case err := <-r.errChan:
+		return fmt.Errorf("%s: %w", n.ID(), err)
+	default:
 		return ctx.Err()
-	case <-done:
-		return nil
 	}
 }
 
-func (n *workerPool[In, Out]) runWorker(
-	ctx context.Context,
-	errChan chan<- error,
-	wg *sync.WaitGroup,
-) {
-	defer wg.Done()
+func (n *workerPool[In, Out]) spawn(r *poolRun[Out]) {
+	r.wg.Add(1)
+	n.workers.Add(1)
+	go n.runWorker(r)
+}
+
+func (n *workerPool[In, Out]) runWorker(r *poolRun[Out]) {
+	defer r.wg.Done()
 
 	for {
-		select {
-		case data, ok := <-n.in:
-			if !ok {
-				return
-			}
+		data, seq, ok := n.take(r)
+		if !ok {
+			return
+		}
+		n.processed.Add(1)
 
-			result, err := n.process(data)
-			if err != nil {
-				select {
-				case errChan <- err:
-				default:
-				}
-				return
-			}
+		n.busy.Add(1)
+		start := n.config.Clock.Now()
+		result, err := call(r.ctx, n.ID(), n.config, func(ctx context.Context) (Out, error) {
+			return n.process(ctx, data)
+		})
+		n.latency.Add(int64(n.config.Clock.Now().Sub(start)))
+		n.completed.Add(1)
+		n.busy.Add(-1)
 
+		if err != nil {
+			n.workers.Add(-1)
 			select {
-			case n.out <- result:
-			case <-ctx.Done():
-				return
+			case r.errChan <- err:
+			default:
+			}
+			return
+		}
+
+		if !n.emit(r, seq, result) {
+			n.workers.Add(-1)
+			return
+		}
+	}
+}
+
+// take receives the next item and, in ordered mode, its sequence number. It reports false when
+// the worker should exit: the input is closed, the worker is retired or the run is canceled.
+// The exiting worker is taken off the count, by the autoscaler if it was retired, so that the
+// autoscaler never sees a retired worker as running.
+func (n *workerPool[In, Out]) take(r *poolRun[Out]) (data In, seq uint64, ok bool) {
+	if n.config.Ordered {
+		r.recvMu.Lock()
+		defer r.recvMu.Unlock()
+
+		select {
+		case r.slots <- struct{}{}:
+		case <-r.quit:
+			return data, 0, false
+		case <-r.ctx.Done():
+			n.workers.Add(-1)
+			return data, 0, false
+		}
+	}
+
+	select {
+	case data, open := <-n.in:
+		if open {
+			if n.config.Ordered {
+				seq = r.next
+				r.next++
 			}
+			return data, seq, true
+		}
+		r.drainOnce.Do(func() { close(r.drained) })
+		n.workers.Add(-1)
+	case <-r.quit:
+	case <-r.ctx.Done():
+		n.workers.Add(-1)
+	}
+
+	if n.config.Ordered {
+		<-r.slots
+	}
+	return data, 0, false
+}
+
+// emit sends a result, in ordered mode together with the results of later items that were
+// waiting for it. It reports false if the run is canceled; results that were not sent are
+// Nack'ed if they are Messages.
+func (n *workerPool[In, Out]) emit(r *poolRun[Out], seq uint64, result Out) bool {
+	if !n.config.Ordered {
+		select {
+		case n.out <- result:
+			n.emitted.Add(1)
+			return true
+		case <-r.ctx.Done():
+			nack(result, r.ctx.Err())
+			return false
+		}
+	}
+
+	r.emitMu.Lock()
+	defer r.emitMu.Unlock()
 
-		case <-ctx.Done():
+	r.pending[seq] = result
+	// after cancellation nothing is sent, and results held back by a slower earlier item are
+	// released by whichever emit comes last
+	if r.ctx.Err() != nil {
+		r.releasePending()
+		return false
+	}
+	for {
+		result, ok := r.pending[r.emitNext]
+		if !ok {
+			return true
+		}
+		select {
+		case n.out <- result:
+		case <-r.ctx.Done():
+			r.releasePending()
+			return false
+		}
+		delete(r.pending, r.emitNext)
+		r.emitNext++
+		n.emitted.Add(1)
+		<-r.slots
+	}
+}
+
+// autoscale periodically adjusts the number of workers between MinWorkers and MaxWorkers.
+// Every ScaleCooldown it grows the pool when all workers are busy and the input backs up,
+// or when the mean latency of the items completed since the last tick exceeds ScaleLatency;
+// it shrinks the pool by one worker when some workers sit idle and the input is empty.
+// At most one adjustment is made per tick.
+func (n *workerPool[In, Out]) autoscale(r *poolRun[Out], lastCompleted uint64) {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-n.config.Clock.After(n.config.ScaleCooldown):
+		case <-r.drained:
+			return
+		case <-r.ctx.Done():
 			return
 		}
+
+		completed := n.completed.Load()
+		var meanLatency time.Duration
+		if d := completed - lastCompleted; d > 0 {
+			meanLatency = time.Duration(n.latency.Swap(0) / int64(d))
+		}
+		lastCompleted = completed
+
+		workers := int(n.workers.Load())
+		busy := int(n.busy.Load())
+		queued := len(n.in)
+		backlog := busy >= workers && (queued > 0 || cap(n.in) == 0)
+		slow := n.config.ScaleLatency > 0 && meanLatency > n.config.ScaleLatency
+
+		switch {
+		case (backlog || slow) && workers < n.config.MaxWorkers:
+			grow := min(max(queued, 1), n.config.MaxWorkers-workers)
+			for range grow {
+				n.spawn(r)
+			}
+		case busy < workers && queued == 0 && workers > n.config.MinWorkers:
+			select {
+			case r.quit <- struct{}{}:
+				n.workers.Add(-1)
+			default:
+			}
+		}
 	}
 }
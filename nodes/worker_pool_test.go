@@ -0,0 +1,223 @@
+package nodes_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+)
+
+// startPool запускает пул вручную, чтобы тест сам управлял его входом.
+func startPool(t *testing.T, pool pipelines.Node[int, int]) (in chan int, out chan int, errc chan error) {
+	t.Helper()
+
+	in = make(chan int)
+	if err := pool.SetInput(in); err != nil {
+		t.Fatalf("SetInput failed: %v", err)
+	}
+	out, err := pool.Output()
+	if err != nil {
+		t.Fatalf("Output failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+	errc = make(chan error, 1)
+	go func() { errc <- pool.Run(ctx) }()
+	return in, out, errc
+}
+
+func TestWorkerPoolAutoscale(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	started := make(chan int)
+	var release chan struct{}
+	pool := nodes.NewWorkerPool(func(x int) (int, error) {
+		started <- x
+		<-release
+		return x, nil
+	}, nodes.Config{MinWorkers: 1, MaxWorkers: 3, ScaleCooldown: time.Second, Clock: clock})
+	stats := pool.(nodes.StatsProvider)
+
+	// tick проводит один цикл автоскейлера: решение принято, когда он снова ждёт часы
+	tick := func() {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		clock.BlockUntil(1)
+	}
+	expectWorkers := func(step string, want int) {
+		t.Helper()
+		if got := stats.Stats().Workers; got != want {
+			t.Fatalf("%s: got %d workers, want %d", step, got, want)
+		}
+	}
+
+	// Пул запускается дважды: состояние масштабирования создаётся заново на каждый Run
+	for range 2 {
+		release = make(chan struct{})
+		in, out, errc := startPool(t, pool)
+
+		in <- 1
+		<-started
+		expectWorkers("start", 1)
+
+		// Все воркеры заняты, вход ждёт — пул растёт
+		tick()
+		expectWorkers("grow", 2)
+
+		// До конца паузы решений нет; если бы тик сработал, BlockUntil дождался бы роста
+		in <- 2
+		<-started
+		clock.Advance(999 * time.Millisecond)
+		clock.BlockUntil(1)
+		expectWorkers("cooldown", 2)
+
+		clock.Advance(time.Millisecond)
+		clock.BlockUntil(1)
+		expectWorkers("after cooldown", 3)
+
+		// Больше MaxWorkers не растёт
+		in <- 3
+		<-started
+		tick()
+		expectWorkers("max", 3)
+
+		// Простаивающие воркеры уходят по одному за тик, но не меньше MinWorkers
+		close(release)
+		for range 3 {
+			<-out
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for stats.Stats().Workers > 1 && time.Now().Before(deadline) {
+			tick()
+		}
+		expectWorkers("shrink", 1)
+		for range 3 {
+			tick()
+		}
+		expectWorkers("min", 1)
+
+		close(in)
+		if err := <-errc; err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if _, open := <-out; open {
+			t.Fatalf("output is not closed after Run")
+		}
+		// Автоскейлер завершился, не дождавшись часов: убираем его ожидание перед следующим Run
+		clock.Advance(time.Second)
+	}
+
+	if s := stats.Stats(); s.Processed != 6 || s.Emitted != 6 {
+		t.Errorf("got %d processed and %d emitted items, want 6 and 6", s.Processed, s.Emitted)
+	}
+}
+
+func TestWorkerPoolAutoscaleLatency(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	started := make(chan int)
+	release := make(chan struct{})
+	pool := nodes.NewWorkerPool(func(x int) (int, error) {
+		if x == 1 {
+			started <- x
+			<-release
+		} else {
+			clock.Advance(200 * time.Millisecond)
+		}
+		return x, nil
+	}, nodes.Config{Workers: 2, MinWorkers: 1, MaxWorkers: 3, ScaleCooldown: time.Second, ScaleLatency: 100 * time.Millisecond, Clock: clock})
+	stats := pool.(nodes.StatsProvider)
+	in, out, errc := startPool(t, pool)
+
+	// Элемент 1 ещё в работе и не должен занижать среднюю задержку завершённого элемента 2
+	in <- 1
+	<-started
+	in <- 2
+	<-out
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	if got := stats.Stats().Workers; got != 3 {
+		t.Fatalf("got %d workers after a slow item, want 3", got)
+	}
+
+	close(release)
+	<-out
+	close(in)
+	if err := <-errc; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestWorkerPoolOrdered(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	var items []int
+	for i := range 50 {
+		items = append(items, i)
+	}
+
+	// Поздние элементы обрабатываются быстрее ранних, но выход сохраняет порядок входа
+	src := pipelinetest.Source(items...)
+	pool := nodes.NewWorkerPool(func(x int) (int, error) {
+		time.Sleep(time.Duration(5-x%5) * time.Millisecond)
+		return x, nil
+	}, nodes.Config{Workers: 4, Ordered: true})
+	got, sink := pipelinetest.Collect[int]()
+	pipelinetest.Connect(t, src, pool)
+	pipelinetest.Connect(t, pool, sink)
+
+	pipelinetest.Run(t, src, pool, sink)
+	pipelinetest.Equal(t, got.Items(), items)
+}
+
+func TestWorkerPoolCancelWithStuckProcessor(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	// Процессор не смотрит на контекст: Run всё равно должен вернуться после отмены
+	started := make(chan struct{})
+	release := make(chan struct{})
+	pool := nodes.NewWorkerPool(func(x int) (int, error) {
+		close(started)
+		<-release
+		return x, nil
+	}, nodes.Config{Workers: 1})
+
+	in := make(chan int, 1)
+	in <- 1
+	if err := pool.SetInput(in); err != nil {
+		t.Fatalf("SetInput failed: %v", err)
+	}
+	out, err := pool.Output()
+	if err != nil {
+		t.Fatalf("Output failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errc := make(chan error, 1)
+	go func() { errc <- pool.Run(ctx) }()
+
+	<-started
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run did not return after cancel")
+	}
+
+	// Выход закрывается, когда застрявший воркер всё-таки завершится
+	close(release)
+	for range out {
+	}
+}
@@ -3,19 +3,26 @@ package nodes
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/Sergey-Polishchenko/pipelines"
 	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
 )
 
-var _ pipelines.Node[any, any] = &zip[any, any]{}
+var (
+	_ pipelines.Node[any, any] = &zip[any, any]{}
+	_ StatsProvider            = &zip[any, any]{}
+)
 
 type zip[In, Out any] struct {
 	id uint64
 
 	in      []<-chan In
 	out     []chan<- Out
-	process ZipProcessor[In, Out]
+	process ContextZipProcessor[In, Out]
+
+	processed atomic.Uint64
+	emitted   atomic.Uint64
 
 	config Config
 }
@@ -25,6 +32,15 @@ type zip[In, Out any] struct {
 // output channels, each created with buffer size cfg.Buffer. If any input channel is closed prematurely,
 // Run returns an error.
 func NewZip[In, Out any](proc ZipProcessor[In, Out], cfg ...Config) pipelines.Node[In, Out] {
+	return NewContextZip(proc.withContext(), cfg...)
+}
+
+// NewContextZip is like NewZip, but the ContextZipProcessor receives a per-item context that is
+// canceled when the pipeline stops or cfg.Timeout elapses.
+func NewContextZip[In, Out any](
+	proc ContextZipProcessor[In, Out],
+	cfg ...Config,
+) pipelines.Node[In, Out] {
 	config := DefaultConfig()
 	if len(cfg) > 0 {
 		config = cfg[0]
@@ -53,6 +69,14 @@ func (n *zip[In, Out]) Output() (chan Out, error) {
 	return out, nil
 }
 
+// Stats counts each set of inputs, one element from every input channel, as one processed item.
+func (n *zip[In, Out]) Stats() Stats {
+	return Stats{
+		Processed: n.processed.Load(),
+		Emitted:   n.emitted.Load(),
+	}
+}
+
 func (n *zip[In, Out]) Run(ctx context.Context) error {
 	if len(n.in) == 0 {
 		return ErrZipNodeNoInput
@@ -78,7 +102,11 @@ func (n *zip[In, Out]) Run(ctx context.Context) error {
 			}
 		}
 
-		res, err := n.process(inputs)
+		n.processed.Add(1)
+
+		res, err := call(ctx, n.ID(), n.config, func(ctx context.Context) (Out, error) {
+			return n.process(ctx, inputs)
+		})
 		if err != nil {
 			return fmt.Errorf("%s: %w", n.ID(), err)
 		}
@@ -86,5 +114,6 @@ func (n *zip[In, Out]) Run(ctx context.Context) error {
 		if err := utils.Broadcast(ctx, n.out, res); err != nil {
 			return err
 		}
+		n.emitted.Add(1)
 	}
 }
@@ -0,0 +1,66 @@
+package pipelinetest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Equal reports an error unless got and want have equal elements in the same order, as
+// compared by reflect.DeepEqual. Use it for outputs whose order is defined, e.g. of a single
+// node with one worker.
+func Equal[T any](t testing.TB, got, want []T) {
+	t.Helper()
+
+	for i := range min(len(got), len(want)) {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("pipelinetest: element %d differs:\ngot:  %s\nwant: %s", i, show(got), show(want))
+			return
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("pipelinetest: got %d elements, want %d:\ngot:  %s\nwant: %s", len(got), len(want), show(got), show(want))
+	}
+}
+
+// EqualUnordered reports an error unless got and want have the same elements, as compared by
+// reflect.DeepEqual, in any order and with the same number of repetitions. Use it for the
+// output of worker pools and fan-ins.
+func EqualUnordered[T any](t testing.TB, got, want []T) {
+	t.Helper()
+
+	matched := make([]bool, len(got))
+	var missing []T
+	for _, w := range want {
+		found := false
+		for i, g := range got {
+			if !matched[i] && reflect.DeepEqual(g, w) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, w)
+		}
+	}
+
+	var extra []T
+	for i, g := range got {
+		if !matched[i] {
+			extra = append(extra, g)
+		}
+	}
+
+	if len(missing) > 0 || len(extra) > 0 {
+		t.Errorf("pipelinetest: elements differ regardless of order:\nmissing: %s\nextra:   %s", show(missing), show(extra))
+	}
+}
+
+func show[T any](items []T) string {
+	parts := make([]string, len(items))
+	for i, it := range items {
+		parts[i] = fmt.Sprintf("%+v", it)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
@@ -0,0 +1,86 @@
+package pipelinetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+var _ nodes.Clock = &FakeClock{}
+
+// FakeClock is a nodes.Clock that only moves when Advance is called. Set it as the Clock of
+// DedupConfig or BreakerConfig to test TTLs and timeouts without sleeping. It is safe for
+// concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to start, or to 2000-01-01 UTC if start is zero.
+func NewFakeClock(start time.Time) *FakeClock {
+	if start.IsZero() {
+		start = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the time once the clock has been advanced by d. If d
+// is not positive, the channel is ready at once.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	c.cond.Broadcast()
+	return ch
+}
+
+// Advance moves the clock forward by d and fires the After channels that became due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	clear(c.waiters[len(pending):])
+	c.waiters = pending
+}
+
+// BlockUntil waits until at least n After channels are waiting for the clock to advance. Call
+// it before Advance when a node must have started waiting first, e.g. a CircuitBreaker with
+// BreakerPark holding an element until OpenTimeout passes.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
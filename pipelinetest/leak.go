@@ -0,0 +1,74 @@
+package pipelinetest
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// modulePath marks the goroutines VerifyNoLeaks checks: those running or created by code of this
+// module, such as FanIn and worker goroutines, or by the test itself.
+const modulePath = "github.com/Sergey-Polishchenko/pipelines"
+
+// leakTimeout is how long VerifyNoLeaks waits for goroutines to exit.
+const leakTimeout = time.Second
+
+// VerifyNoLeaks fails the test if goroutines started during the test by code of this module are
+// still running when it ends. Call it at the start of the test, before its own cleanups are
+// registered, so that it runs after them. Goroutines get a second to exit, since a node may
+// return from Run a moment before its helpers do. It does not work with parallel tests, whose
+// goroutines it cannot tell apart.
+func VerifyNoLeaks(t testing.TB) {
+	t.Helper()
+
+	before := make(map[string]bool)
+	for _, g := range goroutines() {
+		before[goroutineID(g)] = true
+	}
+
+	t.Cleanup(func() {
+		t.Helper()
+
+		deadline := time.Now().Add(leakTimeout)
+		for {
+			var leaked []string
+			for _, g := range goroutines() {
+				if !before[goroutineID(g)] && strings.Contains(g, modulePath) {
+					leaked = append(leaked, g)
+				}
+			}
+			if len(leaked) == 0 {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Errorf("pipelinetest: %d goroutine(s) leaked:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+// goroutines returns the stacks of all goroutines but the calling one.
+func goroutines() []string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	stacks := strings.Split(string(bytes.TrimSpace(buf)), "\n\n")
+	return stacks[1:] // the first one is the caller
+}
+
+// goroutineID returns N of the "goroutine N [state]:" header of a stack.
+func goroutineID(stack string) string {
+	id, _, _ := strings.Cut(strings.TrimPrefix(stack, "goroutine "), " ")
+	return id
+}
@@ -0,0 +1,77 @@
+// Package pipelinetest provides utilities for testing pipelines: slice source nodes, thread-safe
+// collectors, a Run with a deadline, assertions on collected outputs, a fake clock for
+// time-based nodes and a check for goroutines that outlive a test.
+//
+//	func TestDouble(t *testing.T) {
+//		pipelinetest.VerifyNoLeaks(t)
+//
+//		src := pipelinetest.Source(1, 2, 3)
+//		double := nodes.NewWorkerPool(func(x int) (int, error) { return 2 * x, nil })
+//		got, sink := pipelinetest.Collect[int]()
+//		pipelinetest.Connect(t, src, double)
+//		pipelinetest.Connect(t, double, sink)
+//
+//		pipelinetest.Run(t, src, double, sink)
+//		pipelinetest.EqualUnordered(t, got.Items(), []int{2, 4, 6})
+//	}
+package pipelinetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/sinks"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+// DefaultTimeout is how long Run and RunError wait for a pipeline to finish.
+const DefaultTimeout = 10 * time.Second
+
+// Source returns a generator node that emits items in order, see sources.Slice.
+func Source[T any](items ...T) pipelines.Node[any, T] {
+	return nodes.NewGenerator(sources.Slice(items))
+}
+
+// Collect returns a sink node and the Collector it writes to. The Collector is safe for
+// concurrent use and may be read while the pipeline runs.
+func Collect[T any]() (*sinks.Collector[T], pipelines.Node[T, any]) {
+	c := sinks.Collect[T]()
+	return c, nodes.NewWriterAggregator(c)
+}
+
+// Connect connects from to to and fails the test on error.
+func Connect[In, Mid, Out any](t testing.TB, from pipelines.Node[In, Mid], to pipelines.Node[Mid, Out]) {
+	t.Helper()
+	if err := pipelines.Connect(from, to); err != nil {
+		t.Fatalf("pipelinetest: Connect(%s, %s) failed: %v", from.ID(), to.ID(), err)
+	}
+}
+
+// RunError runs the nodes as one pipeline and returns the error of its Run. The test fails
+// if the pipeline does not finish within DefaultTimeout.
+func RunError(t testing.TB, ns ...pipelines.Runnable) error {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(t.Context(), DefaultTimeout)
+	defer cancel()
+
+	p := pipelines.New()
+	p.Add(ns...)
+	err := p.Run(ctx)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		t.Fatalf("pipelinetest: pipeline did not finish within %s", DefaultTimeout)
+	}
+	return err
+}
+
+// Run is like RunError, but fails the test if the pipeline fails.
+func Run(t testing.TB, ns ...pipelines.Runnable) {
+	t.Helper()
+	if err := RunError(t, ns...); err != nil {
+		t.Fatalf("pipelinetest: pipeline failed: %v", err)
+	}
+}
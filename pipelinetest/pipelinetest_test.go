@@ -0,0 +1,131 @@
+package pipelinetest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+)
+
+func TestWorkerPool(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	src := pipelinetest.Source(1, 2, 3, 4, 5)
+	square := nodes.NewWorkerPool(func(x int) (int, error) { return x * x, nil }, nodes.Config{Workers: 3})
+	got, sink := pipelinetest.Collect[int]()
+	pipelinetest.Connect(t, src, square)
+	pipelinetest.Connect(t, square, sink)
+
+	pipelinetest.Run(t, src, square, sink)
+	pipelinetest.EqualUnordered(t, got.Items(), []int{1, 4, 9, 16, 25})
+}
+
+func TestDedupTTLWithFakeClock(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	type event struct {
+		key  string
+		wait time.Duration
+	}
+
+	// Часы двигает функция ключа: она вызывается в горутине узла перед проверкой ключа,
+	// поэтому время меняется строго между элементами.
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	key := func(e event) string {
+		clock.Advance(e.wait)
+		return e.key
+	}
+
+	src := pipelinetest.Source(
+		event{key: "a"},
+		event{key: "a", wait: 30 * time.Second},
+		event{key: "b", wait: 20 * time.Second},
+		event{key: "a", wait: 10 * time.Second}, // прошла минута — "a" забыт
+		event{key: "b"},
+	)
+	dedup := nodes.NewDedup(key, nodes.DedupConfig{Strategy: nodes.DedupTTL, TTL: time.Minute, Clock: clock})
+	got, sink := pipelinetest.Collect[event]()
+	pipelinetest.Connect(t, src, dedup)
+	pipelinetest.Connect(t, dedup, sink)
+
+	pipelinetest.Run(t, src, dedup, sink)
+	pipelinetest.Equal(t, got.Items(), []event{
+		{key: "a"},
+		{key: "b", wait: 20 * time.Second},
+		{key: "a", wait: 10 * time.Second},
+	})
+}
+
+func TestFakeClockAfter(t *testing.T) {
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	start := clock.Now()
+
+	fired := make(chan time.Time)
+	go func() { fired <- <-clock.After(time.Second) }()
+
+	clock.BlockUntil(1)
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-fired:
+		t.Fatalf("After(1s) fired after 500ms")
+	default:
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if at := <-fired; !at.Equal(start.Add(time.Second)) {
+		t.Errorf("After(1s) fired at %v, want %v", at, start.Add(time.Second))
+	}
+}
+
+// recorder подменяет testing.TB, чтобы проверить, что помощники сообщают об ошибках.
+type recorder struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (r *recorder) Helper()          {}
+func (r *recorder) Cleanup(f func()) { r.cleanups = append(r.cleanups, f) }
+func (r *recorder) Errorf(f string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(f, args...))
+}
+
+func TestVerifyNoLeaksReportsLeak(t *testing.T) {
+	r := &recorder{TB: t}
+	pipelinetest.VerifyNoLeaks(r)
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-release
+	}()
+
+	for _, f := range r.cleanups {
+		f()
+	}
+	close(release)
+	<-done
+
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "TestVerifyNoLeaksReportsLeak") {
+		t.Fatalf("expected one error naming the leaked goroutine, got %q", r.errors)
+	}
+}
+
+func TestEqualReportsDifference(t *testing.T) {
+	r := &recorder{TB: t}
+	pipelinetest.Equal(r, []int{1, 2, 3}, []int{1, 2, 3})
+	pipelinetest.EqualUnordered(r, []int{3, 1, 2}, []int{1, 2, 3})
+	if len(r.errors) != 0 {
+		t.Fatalf("equal slices reported as different: %q", r.errors)
+	}
+
+	pipelinetest.Equal(r, []int{1, 3, 2}, []int{1, 2, 3})
+	pipelinetest.EqualUnordered(r, []int{1, 1, 2}, []int{1, 2, 2})
+	if len(r.errors) != 2 {
+		t.Fatalf("expected 2 errors, got %q", r.errors)
+	}
+}
@@ -0,0 +1,61 @@
+// Package codec provides pluggable stream encodings used by nodes that move items
+// outside of Go channels, such as spill buffers and network transports.
+package codec
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+// Encoder writes values to an underlying stream.
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads values written by the matching Encoder into v, which must be a pointer.
+type Decoder interface {
+	Decode(v any) error
+}
+
+// Codec creates encoders and decoders for one stream format.
+// An Encoder/Decoder pair must be used for a single stream from its beginning,
+// since formats such as gob transmit type information only once per stream.
+type Codec interface {
+	Name() string
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+var (
+	// Gob encodes values with encoding/gob. It is compact and preserves Go types,
+	// but both sides must be Go programs.
+	Gob Codec = gobCodec{}
+
+	// JSON encodes values as a stream of JSON documents with encoding/json.
+	JSON Codec = jsonCodec{}
+)
+
+// ByName returns the built-in codec with the given name ("gob" or "json").
+func ByName(name string) (Codec, bool) {
+	switch name {
+	case Gob.Name():
+		return Gob, true
+	case JSON.Name():
+		return JSON, true
+	default:
+		return nil, false
+	}
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string                   { return "gob" }
+func (gobCodec) NewEncoder(w io.Writer) Encoder { return gob.NewEncoder(w) }
+func (gobCodec) NewDecoder(r io.Reader) Decoder { return gob.NewDecoder(r) }
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                   { return "json" }
+func (jsonCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+func (jsonCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
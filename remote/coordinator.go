@@ -0,0 +1,368 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/rpc"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+var (
+	_ pipelines.Node[any, any] = &workerPool[any, any]{}
+	_ nodes.StatsProvider      = &workerPool[any, any]{}
+)
+
+var poolCounter atomic.Uint64
+
+type workerPool[In, Out any] struct {
+	id uint64
+
+	ln  net.Listener
+	in  <-chan In
+	out chan<- Out
+
+	// requests of the rpc handlers, served by dispatch
+	leases    chan leaseRequest[In]
+	completes chan completion[Out]
+	gone      chan *session
+
+	workers   atomic.Int64
+	processed atomic.Uint64
+	emitted   atomic.Uint64
+
+	pool   PoolConfig
+	config nodes.Config
+}
+
+// NewWorkerPool creates a coordinator node that processes its input in worker processes
+// connected to ln, see Work. Like nodes.NewWorkerPool it accepts exactly one input and
+// emits results in completion order to a single output buffered with cfg.Buffer items.
+// A processor error in any worker stops the node with a *RemoteError. Once all items are done,
+// workers are told to exit and Run waits up to pcfg.ShutdownTimeout for them to disconnect;
+// ln and the remaining connections are closed when Run returns.
+func NewWorkerPool[In, Out any](ln net.Listener, pcfg PoolConfig, cfg ...nodes.Config) pipelines.Node[In, Out] {
+	config := nodes.DefaultConfig()
+	if len(cfg) > 0 {
+		config = cfg[0]
+	}
+
+	return &workerPool[In, Out]{
+		id:        poolCounter.Add(1),
+		ln:        ln,
+		leases:    make(chan leaseRequest[In]),
+		completes: make(chan completion[Out]),
+		gone:      make(chan *session),
+		pool:      pcfg.withDefaults(),
+		config:    config,
+	}
+}
+
+func (n *workerPool[In, Out]) ID() string {
+	return fmt.Sprintf("remote-worker-pool-node-%d", n.id)
+}
+
+func (n *workerPool[In, Out]) SetInput(in ...<-chan In) error {
+	if len(in) != 1 {
+		return nodes.ErrOnlyOneInput
+	}
+	n.in = in[0]
+	return nil
+}
+
+func (n *workerPool[In, Out]) Output() (chan Out, error) {
+	if n.out != nil {
+		return nil, nodes.ErrOnlyOneOutput
+	}
+	out := make(chan Out, n.config.Buffer)
+	n.out = out
+	return out, nil
+}
+
+// Stats reports the number of connected worker processes as Workers.
+func (n *workerPool[In, Out]) Stats() nodes.Stats {
+	return nodes.Stats{
+		Processed: n.processed.Load(),
+		Emitted:   n.emitted.Load(),
+		Workers:   int(n.workers.Load()),
+	}
+}
+
+func (n *workerPool[In, Out]) Run(ctx context.Context) error {
+	defer close(n.out)
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// finished is closed once all items are done and belongs to this run only
+	finished := make(chan struct{})
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		conns = make(map[net.Conn]struct{})
+	)
+	defer func() {
+		cancel()
+		n.ln.Close()
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	}()
+
+	errChan := make(chan error, 1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			conn, err := n.ln.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					errChan <- err
+				}
+				return
+			}
+
+			mu.Lock()
+			conns[conn] = struct{}{}
+			mu.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				n.serve(ctx, conn, finished)
+
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+			}()
+		}
+	}()
+
+	if err := n.dispatch(ctx, errChan); err != nil {
+		return err
+	}
+
+	// workers are told to exit from now on; give them time to disconnect on their own
+	close(finished)
+	n.ln.Close()
+
+	disconnected := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(disconnected)
+	}()
+
+	select {
+	case <-disconnected:
+	case <-time.After(n.pool.ShutdownTimeout):
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// session is the connection of one worker process. Its dead flag is owned by dispatch.
+type session struct {
+	dead bool
+}
+
+// serve runs the rpc server of one worker connection and reports the worker gone
+// once the connection is closed. finished is closed when the run has no items left.
+func (n *workerPool[In, Out]) serve(ctx context.Context, conn net.Conn, finished <-chan struct{}) {
+	s := &session{}
+	n.workers.Add(1)
+	defer n.workers.Add(-1)
+
+	srv := rpc.NewServer()
+	srv.RegisterName("Coordinator", &service[In, Out]{ctx: ctx, finished: finished, pool: n, session: s})
+	srv.ServeConn(conn)
+
+	select {
+	case n.gone <- s:
+	case <-finished:
+	case <-ctx.Done():
+	}
+}
+
+type task[In any] struct {
+	item     In
+	attempts int
+}
+
+type lease[In any] struct {
+	task    *task[In]
+	session *session
+}
+
+type leaseRequest[In any] struct {
+	session *session
+	reply   chan Lease[In]
+}
+
+type completion[Out any] struct {
+	session *session
+	result  Result[Out]
+	reply   chan bool
+}
+
+// dispatch owns the pool state: it leases items to waiting workers, collects their results,
+// re-queues the items of workers that are gone and sends results to the output.
+func (n *workerPool[In, Out]) dispatch(ctx context.Context, errChan <-chan error) error {
+	var (
+		in      = n.in
+		queue   []*task[In]
+		waiting []leaseRequest[In]
+		leased  = make(map[uint64]*lease[In])
+		results []Out
+		leaseID uint64
+	)
+
+	for {
+		for len(queue) > 0 && len(waiting) > 0 {
+			t, req := queue[0], waiting[0]
+			queue, waiting = queue[1:], waiting[1:]
+
+			leaseID++
+			t.attempts++
+			leased[leaseID] = &lease[In]{task: t, session: req.session}
+			req.reply <- Lease[In]{ID: leaseID, Item: t.item}
+		}
+
+		if in == nil && len(queue) == 0 && len(leased) == 0 && len(results) == 0 {
+			for _, req := range waiting {
+				req.reply <- Lease[In]{Done: true}
+			}
+			return nil
+		}
+
+		// read new input only for a waiting worker, so backpressure reaches upstream
+		var input <-chan In
+		if len(waiting) > 0 && len(results) <= n.config.Buffer {
+			input = in
+		}
+
+		var (
+			out  chan<- Out
+			next Out
+		)
+		if len(results) > 0 {
+			out, next = n.out, results[0]
+		}
+
+		select {
+		case item, open := <-input:
+			if !open {
+				in = nil
+				continue
+			}
+			n.processed.Add(1)
+			queue = append(queue, &task[In]{item: item})
+
+		case req := <-n.leases:
+			if req.session.dead {
+				req.reply <- Lease[In]{Done: true}
+				continue
+			}
+			waiting = append(waiting, req)
+
+		case c := <-n.completes:
+			l, ok := leased[c.result.ID]
+			if !ok || l.session != c.session {
+				// the item was re-queued after its worker was considered gone
+				c.reply <- false
+				continue
+			}
+			delete(leased, c.result.ID)
+			c.reply <- true
+
+			if c.result.Err != "" {
+				return fmt.Errorf("%s: %w", n.ID(), &RemoteError{Msg: c.result.Err})
+			}
+			results = append(results, c.result.Value)
+
+		case s := <-n.gone:
+			s.dead = true
+			waiting = slices.DeleteFunc(waiting, func(req leaseRequest[In]) bool {
+				return req.session == s
+			})
+			for id, l := range leased {
+				if l.session != s {
+					continue
+				}
+				delete(leased, id)
+				if l.task.attempts >= n.pool.MaxAttempts {
+					return fmt.Errorf("%s: %w after %d attempts", n.ID(), ErrTooManyAttempts, l.task.attempts)
+				}
+				queue = append(queue, l.task)
+			}
+
+		case out <- next:
+			results = results[1:]
+			n.emitted.Add(1)
+
+		case err := <-errChan:
+			return fmt.Errorf("%s: %w", n.ID(), err)
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// service is the rpc receiver of one worker connection.
+type service[In, Out any] struct {
+	ctx      context.Context
+	finished <-chan struct{}
+	pool     *workerPool[In, Out]
+	session  *session
+}
+
+// Lease blocks until an item is available for the worker or no items are left.
+func (s *service[In, Out]) Lease(_ LeaseArgs, reply *Lease[In]) error {
+	req := leaseRequest[In]{session: s.session, reply: make(chan Lease[In], 1)}
+
+	select {
+	case s.pool.leases <- req:
+	case <-s.finished:
+		reply.Done = true
+		return nil
+	case <-s.ctx.Done():
+		return ErrStopped
+	}
+	select {
+	case *reply = <-req.reply:
+		return nil
+	case <-s.ctx.Done():
+		return ErrStopped
+	}
+}
+
+// Complete reports the result of a lease. accepted is false for a lease that
+// has been given to another worker in the meantime.
+func (s *service[In, Out]) Complete(res Result[Out], accepted *bool) error {
+	c := completion[Out]{session: s.session, result: res, reply: make(chan bool, 1)}
+
+	select {
+	case s.pool.completes <- c:
+	case <-s.finished:
+		return nil
+	case <-s.ctx.Done():
+		return ErrStopped
+	}
+	select {
+	case *accepted = <-c.reply:
+		return nil
+	case <-s.ctx.Done():
+		return ErrStopped
+	}
+}
@@ -0,0 +1,93 @@
+// Package remote runs a processor in separate worker processes. NewWorkerPool is a coordinator
+// node that listens on a Unix socket or loopback TCP address; worker processes connect to it
+// with Work and call the processor for the items they lease.
+//
+// The coordinator and the workers talk net/rpc (gob encoded), so item and result types must be
+// gob-encodable. Every item is leased to exactly one worker at a time. If the connection of a
+// worker drops, for example because the process crashed, its unfinished items are leased again
+// to other workers, up to PoolConfig.MaxAttempts times. Each item yields at most one result,
+// but a processor may be called more than once for the same item.
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTooManyAttempts = errors.New("item lost by too many workers")
+	ErrStopped         = errors.New("coordinator stopped")
+)
+
+// RemoteError is the error a processor returned in a worker process.
+type RemoteError struct {
+	Msg string
+}
+
+func (e *RemoteError) Error() string {
+	return "remote: " + e.Msg
+}
+
+// PoolConfig configures a coordinator. Zero fields take the defaults listed below.
+type PoolConfig struct {
+	// MaxAttempts is the number of workers an item may be leased to before the
+	// pool fails with ErrTooManyAttempts (default 3).
+	MaxAttempts int
+	// ShutdownTimeout bounds the wait for workers to disconnect after the
+	// last item (default 5s).
+	ShutdownTimeout time.Duration
+}
+
+func (c PoolConfig) withDefaults() PoolConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 3
+	}
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = 5 * time.Second
+	}
+	return c
+}
+
+// LeaseArgs, Lease and Result are the net/rpc messages of the protocol.
+// They are exported only because net/rpc requires it.
+type LeaseArgs struct{}
+
+// Lease is an item leased to a worker. Done reports that no items are left.
+type Lease[In any] struct {
+	ID   uint64
+	Item In
+	Done bool
+}
+
+// Result is the outcome of a Lease. A non-empty Err is the processor error.
+type Result[Out any] struct {
+	ID    uint64
+	Value Out
+	Err   string
+}
+
+// EnvAddress is the environment variable Command uses to pass the coordinator
+// address to a worker process, formatted as "network:address".
+const EnvAddress = "PIPELINES_REMOTE_ADDR"
+
+// Command returns a command that starts a worker process connecting to the coordinator
+// at network/address. The process reads the address with AddressFromEnv.
+func Command(network, address, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s:%s", EnvAddress, network, address))
+	return cmd
+}
+
+// AddressFromEnv returns the coordinator address set by Command. ok is false
+// if the current process was not started as a worker.
+func AddressFromEnv() (network, address string, ok bool) {
+	network, address, ok = strings.Cut(os.Getenv(EnvAddress), ":")
+	if network == "" || address == "" {
+		return "", "", false
+	}
+	return network, address, ok
+}
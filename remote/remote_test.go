@@ -0,0 +1,188 @@
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/remote"
+	"github.com/Sergey-Polishchenko/pipelines/sinks"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+func square(_ context.Context, v int) (int, error) {
+	return v * v, nil
+}
+
+// TestMain запускает тестовый бинарник как воркер, если его стартовал remote.Command
+func TestMain(m *testing.M) {
+	if network, address, ok := remote.AddressFromEnv(); ok {
+		if err := remote.Work(context.Background(), network, address, square, 2); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// run прогоняет items через удалённый пул и возвращает отсортированные результаты
+func run(t *testing.T, ln net.Listener, items []int, pcfg remote.PoolConfig) ([]int, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	gen := nodes.NewGenerator(sources.Slice(items))
+	pool := remote.NewWorkerPool[int, int](ln, pcfg)
+	collect := sinks.Collect[int]()
+	agg := nodes.NewWriterAggregator(collect)
+
+	if err := pipelines.Connect(gen, pool); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := pipelines.Connect(pool, agg); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(gen, pool, agg)
+	err := p.Run(ctx)
+
+	got := collect.Items()
+	slices.Sort(got)
+	return got, err
+}
+
+func checkSquares(t *testing.T, got []int, n int) {
+	t.Helper()
+
+	if len(got) != n {
+		t.Fatalf("got %d results, want %d", len(got), n)
+	}
+	for i, v := range got {
+		if v != i*i {
+			t.Fatalf("result %d = %d, want %d", i, v, i*i)
+		}
+	}
+}
+
+func sequence(n int) []int {
+	items := make([]int, n)
+	for i := range items {
+		items[i] = i
+	}
+	return items
+}
+
+func TestWorkerProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coordinator.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	var cmds []*os.Process
+	for range 2 {
+		cmd := remote.Command("unix", path, os.Args[0])
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		cmds = append(cmds, cmd.Process)
+	}
+
+	got, err := run(t, ln, sequence(200), remote.PoolConfig{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSquares(t, got, 200)
+
+	for _, proc := range cmds {
+		if state, err := proc.Wait(); err != nil || !state.Success() {
+			t.Fatalf("worker exited with %v, %v", state, err)
+		}
+	}
+}
+
+func TestDeadWorkerItemIsRequeued(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	addr := ln.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Первый воркер «падает» на первом же элементе: рвёт соединение, не вернув результат
+	crashed := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Errorf("Dial failed: %v", err)
+			close(crashed)
+			return
+		}
+		remote.WorkConn(ctx, conn, func(context.Context, int) (int, error) {
+			conn.Close()
+			close(crashed)
+			return 0, nil
+		}, 1)
+	}()
+
+	// Второй подключается только после падения первого и обрабатывает всё, включая потерянный элемент
+	go func() {
+		defer wg.Done()
+		<-crashed
+		if err := remote.Work(ctx, "tcp", addr, square, 4); err != nil {
+			t.Errorf("Work failed: %v", err)
+		}
+	}()
+
+	got, err := run(t, ln, sequence(50), remote.PoolConfig{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSquares(t, got, 50)
+	wg.Wait()
+}
+
+func TestPoisonItemFails(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	addr := ln.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Каждый воркер падает на элементе, поэтому после MaxAttempts пул сдаётся
+	go func() {
+		for ctx.Err() == nil {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				return
+			}
+			remote.WorkConn(ctx, conn, func(context.Context, int) (int, error) {
+				conn.Close()
+				return 0, nil
+			}, 1)
+		}
+	}()
+
+	_, err = run(t, ln, sequence(1), remote.PoolConfig{MaxAttempts: 2})
+	if !errors.Is(err, remote.ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+}
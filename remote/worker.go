@@ -0,0 +1,97 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// Work connects to the coordinator at network/address and runs proc for leased items in
+// concurrency goroutines. It returns nil once the coordinator has no more items or has
+// stopped, and an error if the connection fails.
+func Work[In, Out any](
+	ctx context.Context,
+	network, address string,
+	proc nodes.ContextProcessor[In, Out],
+	concurrency int,
+) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return err
+	}
+	return WorkConn(ctx, conn, proc, concurrency)
+}
+
+// WorkConn is like Work, but uses an established connection to the coordinator.
+// conn is closed when WorkConn returns.
+func WorkConn[In, Out any](
+	ctx context.Context,
+	conn io.ReadWriteCloser,
+	proc nodes.ContextProcessor[In, Out],
+	concurrency int,
+) error {
+	client := rpc.NewClient(conn)
+	defer client.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		client.Close()
+	})
+	defer stop()
+
+	concurrency = max(concurrency, 1)
+	errs := make([]error, concurrency)
+
+	var wg sync.WaitGroup
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = work(ctx, client, proc)
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.Join(errs...)
+}
+
+func work[In, Out any](ctx context.Context, client *rpc.Client, proc nodes.ContextProcessor[In, Out]) error {
+	for {
+		var l Lease[In]
+		if err := client.Call("Coordinator.Lease", LeaseArgs{}, &l); err != nil {
+			return ignoreStopped(err)
+		}
+		if l.Done {
+			return nil
+		}
+
+		res := Result[Out]{ID: l.ID}
+		if v, err := proc(ctx, l.Item); err != nil {
+			res.Err = err.Error()
+		} else {
+			res.Value = v
+		}
+
+		var accepted bool
+		if err := client.Call("Coordinator.Complete", res, &accepted); err != nil {
+			return ignoreStopped(err)
+		}
+	}
+}
+
+// ignoreStopped treats a stopped coordinator as the end of work.
+func ignoreStopped(err error) error {
+	var serr rpc.ServerError
+	if errors.As(err, &serr) && string(serr) == ErrStopped.Error() {
+		return nil
+	}
+	return err
+}
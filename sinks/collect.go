@@ -0,0 +1,84 @@
+package sinks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+var (
+	_ nodes.SinkWriter[any] = &Collector[any]{}
+	_ nodes.SinkWriter[any] = &Forwarder[any]{}
+)
+
+// Collector gathers items into a slice. It is safe for concurrent use, so it can be shared
+// by several aggregators and read while the pipeline runs.
+type Collector[T any] struct {
+	mu    sync.Mutex
+	items []T
+}
+
+// Collect returns an empty Collector.
+func Collect[T any]() *Collector[T] {
+	return &Collector[T]{}
+}
+
+// Write appends v.
+func (c *Collector[T]) Write(_ context.Context, v T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = append(c.items, v)
+	return nil
+}
+
+// Items returns a copy of the collected items in arrival order.
+func (c *Collector[T]) Items() []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]T(nil), c.items...)
+}
+
+// Len returns the number of collected items.
+func (c *Collector[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Close does nothing; collected items stay available.
+func (c *Collector[T]) Close() error {
+	return nil
+}
+
+// Forwarder sends items to a channel owned by the caller, for handing results
+// to code outside the pipeline.
+type Forwarder[T any] struct {
+	ch        chan<- T
+	closeOnce sync.Once
+	closeCh   bool
+}
+
+// Forward returns a Forwarder to ch. If closeCh is set, ch is closed when the
+// aggregator stops, so a consumer can range over it.
+func Forward[T any](ch chan<- T, closeCh bool) *Forwarder[T] {
+	return &Forwarder[T]{ch: ch, closeCh: closeCh}
+}
+
+// Write sends v, giving up when ctx is canceled.
+func (f *Forwarder[T]) Write(ctx context.Context, v T) error {
+	select {
+	case f.ch <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close closes the channel if requested in Forward.
+func (f *Forwarder[T]) Close() error {
+	if f.closeCh {
+		f.closeOnce.Do(func() { close(f.ch) })
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package sinks
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// OutputFile is a buffered file opened by this package. Writers built on it close it
+// on Close.
+type OutputFile struct {
+	f   *os.File
+	buf *bufio.Writer
+}
+
+// File creates (or truncates) the file at path for a Writer.
+func File(path string) (*OutputFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &OutputFile{f: f, buf: bufio.NewWriter(f)}, nil
+}
+
+func (f *OutputFile) Write(p []byte) (int, error) { return f.buf.Write(p) }
+func (f *OutputFile) Flush() error                { return f.buf.Flush() }
+func (f *OutputFile) owned()                      {}
+
+// Close flushes and closes the file.
+func (f *OutputFile) Close() error {
+	return errors.Join(f.buf.Flush(), f.f.Close())
+}
+
+// RotateOptions configures a RotatingFile. At least one limit should be set.
+type RotateOptions struct {
+	// MaxBytes starts a new file before a record would grow the current one past this size.
+	MaxBytes int64
+	// Interval starts a new file when the current one is older than this.
+	Interval time.Duration
+	// Clock measures Interval; nil means the system clock.
+	Clock nodes.Clock
+}
+
+// RotatingFile writes records to a sequence of files named after path with a sequence
+// number before the extension: "out.jsonl" becomes "out-000001.jsonl", "out-000002.jsonl", ...
+// Rotation happens only between records written by a Writer.
+type RotatingFile struct {
+	mu     sync.Mutex
+	prefix string
+	ext    string
+	opts   RotateOptions
+	now    func() time.Time
+
+	seq     int
+	cur     *OutputFile
+	size    int64
+	started time.Time
+}
+
+// Rotating opens the first file of a RotatingFile.
+func Rotating(path string, opts RotateOptions) (*RotatingFile, error) {
+	ext := filepath.Ext(path)
+	r := &RotatingFile{
+		prefix: strings.TrimSuffix(path, ext),
+		ext:    ext,
+		opts:   opts,
+		now:    time.Now,
+	}
+	if opts.Clock != nil {
+		r.now = opts.Clock.Now
+	}
+	if err := r.rotate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write appends one record, starting a new file first if a limit would be exceeded.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cur == nil {
+		return 0, os.ErrClosed
+	}
+
+	full := r.opts.MaxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.opts.MaxBytes
+	old := r.opts.Interval > 0 && r.now().Sub(r.started) >= r.opts.Interval
+	if full || old {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.cur.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Flush writes buffered data of the current file.
+func (r *RotatingFile) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cur == nil {
+		return nil
+	}
+	return r.cur.Flush()
+}
+
+// Close flushes and closes the current file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cur == nil {
+		return nil
+	}
+	err := r.cur.Close()
+	r.cur = nil
+	return err
+}
+
+func (r *RotatingFile) owned() {}
+
+func (r *RotatingFile) rotate() error {
+	if r.cur != nil {
+		if err := r.cur.Close(); err != nil {
+			return err
+		}
+		r.cur = nil
+	}
+
+	r.seq++
+	f, err := File(fmt.Sprintf("%s-%06d%s", r.prefix, r.seq, r.ext))
+	if err != nil {
+		return err
+	}
+	r.cur, r.size, r.started = f, 0, r.now()
+	return nil
+}
@@ -0,0 +1,249 @@
+package sinks_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+	"github.com/Sergey-Polishchenko/pipelines/sinks"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+func run[T any](t *testing.T, items []T, w nodes.SinkWriter[T]) error {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	gen := nodes.NewGenerator(sources.Slice(items))
+	agg := nodes.NewWriterAggregator(w)
+	if err := pipelines.Connect(gen, agg); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(gen, agg)
+	return p.Run(ctx)
+}
+
+func TestRotatingJSONLines(t *testing.T) {
+	dir := t.TempDir()
+
+	// Каждая запись {"N":x}\n занимает 8 байт, в файл помещается не больше двух
+	rot, err := sinks.Rotating(filepath.Join(dir, "out.jsonl"), sinks.RotateOptions{MaxBytes: 16})
+	if err != nil {
+		t.Fatalf("Rotating failed: %v", err)
+	}
+
+	type rec struct{ N int }
+	if err := run(t, []rec{{1}, {2}, {3}, {4}, {5}}, sinks.JSONLines[rec](rot)); err != nil {
+		t.Fatalf("pipeline error: %v", err)
+	}
+
+	for i, want := range []string{"{\"N\":1}\n{\"N\":2}\n", "{\"N\":3}\n{\"N\":4}\n", "{\"N\":5}\n"} {
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("out-%06d.jsonl", i+1)))
+		if err != nil {
+			t.Fatalf("file %d: %v", i+1, err)
+		}
+		if string(data) != want {
+			t.Errorf("file %d = %q, want %q", i+1, data, want)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("expected 3 files, got %d", len(entries))
+	}
+}
+
+func TestCollector(t *testing.T) {
+	c := sinks.Collect[int]()
+	if err := run(t, []int{1, 2, 3}, c); err != nil {
+		t.Fatalf("pipeline error: %v", err)
+	}
+	if got := c.Items(); len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestCSVAndText(t *testing.T) {
+	type row struct {
+		Name string
+		N    int
+	}
+	rows := []row{{"plain", 1}, {"with, comma", 2}, {`with "quotes"`, 3}}
+
+	// Поля с запятыми и кавычками экранируются по правилам CSV
+	var csvOut bytes.Buffer
+	err := run(t, rows, sinks.CSV(&csvOut, func(r row) ([]string, error) {
+		return []string{r.Name, strconv.Itoa(r.N)}, nil
+	}))
+	if err != nil {
+		t.Fatalf("pipeline error: %v", err)
+	}
+	if want := "plain,1\n\"with, comma\",2\n\"with \"\"quotes\"\"\",3\n"; csvOut.String() != want {
+		t.Errorf("CSV = %q, want %q", csvOut.String(), want)
+	}
+
+	var textOut bytes.Buffer
+	if err := run(t, rows, sinks.Text(&textOut, func(r row) string { return fmt.Sprintf("%s=%d", r.Name, r.N) })); err != nil {
+		t.Fatalf("pipeline error: %v", err)
+	}
+	if want := "plain=1\nwith, comma=2\nwith \"quotes\"=3\n"; textOut.String() != want {
+		t.Errorf("Text = %q, want %q", textOut.String(), want)
+	}
+
+	// Ошибка функции записи завершает пайплайн
+	errBad := errors.New("bad row")
+	err = run(t, rows, sinks.CSV(io.Discard, func(r row) ([]string, error) {
+		if r.N == 2 {
+			return nil, errBad
+		}
+		return []string{r.Name}, nil
+	}))
+	if !errors.Is(err, errBad) {
+		t.Errorf("expected errBad, got %v", err)
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	// Потребитель вне пайплайна читает канал до закрытия
+	ch := make(chan int)
+	got := make(chan []int)
+	go func() {
+		var items []int
+		for x := range ch {
+			items = append(items, x)
+		}
+		got <- items
+	}()
+	if err := run(t, []int{1, 2, 3}, sinks.Forward(ch, true)); err != nil {
+		t.Fatalf("pipeline error: %v", err)
+	}
+	if items := <-got; !slices.Equal(items, []int{1, 2, 3}) {
+		t.Errorf("got %v", items)
+	}
+
+	// Без читателя Write сдаётся при отмене контекста, а канал вызывающего остаётся открытым
+	f := sinks.Forward(make(chan int), false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.Write(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestRotatingInterval(t *testing.T) {
+	dir := t.TempDir()
+	clock := pipelinetest.NewFakeClock(time.Now())
+	rot, err := sinks.Rotating(filepath.Join(dir, "out.txt"), sinks.RotateOptions{Interval: time.Minute, Clock: clock})
+	if err != nil {
+		t.Fatalf("Rotating failed: %v", err)
+	}
+	w := sinks.Text(rot, strconv.Itoa)
+	ctx := context.Background()
+
+	// Новый файл начинается с первой записи после истечения интервала
+	for _, step := range []struct {
+		x       int
+		advance time.Duration
+	}{{1, 0}, {2, 59 * time.Second}, {3, time.Second}, {4, 0}, {5, time.Minute}} {
+		clock.Advance(step.advance)
+		if err := w.Write(ctx, step.x); err != nil {
+			t.Fatalf("Write(%d) failed: %v", step.x, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	for i, want := range []string{"1\n2\n", "3\n4\n", "5\n"} {
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("out-%06d.txt", i+1)))
+		if err != nil {
+			t.Fatalf("file %d: %v", i+1, err)
+		}
+		if string(data) != want {
+			t.Errorf("file %d = %q, want %q", i+1, data, want)
+		}
+	}
+}
+
+// failingWriter отказывает на заданном элементе и считает вызовы Close
+type failingWriter struct {
+	*sinks.Writer[int]
+	failOn int
+	closed int
+}
+
+var errWrite = errors.New("write failed")
+
+func (w *failingWriter) Write(ctx context.Context, x int) error {
+	if x == w.failOn {
+		return errWrite
+	}
+	return w.Writer.Write(ctx, x)
+}
+
+func (w *failingWriter) Close() error {
+	w.closed++
+	return w.Writer.Close()
+}
+
+func TestWriterAggregatorClosesOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	tw, err := sinks.TextFile(path, strconv.Itoa)
+	if err != nil {
+		t.Fatalf("TextFile failed: %v", err)
+	}
+	w := &failingWriter{Writer: tw, failOn: 3}
+
+	// Ошибка записи не мешает закрыть файл и сбросить уже записанное
+	if err := run(t, []int{1, 2, 3, 4}, nodes.SinkWriter[int](w)); !errors.Is(err, errWrite) {
+		t.Fatalf("expected errWrite, got %v", err)
+	}
+	if w.closed != 1 {
+		t.Errorf("writer closed %d times, want 1", w.closed)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "1\n2\n" {
+		t.Errorf("file = %q, want the items before the failure", data)
+	}
+}
+
+func TestWriterAggregatorClosesWithoutRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	tw, err := sinks.TextFile(path, strconv.Itoa)
+	if err != nil {
+		t.Fatalf("TextFile failed: %v", err)
+	}
+	w := &failingWriter{Writer: tw}
+
+	// Нода, которую так и не запустили, освобождает файл через io.Closer, и только один раз
+	agg := nodes.NewWriterAggregator[int](w)
+	closer, ok := agg.(io.Closer)
+	if !ok {
+		t.Fatalf("writer aggregator does not implement io.Closer")
+	}
+	for range 2 {
+		if err := closer.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+	if w.closed != 1 {
+		t.Errorf("writer closed %d times, want 1", w.closed)
+	}
+	if err := tw.Write(context.Background(), 1); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected os.ErrClosed after Close, got %v", err)
+	}
+}
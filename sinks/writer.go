@@ -0,0 +1,166 @@
+// Package sinks provides ready-made nodes.SinkWriter implementations: JSON Lines, CSV and text
+// writers (optionally over rotating files), a thread-safe slice collector and a channel forwarder.
+//
+// Use them with nodes.NewWriterAggregator, which flushes and closes the writer when the
+// pipeline completes or fails:
+//
+//	w, err := sinks.JSONLinesFile[Result]("results.jsonl")
+//	if err != nil { ... }
+//	agg := nodes.NewWriterAggregator(w)
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+var _ nodes.SinkWriter[any] = &Writer[any]{}
+
+// Writer encodes items onto a byte stream. It is safe for concurrent use.
+// Each item is encoded as one whole record, so a record is never split between
+// two files of a RotatingFile.
+type Writer[T any] struct {
+	mu      sync.Mutex
+	encode  func(w io.Writer, v T) error
+	record  bytes.Buffer
+	out     io.Writer
+	flush   func() error
+	closeFn func() error
+	closed  bool
+}
+
+// flusher is implemented by destinations that buffer records themselves.
+type flusher interface {
+	Flush() error
+}
+
+// owned is implemented by destinations opened by this package; Writer closes them on Close.
+type owned interface {
+	io.WriteCloser
+	owned()
+}
+
+func newWriter[T any](dst io.Writer, encode func(w io.Writer, v T) error) *Writer[T] {
+	w := &Writer[T]{encode: encode}
+
+	if f, ok := dst.(flusher); ok {
+		w.out, w.flush = dst, f.Flush
+	} else {
+		buf := bufio.NewWriter(dst)
+		w.out, w.flush = buf, buf.Flush
+	}
+	if o, ok := dst.(owned); ok {
+		w.closeFn = o.Close
+	}
+	return w
+}
+
+// Write encodes v and appends it to the stream.
+func (w *Writer[T]) Write(_ context.Context, v T) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	w.record.Reset()
+	if err := w.encode(&w.record, v); err != nil {
+		return err
+	}
+	_, err := w.out.Write(w.record.Bytes())
+	return err
+}
+
+// Flush writes buffered records to the destination.
+func (w *Writer[T]) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+// Close flushes buffered records and closes the destination if it was opened by this package
+// (see File and RotatingFile). Destinations passed in by the caller, such as os.Stdout,
+// are left open. Close is idempotent.
+func (w *Writer[T]) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.flush()
+	if w.closeFn != nil {
+		err = errors.Join(err, w.closeFn())
+	}
+	return err
+}
+
+// JSONLines returns a Writer that encodes each item as one line of JSON.
+func JSONLines[T any](dst io.Writer) *Writer[T] {
+	return newWriter(dst, func(w io.Writer, v T) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+}
+
+// CSV returns a Writer that writes record(item) as one CSV record.
+func CSV[T any](dst io.Writer, record func(T) ([]string, error)) *Writer[T] {
+	return newWriter(dst, func(w io.Writer, v T) error {
+		rec, err := record(v)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+}
+
+// Text returns a Writer that writes format(item) followed by a newline.
+func Text[T any](dst io.Writer, format func(T) string) *Writer[T] {
+	return newWriter(dst, func(w io.Writer, v T) error {
+		_, err := io.WriteString(w, format(v)+"\n")
+		return err
+	})
+}
+
+// JSONLinesFile is JSONLines over a new file at path (see File).
+func JSONLinesFile[T any](path string) (*Writer[T], error) {
+	f, err := File(path)
+	if err != nil {
+		return nil, err
+	}
+	return JSONLines[T](f), nil
+}
+
+// CSVFile is CSV over a new file at path (see File).
+func CSVFile[T any](path string, record func(T) ([]string, error)) (*Writer[T], error) {
+	f, err := File(path)
+	if err != nil {
+		return nil, err
+	}
+	return CSV(f, record), nil
+}
+
+// TextFile is Text over a new file at path (see File).
+func TextFile[T any](path string, format func(T) string) (*Writer[T], error) {
+	f, err := File(path)
+	if err != nil {
+		return nil, err
+	}
+	return Text(f, format), nil
+}
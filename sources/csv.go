@@ -0,0 +1,60 @@
+package sources
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// CSV returns a Generator that emits the records of r. The optional configure functions
+// may adjust the csv.Reader (Comma, FieldsPerRecord, ...) before reading. Like Lines, r is
+// closed only if it is an io.Closer and ctx is canceled.
+func CSV(r io.Reader, configure ...func(*csv.Reader)) nodes.Generator[[]string] {
+	return func(ctx context.Context) (<-chan []string, error) {
+		return nodes.Produce(ctx, func(emit func([]string) bool) error {
+			defer closeOnCancel(ctx, r)()
+			return readCSV(r, configure, emit)
+		}), nil
+	}
+}
+
+// CSVFile is like CSV, but opens the file at path when the generator starts
+// and closes it when done or when ctx is canceled, so that a read blocked on a FIFO returns.
+func CSVFile(path string, configure ...func(*csv.Reader)) nodes.Generator[[]string] {
+	return func(ctx context.Context) (<-chan []string, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return nodes.Produce(ctx, func(emit func([]string) bool) error {
+			defer f.Close()
+			defer closeOnCancel(ctx, f)()
+			return readCSV(f, configure, emit)
+		}), nil
+	}
+}
+
+func readCSV(r io.Reader, configure []func(*csv.Reader), emit func([]string) bool) error {
+	cr := csv.NewReader(r)
+	for _, fn := range configure {
+		fn(cr)
+	}
+
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !emit(rec) {
+			return nil
+		}
+	}
+}
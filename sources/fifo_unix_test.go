@@ -0,0 +1,99 @@
+//go:build unix
+
+package sources_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+func TestFileSourcesCancelBlockedRead(t *testing.T) {
+	tests := []struct {
+		name  string
+		gen   func(path string) nodes.Generator[any]
+		input string
+	}{
+		{"LinesFile", func(p string) nodes.Generator[any] { return anyGen(sources.LinesFile(p)) }, "first\n"},
+		{"CSVFile", func(p string) nodes.Generator[any] { return anyGen(sources.CSVFile(p)) }, "a,b\n"},
+		{"JSONLinesFile", func(p string) nodes.Generator[any] { return anyGen(sources.JSONLinesFile[int](p)) }, "1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Писатель FIFO молчит после первой записи: чтение висит, пока отмена не закроет файл.
+			// Писатель закрывается уже после проверки утечек, иначе его EOF сам завершил бы чтение.
+			var w *os.File
+			t.Cleanup(func() {
+				if w != nil {
+					w.Close()
+				}
+			})
+			pipelinetest.VerifyNoLeaks(t)
+
+			path := filepath.Join(t.TempDir(), "fifo")
+			if err := syscall.Mkfifo(path, 0o600); err != nil {
+				t.Skipf("mkfifo not supported: %v", err)
+			}
+			writer := make(chan *os.File, 1)
+			go func() {
+				w, err := os.OpenFile(path, os.O_WRONLY, 0)
+				if err != nil {
+					t.Errorf("opening the writer failed: %v", err)
+					close(writer)
+					return
+				}
+				w.WriteString(tt.input)
+				writer <- w
+			}()
+
+			gen := nodes.NewGenerator(tt.gen(path))
+			out, err := gen.Output()
+			if err != nil {
+				t.Fatalf("Output failed: %v", err)
+			}
+			ctx, cancel := context.WithCancel(t.Context())
+			errc := make(chan error, 1)
+			go func() { errc <- gen.Run(ctx) }()
+
+			<-out
+			w = <-writer
+
+			cancel()
+			select {
+			case err := <-errc:
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("expected context.Canceled, got %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run did not return after cancel")
+			}
+		})
+	}
+}
+
+// anyGen приводит генератор к Generator[any], чтобы таблица тестов содержала разные типы
+func anyGen[T any](gen nodes.Generator[T]) nodes.Generator[any] {
+	return func(ctx context.Context) (<-chan any, error) {
+		ch, err := gen(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return nodes.Produce(ctx, func(emit func(any) bool) error {
+			for v := range ch {
+				if !emit(v) {
+					return nil
+				}
+			}
+			return nil
+		}), nil
+	}
+}
@@ -0,0 +1,118 @@
+package sources
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// ignorePattern is one rule of a .gitignore-style file.
+type ignorePattern struct {
+	// segments of the pattern relative to the ignore file; "**" matches any number of segments
+	segments []string
+	dirOnly  bool
+	negate   bool
+}
+
+// ignoreFile holds the rules of one ignore file.
+type ignoreFile struct {
+	// dir is the slash-separated path of the directory holding the file, relative
+	// to the walk root, "" for the root itself
+	dir      string
+	patterns []ignorePattern
+}
+
+// parseIgnore parses the subset of the .gitignore syntax that matters for walking:
+// comments, negation with '!', directory-only rules with a trailing '/', rules anchored
+// to the file's directory when they contain a '/', wildcards and "**".
+func parseIgnore(dir string, data []byte) *ignoreFile {
+	f := &ignoreFile{dir: dir}
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimRight(line, "\r\n")
+		line = strings.TrimRight(line, " \t")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var p ignorePattern
+		if line[0] == '!' {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// without an inner slash a rule matches a name at any depth
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		p.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		f.patterns = append(f.patterns, p)
+	}
+	return f
+}
+
+// ignoreStack holds the ignore files of a directory and its ancestors, outermost first.
+type ignoreStack []*ignoreFile
+
+// with returns the stack extended by f without modifying s, which other directories share.
+func (s ignoreStack) with(f *ignoreFile) ignoreStack {
+	return append(slices.Clip(s), f)
+}
+
+// ignored reports whether rel, a slash-separated path relative to the walk root, is ignored.
+// As in git, the last matching rule wins and deeper files override their ancestors.
+func (s ignoreStack) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, f := range s {
+		name := rel
+		if f.dir != "" {
+			name = strings.TrimPrefix(rel, f.dir+"/")
+		}
+		segments := strings.Split(name, "/")
+
+		for _, p := range f.patterns {
+			if p.dirOnly && !isDir {
+				continue
+			}
+			if matchSegments(p.segments, segments) {
+				ignored = !p.negate
+			}
+		}
+	}
+	return ignored
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				// a trailing "**" matches everything inside, but not the directory itself
+				return len(name) > 0
+			}
+			for i := range len(name) {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
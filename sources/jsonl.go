@@ -0,0 +1,57 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// JSONLines returns a Generator that decodes a stream of JSON values (one per line) from r into T.
+// A malformed value stops the stream and is reported. Like Lines, r is closed only if it is
+// an io.Closer and ctx is canceled.
+func JSONLines[T any](r io.Reader) nodes.Generator[T] {
+	return func(ctx context.Context) (<-chan T, error) {
+		return nodes.Produce(ctx, func(emit func(T) bool) error {
+			defer closeOnCancel(ctx, r)()
+			return decodeJSONLines(r, emit)
+		}), nil
+	}
+}
+
+// JSONLinesFile is like JSONLines, but opens the file at path when the generator starts
+// and closes it when done or when ctx is canceled, so that a read blocked on a FIFO returns.
+func JSONLinesFile[T any](path string) nodes.Generator[T] {
+	return func(ctx context.Context) (<-chan T, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return nodes.Produce(ctx, func(emit func(T) bool) error {
+			defer f.Close()
+			defer closeOnCancel(ctx, f)()
+			return decodeJSONLines(f, emit)
+		}), nil
+	}
+}
+
+func decodeJSONLines[T any](r io.Reader, emit func(T) bool) error {
+	dec := json.NewDecoder(r)
+	for {
+		var v T
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !emit(v) {
+			return nil
+		}
+	}
+}
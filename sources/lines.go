@@ -0,0 +1,67 @@
+package sources
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// Lines returns a Generator that emits the lines of r without line terminators.
+// Lines longer than bufio.MaxScanTokenSize are reported as errors. r is not closed at the end of
+// input, but if it is an io.Closer it is closed when ctx is canceled, see closeOnCancel.
+func Lines(r io.Reader) nodes.Generator[string] {
+	return func(ctx context.Context) (<-chan string, error) {
+		return nodes.Produce(ctx, func(emit func(string) bool) error {
+			defer closeOnCancel(ctx, r)()
+			return scanLines(r, emit)
+		}), nil
+	}
+}
+
+// LinesFile is like Lines, but opens the file at path when the generator starts
+// and closes it when done or when ctx is canceled, so that a read blocked on a FIFO returns.
+func LinesFile(path string) nodes.Generator[string] {
+	return func(ctx context.Context) (<-chan string, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return nodes.Produce(ctx, func(emit func(string) bool) error {
+			defer f.Close()
+			defer closeOnCancel(ctx, f)()
+			return scanLines(f, emit)
+		}), nil
+	}
+}
+
+// Stdin returns a Generator that emits the lines of standard input.
+// Standard input is closed when the generator's context is canceled.
+func Stdin() nodes.Generator[string] {
+	return Lines(os.Stdin)
+}
+
+// closeOnCancel closes r, if it is an io.Closer, once ctx is canceled, so that a read blocked on
+// a pipe returns and the producing goroutine exits. A read from a blocking descriptor, such as a
+// terminal, is not interrupted by the close; the generator node still stops at once, as it does
+// not wait for its producer. The returned function stops the watch when reading is done.
+func closeOnCancel(ctx context.Context, r io.Reader) func() bool {
+	c, ok := r.(io.Closer)
+	if !ok {
+		return func() bool { return false }
+	}
+	return context.AfterFunc(ctx, func() { c.Close() })
+}
+
+func scanLines(r io.Reader, emit func(string) bool) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if !emit(sc.Text()) {
+			return nil
+		}
+	}
+	return sc.Err()
+}
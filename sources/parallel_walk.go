@@ -0,0 +1,355 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// ErrSymlinkLoop is the error of a symbolic link that points to one of its own ancestors.
+var ErrSymlinkLoop = errors.New("symbolic link loop")
+
+// SymlinkPolicy controls how ParallelWalk treats symbolic links.
+type SymlinkPolicy int
+
+const (
+	// SymlinksSkip ignores symbolic links.
+	SymlinksSkip SymlinkPolicy = iota
+	// SymlinksFiles follows links to regular files and ignores links to directories.
+	SymlinksFiles
+	// SymlinksFollow follows all links. A link to an ancestor directory is reported
+	// with ErrSymlinkLoop instead of being walked again.
+	SymlinksFollow
+)
+
+// ParallelWalkOptions configures ParallelWalk.
+type ParallelWalkOptions struct {
+	// WalkOptions filter files and directories as in Walk; with SkipErrors, error
+	// entries are dropped instead of being emitted.
+	WalkOptions
+
+	// Workers is the number of directories listed in parallel, 8 if not positive.
+	Workers int
+
+	// IgnoreFiles are names of .gitignore-style files, e.g. ".gitignore". Each such file
+	// found in a directory applies to that directory and everything below it.
+	IgnoreFiles []string
+
+	Symlinks SymlinkPolicy
+
+	// MaxDepth limits how deep the walk goes: 1 lists only the roots, 2 also their
+	// subdirectories, and so on. 0 means no limit.
+	MaxDepth int
+}
+
+// WalkEntry is a regular file found by ParallelWalk, or an error found while walking.
+type WalkEntry struct {
+	Path string
+	// Rel is the slash-separated path relative to the root the entry was found under.
+	Rel string
+	// Depth is 1 for the entries of a root directory.
+	Depth int
+	Err   error
+}
+
+// ParallelWalk returns a Generator that walks roots with a pipeline of its own: directories
+// feed a worker pool that lists them and emits the files and subdirectories found, and the
+// subdirectories are fed back to the pool. Entries arrive in no particular order.
+//
+// A root may be a directory or a file; files named as roots are emitted regardless of the
+// filters. Unreadable roots and directories, broken links and link loops are emitted as
+// entries with Err set, and the walk goes on.
+func ParallelWalk(roots []string, opts ...ParallelWalkOptions) nodes.Generator[WalkEntry] {
+	var o ParallelWalkOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Workers <= 0 {
+		o.Workers = 8
+	}
+
+	return func(ctx context.Context) (<-chan WalkEntry, error) {
+		return nodes.Produce(ctx, func(emit func(WalkEntry) bool) error {
+			w := &walker{opts: o, emit: emit, queue: newDirQueue()}
+			return w.run(ctx, roots)
+		}), nil
+	}
+}
+
+// dirJob is a directory waiting to be listed.
+type dirJob struct {
+	path   string
+	rel    string
+	depth  int
+	ignore ignoreStack
+	// ancestors are the directories from the root down to this one, for loop detection;
+	// only kept with SymlinksFollow
+	ancestors []os.FileInfo
+}
+
+// listing is the result of listing one directory.
+type listing struct {
+	entries []WalkEntry
+	dirs    []dirJob
+}
+
+type walker struct {
+	opts  ParallelWalkOptions
+	emit  func(WalkEntry) bool
+	queue *dirQueue
+}
+
+func (w *walker) run(ctx context.Context, roots []string) error {
+	for _, root := range roots {
+		if !w.addRoot(root) {
+			return nil
+		}
+	}
+	if w.queue.finished() {
+		return nil
+	}
+
+	dirs := nodes.NewGenerator(w.queue.generator)
+	lister := nodes.NewContextWorkerPool(func(ctx context.Context, job dirJob) (listing, error) {
+		return w.list(job), nil
+	}, nodes.Config{Workers: w.opts.Workers, Buffer: w.opts.Workers})
+	collector := nodes.NewResultAggregator(func(l listing) error {
+		defer w.queue.done()
+		for _, e := range l.entries {
+			if !w.send(e) {
+				return ctx.Err()
+			}
+		}
+		w.queue.push(l.dirs...)
+		return nil
+	})
+
+	if err := pipelines.Connect(dirs, lister); err != nil {
+		return err
+	}
+	if err := pipelines.Connect(lister, collector); err != nil {
+		return err
+	}
+
+	p := pipelines.New()
+	p.Add(dirs, lister, collector)
+	if err := p.Run(ctx); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// send emits e unless it is an error dropped by SkipErrors, and reports false once
+// the consumer is gone.
+func (w *walker) send(e WalkEntry) bool {
+	if e.Err != nil && w.opts.SkipErrors {
+		return true
+	}
+	return w.emit(e)
+}
+
+func (w *walker) addRoot(root string) bool {
+	info, err := os.Stat(root)
+	if err != nil {
+		return w.send(WalkEntry{Path: root, Err: err})
+	}
+	if !info.IsDir() {
+		return w.send(WalkEntry{Path: root, Rel: filepath.Base(root)})
+	}
+
+	job := dirJob{path: root, rel: ".", depth: 1}
+	if w.opts.Symlinks == SymlinksFollow {
+		job.ancestors = []os.FileInfo{info}
+	}
+	w.queue.push(job)
+	return true
+}
+
+// list reads one directory. Errors go into the listing, so that they are reported
+// like files and do not stop the pool.
+func (w *walker) list(job dirJob) listing {
+	var l listing
+
+	entries, err := os.ReadDir(job.path)
+	if err != nil {
+		// ReadDir may still return the entries read before the error
+		l.entries = append(l.entries, WalkEntry{Path: job.path, Rel: job.rel, Depth: job.depth - 1, Err: err})
+	}
+
+	ignore := job.ignore
+	for _, e := range entries {
+		if e.Type().IsRegular() && slices.Contains(w.opts.IgnoreFiles, e.Name()) {
+			data, err := os.ReadFile(filepath.Join(job.path, e.Name()))
+			if err != nil {
+				l.entries = append(l.entries, w.entry(job, e.Name(), err))
+				continue
+			}
+			ignore = ignore.with(parseIgnore(job.relDir(), data))
+		}
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(job.path, e.Name())
+		rel := job.child(e.Name())
+		typ := e.Type()
+
+		var target os.FileInfo
+		if typ&fs.ModeSymlink != 0 {
+			if w.opts.Symlinks == SymlinksSkip {
+				continue
+			}
+			if target, err = os.Stat(path); err != nil {
+				l.entries = append(l.entries, w.entry(job, e.Name(), err))
+				continue
+			}
+			if target.IsDir() && w.opts.Symlinks == SymlinksFiles {
+				continue
+			}
+			typ = target.Mode().Type()
+		}
+
+		isDir := typ.IsDir()
+		if matchAny(w.opts.Exclude, rel) || ignore.ignored(rel, isDir) {
+			continue
+		}
+
+		switch {
+		case isDir:
+			if w.opts.MaxDepth > 0 && job.depth >= w.opts.MaxDepth {
+				continue
+			}
+			child := dirJob{path: path, rel: rel, depth: job.depth + 1, ignore: ignore}
+			if w.opts.Symlinks == SymlinksFollow {
+				if target == nil {
+					if target, err = e.Info(); err != nil {
+						l.entries = append(l.entries, w.entry(job, e.Name(), err))
+						continue
+					}
+				}
+				if slices.ContainsFunc(job.ancestors, func(a os.FileInfo) bool { return os.SameFile(a, target) }) {
+					err := &fs.PathError{Op: "walk", Path: path, Err: ErrSymlinkLoop}
+					l.entries = append(l.entries, w.entry(job, e.Name(), err))
+					continue
+				}
+				child.ancestors = append(slices.Clip(job.ancestors), target)
+			}
+			l.dirs = append(l.dirs, child)
+
+		case typ.IsRegular():
+			if len(w.opts.Include) > 0 && !matchAny(w.opts.Include, rel) {
+				continue
+			}
+			l.entries = append(l.entries, w.entry(job, e.Name(), nil))
+		}
+	}
+	return l
+}
+
+func (w *walker) entry(job dirJob, name string, err error) WalkEntry {
+	return WalkEntry{Path: filepath.Join(job.path, name), Rel: job.child(name), Depth: job.depth, Err: err}
+}
+
+// relDir is the relative path of the directory as used by ignore files: "" for the root.
+func (j dirJob) relDir() string {
+	if j.rel == "." {
+		return ""
+	}
+	return j.rel
+}
+
+func (j dirJob) child(name string) string {
+	if j.rel == "." {
+		return name
+	}
+	return j.rel + "/" + name
+}
+
+// dirQueue holds the directories waiting to be listed. Listed directories are taken out
+// with done once their subdirectories were pushed, so the queue knows the walk is over
+// when nothing is queued or being listed.
+type dirQueue struct {
+	mu      sync.Mutex
+	jobs    []dirJob
+	pending int
+	wake    chan struct{}
+}
+
+func newDirQueue() *dirQueue {
+	return &dirQueue{wake: make(chan struct{}, 1)}
+}
+
+func (q *dirQueue) push(jobs ...dirJob) {
+	q.mu.Lock()
+	q.jobs = append(q.jobs, jobs...)
+	q.pending += len(jobs)
+	q.mu.Unlock()
+	q.notify()
+}
+
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	q.pending--
+	q.mu.Unlock()
+	q.notify()
+}
+
+func (q *dirQueue) finished() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending == 0
+}
+
+func (q *dirQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the most recently pushed job, which keeps the queue short on wide trees.
+func (q *dirQueue) pop() (job dirJob, ok, finished bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n := len(q.jobs); n > 0 {
+		job = q.jobs[n-1]
+		q.jobs = q.jobs[:n-1]
+		return job, true, false
+	}
+	return job, false, q.pending == 0
+}
+
+// generator feeds queued directories to the worker pool until the walk is over.
+func (q *dirQueue) generator(ctx context.Context) (<-chan dirJob, error) {
+	out := make(chan dirJob)
+	go func() {
+		defer close(out)
+
+		for {
+			job, ok, finished := q.pop()
+			switch {
+			case finished:
+				return
+			case ok:
+				select {
+				case out <- job:
+				case <-ctx.Done():
+					return
+				}
+			default:
+				select {
+				case <-q.wake:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
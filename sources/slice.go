@@ -0,0 +1,54 @@
+package sources
+
+import (
+	"context"
+	"iter"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// Slice returns a Generator that emits the items in order.
+func Slice[T any](items []T) nodes.Generator[T] {
+	return func(ctx context.Context) (<-chan T, error) {
+		return nodes.Produce(ctx, func(emit func(T) bool) error {
+			for _, v := range items {
+				if !emit(v) {
+					return nil
+				}
+			}
+			return nil
+		}), nil
+	}
+}
+
+// Seq returns a Generator that emits the values of seq.
+func Seq[T any](seq iter.Seq[T]) nodes.Generator[T] {
+	return func(ctx context.Context) (<-chan T, error) {
+		return nodes.Produce(ctx, func(emit func(T) bool) error {
+			for v := range seq {
+				if !emit(v) {
+					return nil
+				}
+			}
+			return nil
+		}), nil
+	}
+}
+
+// Seq2 returns a Generator that emits the values of seq and stops at the first non-nil error,
+// reporting it.
+func Seq2[T any](seq iter.Seq2[T, error]) nodes.Generator[T] {
+	return func(ctx context.Context) (<-chan T, error) {
+		return nodes.Produce(ctx, func(emit func(T) bool) error {
+			for v, err := range seq {
+				if err != nil {
+					return err
+				}
+				if !emit(v) {
+					return nil
+				}
+			}
+			return nil
+		}), nil
+	}
+}
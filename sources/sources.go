@@ -0,0 +1,8 @@
+// Package sources provides ready-made nodes.Generator implementations: slices and iterators,
+// text lines, CSV records, JSON Lines, sequential and parallel directory walks, file system
+// watches, tickers and stdin.
+//
+// Every source stops when its context is canceled and reports read errors with
+// nodes.ReportError, so a generator node built from it fails instead of ending early. Their
+// goroutines run under nodes.Produce, so a panic fails the node with a *nodes.PanicError.
+package sources
@@ -0,0 +1,402 @@
+package sources_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+)
+
+// collect прогоняет генератор через агрегатор и возвращает собранные элементы и ошибку пайплайна
+func collect[T any](t *testing.T, gen nodes.Generator[T]) ([]T, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var items []T
+	src := nodes.NewGenerator(gen)
+	agg := nodes.NewResultAggregator(func(v T) error {
+		items = append(items, v)
+		return nil
+	})
+	if err := pipelines.Connect(src, agg); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	p := pipelines.New()
+	p.Add(src, agg)
+	return items, p.Run(ctx)
+}
+
+func TestJSONLines(t *testing.T) {
+	type rec struct{ N int }
+
+	got, err := collect(t, sources.JSONLines[rec](strings.NewReader(`{"N":1}`+"\n"+`{"N":2}`+"\n")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(got, []rec{{1}, {2}}) {
+		t.Errorf("got %v", got)
+	}
+
+	// Битая строка должна завершить пайплайн ошибкой, а не тихо оборвать поток
+	if _, err := collect(t, sources.JSONLines[rec](strings.NewReader(`{"N":1}`+"\n"+`{"N":`))); err == nil {
+		t.Fatal("expected decode error")
+	}
+}
+
+func TestSliceAndSeq(t *testing.T) {
+	got, err := collect(t, sources.Slice([]int{1, 2, 3}))
+	if err != nil || !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Slice: got %v, %v", got, err)
+	}
+
+	got, err = collect(t, sources.Seq(slices.Values([]int{4, 5})))
+	if err != nil || !slices.Equal(got, []int{4, 5}) {
+		t.Errorf("Seq: got %v, %v", got, err)
+	}
+
+	// Seq2 выдаёт значения до первой ошибки и завершает пайплайн ею; отмена может опередить элемент 1
+	errBad := errors.New("bad item")
+	got, err = collect(t, sources.Seq2(func(yield func(int, error) bool) {
+		_ = yield(1, nil) && yield(0, errBad) && yield(2, nil)
+	}))
+	if !errors.Is(err, errBad) || len(got) > 1 || len(got) == 1 && got[0] != 1 {
+		t.Errorf("Seq2: got %v, %v", got, err)
+	}
+}
+
+func TestLines(t *testing.T) {
+	got, err := collect(t, sources.Lines(strings.NewReader("a\r\nb\n\nc")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"a", "b", "", "c"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Слишком длинная строка — ошибка, а не обрезанный поток
+	long := strings.Repeat("x", bufio.MaxScanTokenSize+1)
+	if _, err := collect(t, sources.Lines(strings.NewReader(long))); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected bufio.ErrTooLong, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "lines.txt")
+	if err := os.WriteFile(path, []byte("x\ny\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = collect(t, sources.LinesFile(path))
+	if err != nil || !slices.Equal(got, []string{"x", "y"}) {
+		t.Errorf("LinesFile: got %q, %v", got, err)
+	}
+	if _, err := collect(t, sources.LinesFile(path+".missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestLinesCancelBlockedRead(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	// Писатель молчит: чтение висит, пока отмена не закроет читателя
+	r, w := io.Pipe()
+	defer w.Close()
+
+	gen := nodes.NewGenerator(sources.Lines(r))
+	out, err := gen.Output()
+	if err != nil {
+		t.Fatalf("Output failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	errc := make(chan error, 1)
+	go func() { errc <- gen.Run(ctx) }()
+
+	if _, err := w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-out; line != "first" {
+		t.Fatalf("got %q, want first", line)
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestCSV(t *testing.T) {
+	got, err := collect(t, sources.CSV(strings.NewReader("a;b\n\"c;d\";e\n"), func(r *csv.Reader) {
+		r.Comma = ';'
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := [][]string{{"a", "b"}, {"c;d", "e"}}; !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Разное число полей по умолчанию — ошибка
+	if _, err := collect(t, sources.CSV(strings.NewReader("a,b\nc\n"))); !errors.Is(err, csv.ErrFieldCount) {
+		t.Errorf("expected csv.ErrFieldCount, got %v", err)
+	}
+	if _, err := collect(t, sources.CSVFile(filepath.Join(t.TempDir(), "missing.csv"))); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestTicker(t *testing.T) {
+	got, err := collect(t, sources.Ticker(time.Millisecond, 3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d ticks, want 3", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i].After(got[i-1]) {
+			t.Errorf("tick %d at %v is not after %v", i, got[i], got[i-1])
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.log", "skip/c.txt", "sub/d.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := collect(t, sources.Walk(dir, sources.WalkOptions{
+		Include: []string{"*.txt"},
+		Exclude: []string{"skip"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub/d.txt")}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := collect(t, sources.Walk(filepath.Join(dir, "missing"))); err == nil {
+		t.Error("expected error for a missing root")
+	}
+}
+
+func TestParallelWalk(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":            "*.log\n/build/\n!keep.log\n",
+		"a.txt":                 "",
+		"b.log":                 "",
+		"keep.log":              "",
+		"build/out.txt":         "",
+		"src/build/gen.txt":     "",
+		"src/.gitignore":        "tmp/**\n",
+		"src/tmp/x/y.txt":       "",
+		"src/deep/er/z.txt":     "",
+		"vendor/lib/vendor.txt": "",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Ссылка на предка даёт ошибку цикла, ссылка на файл — обычный файл
+	if err := os.Symlink(dir, filepath.Join(dir, "src/loop")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	walk := func(opts sources.ParallelWalkOptions) (files, errs []string) {
+		entries, err := collect(t, sources.ParallelWalk([]string{dir}, opts))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, e := range entries {
+			if e.Err != nil {
+				errs = append(errs, e.Rel)
+			} else {
+				files = append(files, e.Rel)
+			}
+		}
+		slices.Sort(files)
+		return files, errs
+	}
+
+	files, errs := walk(sources.ParallelWalkOptions{
+		WalkOptions: sources.WalkOptions{Exclude: []string{"vendor"}},
+		Workers:     3,
+		IgnoreFiles: []string{".gitignore"},
+		Symlinks:    sources.SymlinksFollow,
+	})
+	want := []string{
+		".gitignore", "a.txt", "keep.log", "link.txt",
+		"src/.gitignore", "src/build/gen.txt", "src/deep/er/z.txt",
+	}
+	if !slices.Equal(files, want) {
+		t.Errorf("got %v, want %v", files, want)
+	}
+	if !slices.Equal(errs, []string{"src/loop"}) {
+		t.Errorf("expected a loop error for src/loop, got %v", errs)
+	}
+
+	files, errs = walk(sources.ParallelWalkOptions{MaxDepth: 2})
+	want = []string{".gitignore", "a.txt", "b.log", "build/out.txt", "keep.log", "src/.gitignore"}
+	if !slices.Equal(files, want) || len(errs) != 0 {
+		t.Errorf("got %v and errors %v, want %v", files, errs, want)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := sources.Watch([]string{dir}, sources.WatchOptions{
+			WalkOptions:  sources.WalkOptions{Exclude: []string{"*.tmp"}},
+			Existing:     true,
+			Debounce:     30 * time.Millisecond,
+			Poll:         poll,
+			PollInterval: 20 * time.Millisecond,
+		})(ctx)
+		if err != nil {
+			t.Fatalf("poll=%v: Watch failed: %v", poll, err)
+		}
+
+		expect := func(rel string, op sources.Op) {
+			t.Helper()
+			select {
+			case ev := <-events:
+				if ev.Err != nil || ev.Rel != rel || ev.Op != op {
+					t.Fatalf("poll=%v: got %+v, want %s %s", poll, ev, op, rel)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("poll=%v: no event, want %s %s", poll, op, rel)
+			}
+		}
+
+		expect("a.txt", sources.OpExisting)
+
+		// Новый каталог с файлом; исключённый файл событий не даёт
+		if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"sub/b.txt", "sub/c.tmp"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("b"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expect("sub/b.txt", sources.OpCreate)
+
+		// Серия записей склеивается в одно событие
+		for range 3 {
+			f, err := os.OpenFile(filepath.Join(dir, "a.txt"), os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString("more")
+			f.Close()
+		}
+		expect("a.txt", sources.OpWrite)
+
+		if err := os.Remove(filepath.Join(dir, "sub/b.txt")); err != nil {
+			t.Fatal(err)
+		}
+		expect("sub/b.txt", sources.OpRemove)
+
+		select {
+		case ev := <-events:
+			t.Fatalf("poll=%v: unexpected event %+v", poll, ev)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		cancel()
+		for range events {
+		}
+	}
+}
+
+func TestWatchDebounceClock(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "start"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	clock := pipelinetest.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := sources.Watch([]string{dir}, sources.WatchOptions{
+		Existing:     true,
+		Debounce:     time.Minute,
+		Clock:        clock,
+		Poll:         true,
+		PollInterval: 10 * time.Millisecond,
+	})(ctx)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	// Существующий файл сообщается сразу после первого сканирования, без паузы
+	if ev := <-events; ev.Rel != "start" || ev.Op != sources.OpExisting {
+		t.Fatalf("got %+v, want existing start", ev)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Изменение ждёт, пока часы не отсчитают паузу, сколько бы реального времени ни прошло
+	clock.BlockUntil(1)
+	select {
+	case ev := <-events:
+		t.Fatalf("event %+v before the debounce delay", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case ev := <-events:
+		if ev.Err != nil || ev.Rel != "a.txt" || ev.Op != sources.OpCreate {
+			t.Fatalf("got %+v, want create a.txt", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event after the debounce delay")
+	}
+
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,36 @@
+package sources
+
+import (
+	"context"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// Ticker returns a Generator that emits the current time every interval until its context
+// is canceled. If limit is given and positive, it stops after that many ticks.
+func Ticker(interval time.Duration, limit ...int) nodes.Generator[time.Time] {
+	n := 0
+	if len(limit) > 0 {
+		n = limit[0]
+	}
+
+	return func(ctx context.Context) (<-chan time.Time, error) {
+		return nodes.Produce(ctx, func(emit func(time.Time) bool) error {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+
+			for i := 0; n <= 0 || i < n; i++ {
+				select {
+				case now := <-t.C:
+					if !emit(now) {
+						return nil
+					}
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			return nil
+		}), nil
+	}
+}
@@ -0,0 +1,92 @@
+package sources
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// WalkOptions configures Walk.
+type WalkOptions struct {
+	// Include, if not empty, keeps only files matching at least one pattern.
+	// Exclude skips files and directories matching any pattern.
+	// Patterns use path.Match syntax; a pattern without '/' is matched against the base name,
+	// otherwise against the slash-separated path relative to the root.
+	Include []string
+	Exclude []string
+
+	// SkipErrors makes Walk skip unreadable entries instead of failing.
+	SkipErrors bool
+}
+
+// Walk returns a Generator that emits the paths of regular files under root in lexical order.
+// Errors from reading directories are reported unless opts.SkipErrors is set.
+func Walk(root string, opts ...WalkOptions) nodes.Generator[string] {
+	var o WalkOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	return func(ctx context.Context) (<-chan string, error) {
+		if _, err := os.Stat(root); err != nil {
+			return nil, err
+		}
+
+		return nodes.Produce(ctx, func(emit func(string) bool) error {
+			return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					if o.SkipErrors {
+						return nil
+					}
+					return err
+				}
+
+				rel, _ := filepath.Rel(root, p)
+				rel = filepath.ToSlash(rel)
+				if rel != "." && matchAny(o.Exclude, rel) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+
+				if !d.Type().IsRegular() {
+					return nil
+				}
+				if len(o.Include) > 0 && !matchAny(o.Include, rel) {
+					return nil
+				}
+
+				if !emit(p) {
+					return filepath.SkipAll
+				}
+				return nil
+			})
+		}), nil
+	}
+}
+
+// MatchGlob reports whether the slash-separated relative path rel matches pattern,
+// using the rules described in WalkOptions.
+func MatchGlob(pattern, rel string) bool {
+	name := rel
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(rel)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if MatchGlob(p, rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,386 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// ErrEventsLost is the error of a FileEvent emitted when the kernel event queue overflowed,
+// so that changes may have been missed.
+var ErrEventsLost = errors.New("file system events were lost")
+
+// Op is the kind of change reported by a FileEvent.
+type Op int
+
+const (
+	// OpExisting reports a file present when watching started, see WatchOptions.Existing.
+	OpExisting Op = iota
+	OpCreate
+	OpWrite
+	OpRemove
+)
+
+var opNames = []string{"existing", "create", "write", "remove"}
+
+func (o Op) String() string {
+	if int(o) < len(opNames) {
+		return opNames[o]
+	}
+	return fmt.Sprintf("op(%d)", int(o))
+}
+
+// FileEvent is a change of a file under a watched root, or an error found while watching.
+type FileEvent struct {
+	Path string
+	// Rel is the slash-separated path relative to the root the file is under.
+	Rel string
+	Op  Op
+	Err error
+}
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// WalkOptions filter files and directories as in Walk; with SkipErrors, error
+	// events are dropped instead of being emitted.
+	WalkOptions
+
+	// Existing emits an OpExisting event for every file present when watching starts.
+	Existing bool
+
+	// Debounce is how long a file must stay quiet before its changes are emitted as one
+	// event, 100ms if 0.
+	Debounce time.Duration
+	// Clock measures Debounce; nil means the system clock.
+	Clock nodes.Clock
+
+	// Poll forces polling even where inotify is available.
+	Poll bool
+	// PollInterval is the time between two scans when polling, 1s if 0.
+	PollInterval time.Duration
+}
+
+// watchBackend reports raw changes to events until ctx is canceled.
+type watchBackend interface {
+	run(ctx context.Context, events chan<- FileEvent) error
+}
+
+// Watch returns a Generator of changes to the regular files under roots, which must be
+// directories. It never ends by itself: it runs until its context is canceled, which makes
+// the pipeline it feeds long-lived.
+//
+// On Linux, changes come from inotify; elsewhere, or with opts.Poll, the roots are scanned
+// every opts.PollInterval and compared with the previous scan. Bursts of changes to one file
+// are debounced into a single event: a file created and then written is reported as created,
+// a file created and removed again is not reported at all.
+//
+// inotify watches directories added later as well; files already in them are reported as
+// created. A directory moved out of a root is reported as one OpRemove of its own path.
+func Watch(roots []string, opts ...WatchOptions) nodes.Generator[FileEvent] {
+	var o WatchOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Debounce <= 0 {
+		o.Debounce = 100 * time.Millisecond
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.Clock == nil {
+		o.Clock = systemClock{}
+	}
+
+	return func(ctx context.Context) (<-chan FileEvent, error) {
+		for _, root := range roots {
+			info, err := os.Stat(root)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				return nil, &fs.PathError{Op: "watch", Path: root, Err: errors.New("not a directory")}
+			}
+		}
+
+		f := &watchFilter{opts: o}
+		var backend watchBackend
+		if !o.Poll {
+			backend = newNativeWatcher(roots, f)
+		}
+		if backend == nil {
+			backend = &poller{roots: roots, filter: f, interval: o.PollInterval}
+		}
+
+		return nodes.Produce(ctx, func(emit func(FileEvent) bool) error {
+			events := make(chan FileEvent)
+			errc := make(chan error, 1)
+			go func() {
+				defer close(events)
+				errc <- backend.run(ctx, events)
+			}()
+
+			d := &debouncer{delay: o.Debounce, clock: o.Clock, emit: emit, skipErrors: o.SkipErrors}
+			if !d.run(ctx, events) {
+				return nil
+			}
+			return <-errc
+		}), nil
+	}
+}
+
+// systemClock is the nodes.Clock of a Watch without WatchOptions.Clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// watchFilter applies WatchOptions to the paths found by a backend.
+type watchFilter struct {
+	opts WatchOptions
+}
+
+func (f *watchFilter) rel(root, path string) string {
+	rel, _ := filepath.Rel(root, path)
+	return filepath.ToSlash(rel)
+}
+
+// skipDir reports whether the directory at path, under root, is excluded.
+func (f *watchFilter) skipDir(root, path string) bool {
+	return path != root && matchAny(f.opts.Exclude, f.rel(root, path))
+}
+
+// keepFile reports whether the file at path, under root, passes the filters.
+func (f *watchFilter) keepFile(root, path string) bool {
+	rel := f.rel(root, path)
+	if matchAny(f.opts.Exclude, rel) {
+		return false
+	}
+	return len(f.opts.Include) == 0 || matchAny(f.opts.Include, rel)
+}
+
+// event returns the FileEvent of path under root.
+func (f *watchFilter) event(root, path string, op Op) FileEvent {
+	return FileEvent{Path: path, Rel: f.rel(root, path), Op: op}
+}
+
+// pendingEvent is a change waiting for its file to become quiet.
+type pendingEvent struct {
+	event FileEvent
+	due   time.Time
+}
+
+// debouncer merges the changes of each file until no new change arrived for delay.
+type debouncer struct {
+	delay      time.Duration
+	clock      nodes.Clock
+	emit       func(FileEvent) bool
+	skipErrors bool
+
+	pending map[string]*pendingEvent
+}
+
+// run reports false once emit does.
+func (d *debouncer) run(ctx context.Context, events <-chan FileEvent) bool {
+	d.pending = make(map[string]*pendingEvent)
+	// wake fires when the earliest pending change may be due; nil while nothing is pending
+	var wake <-chan time.Time
+
+	for {
+		select {
+		case ev, open := <-events:
+			if !open {
+				return d.flush(time.Time{})
+			}
+			if ev.Err != nil || ev.Op == OpExisting {
+				if (ev.Err == nil || !d.skipErrors) && !d.emit(ev) {
+					return false
+				}
+				continue
+			}
+			if d.add(ev, d.clock.Now().Add(d.delay)) {
+				wake = d.clock.After(d.delay)
+			}
+
+		case now := <-wake:
+			wake = nil
+			if !d.flush(now) {
+				return false
+			}
+			if next, ok := d.next(); ok {
+				wake = d.clock.After(next.Sub(now))
+			}
+
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// add merges ev into the pending change of its file and reports whether the wake-up
+// must be armed because nothing else is pending.
+func (d *debouncer) add(ev FileEvent, due time.Time) bool {
+	idle := len(d.pending) == 0
+
+	p, ok := d.pending[ev.Path]
+	if !ok {
+		d.pending[ev.Path] = &pendingEvent{event: ev, due: due}
+		return idle
+	}
+	p.due = due
+
+	switch prev := p.event.Op; {
+	case prev == OpCreate && ev.Op == OpWrite:
+		// still being written after creation
+	case prev == OpCreate && ev.Op == OpRemove:
+		// never reported, so nothing to report
+		delete(d.pending, ev.Path)
+	case prev == OpRemove && ev.Op != OpRemove:
+		// replaced, e.g. by an editor saving through a temporary file
+		p.event.Op = OpWrite
+	default:
+		p.event.Op = ev.Op
+	}
+	return false
+}
+
+// flush emits the changes due at now, or all of them if now is zero.
+func (d *debouncer) flush(now time.Time) bool {
+	for path, p := range d.pending {
+		if !now.IsZero() && p.due.After(now) {
+			continue
+		}
+		delete(d.pending, path)
+		if !d.emit(p.event) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *debouncer) next() (time.Time, bool) {
+	var next time.Time
+	for _, p := range d.pending {
+		if next.IsZero() || p.due.Before(next) {
+			next = p.due
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// fileState is what polling compares between scans.
+type fileState struct {
+	root    string
+	size    int64
+	modTime time.Time
+}
+
+// poller detects changes by scanning the roots periodically.
+type poller struct {
+	roots    []string
+	filter   *watchFilter
+	interval time.Duration
+
+	// reported holds the paths of errors already emitted, so that a directory that stays
+	// unreadable is reported once
+	reported map[string]bool
+}
+
+func (p *poller) run(ctx context.Context, events chan<- FileEvent) error {
+	send := func(ev FileEvent) bool {
+		select {
+		case events <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	p.reported = make(map[string]bool)
+	prev, ok := p.scan(send)
+	if !ok {
+		return nil
+	}
+	if p.filter.opts.Existing {
+		for path, st := range prev {
+			if !send(p.filter.event(st.root, path, OpExisting)) {
+				return nil
+			}
+		}
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		cur, ok := p.scan(send)
+		if !ok {
+			return nil
+		}
+		for path, st := range cur {
+			old, found := prev[path]
+			switch {
+			case !found:
+				ok = send(p.filter.event(st.root, path, OpCreate))
+			case old.size != st.size || !old.modTime.Equal(st.modTime):
+				ok = send(p.filter.event(st.root, path, OpWrite))
+			}
+			if !ok {
+				return nil
+			}
+		}
+		for path, st := range prev {
+			if _, found := cur[path]; !found && !send(p.filter.event(st.root, path, OpRemove)) {
+				return nil
+			}
+		}
+		prev = cur
+	}
+}
+
+// scan lists the files under the roots. It reports false once send does.
+func (p *poller) scan(send func(FileEvent) bool) (map[string]fileState, bool) {
+	files := make(map[string]fileState)
+	for _, root := range p.roots {
+		stopped := false
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if !p.reported[path] {
+					p.reported[path] = true
+					if !send(FileEvent{Path: path, Rel: p.filter.rel(root, path), Err: err}) {
+						stopped = true
+						return filepath.SkipAll
+					}
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if p.filter.skipDir(root, path) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || !p.filter.keepFile(root, path) {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				files[path] = fileState{root: root, size: info.Size(), modTime: info.ModTime()}
+			}
+			return nil
+		})
+		if stopped {
+			return nil, false
+		}
+	}
+	return files, true
+}
@@ -0,0 +1,170 @@
+//go:build linux
+
+package sources
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// watchedDir is a directory with an inotify watch.
+type watchedDir struct {
+	path string
+	root string
+}
+
+// inotifyWatcher reports changes with inotify. Every directory under the roots gets a watch.
+type inotifyWatcher struct {
+	roots  []string
+	filter *watchFilter
+	fd     int
+	file   *os.File
+
+	dirs map[int32]watchedDir
+}
+
+// newNativeWatcher returns an inotify backend, or nil if inotify is not available.
+func newNativeWatcher(roots []string, filter *watchFilter) watchBackend {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil
+	}
+	// a non-blocking descriptor is served by the runtime poller, so Close unblocks Read
+	return &inotifyWatcher{
+		roots:  roots,
+		filter: filter,
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		dirs:   make(map[int32]watchedDir),
+	}
+}
+
+func (w *inotifyWatcher) run(ctx context.Context, events chan<- FileEvent) error {
+	defer w.file.Close()
+	stop := context.AfterFunc(ctx, func() { w.file.Close() })
+	defer stop()
+
+	send := func(ev FileEvent) bool {
+		select {
+		case events <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for _, root := range w.roots {
+		if !w.addTree(root, root, OpExisting, send) {
+			return nil
+		}
+	}
+
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + syscall.SizeofInotifyEvent
+			off = nameStart + int(raw.Len)
+			name := string(bytes.TrimRight(buf[nameStart:off], "\x00"))
+
+			if !w.handle(raw.Wd, raw.Mask, name, send) {
+				return nil
+			}
+		}
+	}
+}
+
+// handle turns one inotify event into FileEvents. It reports false once send does.
+func (w *inotifyWatcher) handle(wd int32, mask uint32, name string, send func(FileEvent) bool) bool {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		return send(FileEvent{Err: ErrEventsLost})
+	}
+	dir, ok := w.dirs[wd]
+	if !ok {
+		return true
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		// the directory was removed or unmounted
+		delete(w.dirs, wd)
+		return true
+	}
+	path := filepath.Join(dir.path, name)
+
+	if mask&syscall.IN_ISDIR != 0 {
+		switch {
+		case w.filter.skipDir(dir.root, path):
+			return true
+		case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+			return w.addTree(dir.root, path, OpCreate, send)
+		case mask&syscall.IN_MOVED_FROM != 0:
+			return send(w.filter.event(dir.root, path, OpRemove))
+		}
+		return true
+	}
+
+	if !w.filter.keepFile(dir.root, path) {
+		return true
+	}
+	switch {
+	case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		return send(w.filter.event(dir.root, path, OpCreate))
+	case mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0:
+		return send(w.filter.event(dir.root, path, OpWrite))
+	case mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+		return send(w.filter.event(dir.root, path, OpRemove))
+	}
+	return true
+}
+
+// addTree watches dir and its subdirectories and reports the files in them with op;
+// OpExisting files are reported only if WatchOptions.Existing is set. Directories are
+// watched before they are listed, so files created meanwhile are not missed.
+func (w *inotifyWatcher) addTree(root, dir string, op Op, send func(FileEvent) bool) bool {
+	stopped := false
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		ok := true
+		switch {
+		case err != nil:
+			ok = send(FileEvent{Path: path, Rel: w.filter.rel(root, path), Err: err})
+		case d.IsDir():
+			if w.filter.skipDir(root, path) {
+				return filepath.SkipDir
+			}
+			// w.file.Fd would switch the descriptor back to blocking mode
+			wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask)
+			if err != nil {
+				err = &fs.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+				ok = send(FileEvent{Path: path, Rel: w.filter.rel(root, path), Err: err})
+				break
+			}
+			w.dirs[int32(wd)] = watchedDir{path: path, root: root}
+		case d.Type().IsRegular() && w.filter.keepFile(root, path):
+			if op != OpExisting || w.filter.opts.Existing {
+				ok = send(w.filter.event(root, path, op))
+			}
+		}
+
+		if !ok {
+			stopped = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return !stopped
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package sources
+
+// newNativeWatcher returns nil: only Linux has a native backend, other systems are polled.
+func newNativeWatcher([]string, *watchFilter) watchBackend {
+	return nil
+}
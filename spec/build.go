@@ -0,0 +1,347 @@
+package spec
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+)
+
+// Graph is a pipeline built from a Spec.
+type Graph struct {
+	Spec  *Spec
+	Nodes []BuiltNode
+
+	runnables  []pipelines.Runnable
+	generators []pipelines.Runnable
+
+	drain     chan struct{}
+	drainOnce sync.Once
+}
+
+// BuiltNode is a node of a Graph. Node is the value returned by the factory of the kind,
+// so it can be inspected, for example for nodes.StatsProvider.
+type BuiltNode struct {
+	ID   string
+	Kind string
+	Node pipelines.Runnable
+}
+
+// Run runs all nodes of the graph as one pipelines.Pipeline.
+func (g *Graph) Run(ctx context.Context) error {
+	genCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go func() {
+		select {
+		case <-g.drain:
+			stop()
+		case <-genCtx.Done():
+		}
+	}()
+
+	p := pipelines.New()
+	p.Add(g.runnables...)
+	for _, gen := range g.generators {
+		p.Add(&drainable{Runnable: gen, ctx: genCtx, drain: g.drain})
+	}
+	return p.Run(ctx)
+}
+
+// Drain stops the generators of a running graph. The other nodes finish the items already
+// emitted, and Run returns once they are done. It may be called more than once.
+func (g *Graph) Drain() {
+	g.drainOnce.Do(func() {
+		close(g.drain)
+	})
+}
+
+// drainable runs a generator with a context that is also canceled by Graph.Drain,
+// and hides the resulting cancellation error.
+type drainable struct {
+	pipelines.Runnable
+
+	ctx   context.Context
+	drain <-chan struct{}
+}
+
+func (d *drainable) Run(context.Context) error {
+	err := d.Runnable.Run(d.ctx)
+	select {
+	case <-d.drain:
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+	default:
+	}
+	return err
+}
+
+// Load parses the definition at path and builds it with r.
+func Load(r *Registry, path string) (*Graph, error) {
+	s, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Build(s)
+}
+
+// Check validates s against the registered kinds without building any node. It returns
+// an ErrorList with every problem found.
+func (r *Registry) Check(s *Spec) error {
+	_, errs := r.check(s)
+	return errs.withFile(s.File).err()
+}
+
+// Build checks s and constructs and connects its nodes. Errors returned by factories or
+// while connecting are reported with the line of the node or edge. If building fails, the
+// nodes built so far that implement io.Closer, such as file sinks, are closed.
+func (r *Registry) Build(s *Spec) (*Graph, error) {
+	params, errs := r.check(s)
+	if len(errs) > 0 {
+		return nil, errs.withFile(s.File)
+	}
+
+	g := &Graph{Spec: s, drain: make(chan struct{})}
+	fail := func(line int, format string, args ...any) (*Graph, error) {
+		err := ErrorList{errorf(line, format, args...)}.withFile(s.File)
+		if cerr := g.close(); cerr != nil {
+			return nil, errors.Join(err, cerr)
+		}
+		return nil, err
+	}
+
+	ports := make(map[string]port, len(s.Nodes))
+	for i, n := range s.Nodes {
+		p, err := r.kinds[n.Kind].build(params[i], n.Config)
+		if err != nil {
+			return fail(n.Line, "node %q: %v", n.ID, err)
+		}
+		ports[n.ID] = p
+		g.Nodes = append(g.Nodes, BuiltNode{ID: n.ID, Kind: n.Kind, Node: p.node()})
+		if r.kinds[n.Kind].In == nil {
+			g.generators = append(g.generators, p)
+		} else {
+			g.runnables = append(g.runnables, p)
+		}
+	}
+
+	inputs := make(map[string][]any)
+	for _, e := range s.Edges {
+		ch, err := ports[e.From].output()
+		if err != nil {
+			return fail(e.Line, "connect %s -> %s: %v", e.From, e.To, err)
+		}
+		inputs[e.To] = append(inputs[e.To], ch)
+	}
+	for _, n := range s.Nodes {
+		if len(inputs[n.ID]) == 0 {
+			continue
+		}
+		converters, err := ports[n.ID].setInput(inputs[n.ID])
+		if err != nil {
+			return fail(n.Line, "node %q: set inputs: %v", n.ID, err)
+		}
+		g.runnables = append(g.runnables, converters...)
+	}
+	return g, nil
+}
+
+// close closes the nodes of a graph that will not be run.
+func (g *Graph) close() error {
+	var errs []error
+	for _, n := range g.Nodes {
+		if c, ok := n.Node.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close node %q: %w", n.ID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// check validates s and returns the converted params of every node.
+func (r *Registry) check(s *Spec) ([]Params, ErrorList) {
+	var (
+		errs   ErrorList
+		params = make([]Params, len(s.Nodes))
+		byID   = make(map[string]*NodeSpec, len(s.Nodes))
+		kinds  = make(map[string]*Kind, len(s.Nodes))
+	)
+
+	for i := range s.Nodes {
+		n := &s.Nodes[i]
+		if first, dup := byID[n.ID]; dup {
+			errs = append(errs, errorf(n.Line, "duplicate node id %q, first defined on line %d", n.ID, first.Line))
+			continue
+		}
+		byID[n.ID] = n
+
+		k, ok := r.kinds[n.Kind]
+		if !ok {
+			errs = append(errs, errorf(n.Line, "node %q: unknown kind %q", n.ID, n.Kind))
+			continue
+		}
+		kinds[n.ID] = k
+
+		var perrs ErrorList
+		params[i], perrs = checkParams(n, k)
+		errs = append(errs, perrs...)
+	}
+
+	var (
+		incoming = make(map[string]int)
+		outgoing = make(map[string]int)
+		next     = make(map[string][]EdgeSpec)
+	)
+	for _, e := range s.Edges {
+		ok := true
+		for _, id := range []string{e.From, e.To} {
+			if _, found := byID[id]; !found {
+				errs = append(errs, errorf(e.Line, "edge %s -> %s: unknown node %q", e.From, e.To, id))
+				ok = false
+			}
+		}
+		from, to := kinds[e.From], kinds[e.To]
+		if !ok || from == nil || to == nil {
+			continue
+		}
+
+		switch {
+		case from.Out == nil:
+			errs = append(errs, errorf(e.Line, "edge %s -> %s: node %q (kind %s) has no output", e.From, e.To, e.From, from.Name))
+		case to.In == nil:
+			errs = append(errs, errorf(e.Line, "edge %s -> %s: node %q (kind %s) has no input", e.From, e.To, e.To, to.Name))
+		case !assignable(from.Out, to.In):
+			errs = append(errs, errorf(e.Line, "edge %s -> %s: %s emits %s, but %s accepts %s",
+				e.From, e.To, e.From, TypeName(from.Out), e.To, TypeName(to.In)))
+		case from.Schema.SingleOutput && outgoing[e.From] > 0:
+			errs = append(errs, errorf(e.Line, "edge %s -> %s: node %q (kind %s) supports only one output, connected on line %d",
+				e.From, e.To, e.From, from.Name, next[e.From][0].Line))
+		}
+		outgoing[e.From]++
+		incoming[e.To]++
+		next[e.From] = append(next[e.From], e)
+	}
+
+	for i := range s.Nodes {
+		n := &s.Nodes[i]
+		k := kinds[n.ID]
+		if k == nil || byID[n.ID] != n {
+			// unknown kind or duplicate id, reported above
+			continue
+		}
+		if k.In != nil && incoming[n.ID] == 0 {
+			errs = append(errs, errorf(n.Line, "node %q has no incoming edges", n.ID))
+		}
+		if k.Out != nil && outgoing[n.ID] == 0 {
+			errs = append(errs, errorf(n.Line, "output of node %q is not connected", n.ID))
+		}
+	}
+
+	if e, cycle := findCycle(s.Nodes, next); cycle != nil {
+		errs = append(errs, errorf(e.Line, "edges form a cycle: %s", strings.Join(cycle, " -> ")))
+	}
+
+	slices.SortStableFunc(errs, func(a, b *Error) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+	return params, errs
+}
+
+func checkParams(n *NodeSpec, k *Kind) (Params, ErrorList) {
+	var (
+		errs   ErrorList
+		values = make(map[string]any)
+		known  = make(map[string]bool)
+	)
+
+	for _, p := range k.Schema.Params {
+		known[p.Name] = true
+
+		raw, ok := n.Params[p.Name]
+		if !ok || raw == nil {
+			switch {
+			case p.Required:
+				errs = append(errs, errorf(n.Line, "node %q: missing required param %q", n.ID, p.Name))
+			case p.Default != nil:
+				values[p.Name] = p.Default
+			}
+			continue
+		}
+
+		v, ok := convert(raw, p.Type)
+		if !ok {
+			errs = append(errs, errorf(n.paramLines[p.Name], "node %q: param %s: expected %s, got %s",
+				n.ID, p.Name, p.Type, describe(raw)))
+			continue
+		}
+		values[p.Name] = v
+	}
+
+	for name := range n.Params {
+		if !known[name] {
+			errs = append(errs, errorf(n.paramLines[name], "node %q: unknown param %q for kind %s", n.ID, name, k.Name))
+		}
+	}
+	return Params{values: values}, errs
+}
+
+func describe(v any) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "a mapping"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// findCycle returns a cycle of node ids and the edge closing it, if the edges have one.
+func findCycle(specs []NodeSpec, next map[string][]EdgeSpec) (EdgeSpec, []string) {
+	const (
+		unvisited = iota
+		active
+		finished
+	)
+	var (
+		state = make(map[string]int)
+		path  []string
+		visit func(id string) (EdgeSpec, []string)
+	)
+	visit = func(id string) (EdgeSpec, []string) {
+		state[id] = active
+		path = append(path, id)
+		for _, e := range next[id] {
+			switch state[e.To] {
+			case active:
+				start := slices.Index(path, e.To)
+				return e, append(slices.Clone(path[start:]), e.To)
+			case unvisited:
+				if e, cycle := visit(e.To); cycle != nil {
+					return e, cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = finished
+		return EdgeSpec{}, nil
+	}
+
+	for _, n := range specs {
+		if state[n.ID] == unvisited {
+			if e, cycle := visit(n.ID); cycle != nil {
+				return e, cycle
+			}
+		}
+	}
+	return EdgeSpec{}, nil
+}
@@ -0,0 +1,110 @@
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// parseJSON reads a JSON document into a value tree, keeping the line of every node.
+func parseJSON(data []byte) (*value, error) {
+	r := &jsonReader{dec: json.NewDecoder(bytes.NewReader(data))}
+	r.dec.UseNumber()
+	for i, c := range data {
+		if c == '\n' {
+			r.newlines = append(r.newlines, i)
+		}
+	}
+
+	v, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.dec.Token(); err != io.EOF {
+		return nil, errorf(r.line(r.dec.InputOffset()), "unexpected data after the document")
+	}
+	return v, nil
+}
+
+type jsonReader struct {
+	dec      *json.Decoder
+	newlines []int
+}
+
+// line returns the line of the byte before offset, i.e. of the last token read.
+func (r *jsonReader) line(offset int64) int {
+	return sort.SearchInts(r.newlines, int(offset)-1) + 1
+}
+
+func (r *jsonReader) token() (json.Token, int, error) {
+	tok, err := r.dec.Token()
+	if err != nil {
+		var serr *json.SyntaxError
+		if errors.As(err, &serr) {
+			return nil, r.line(serr.Offset), errorf(r.line(serr.Offset), "%s", serr.Error())
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		line := r.line(r.dec.InputOffset())
+		return nil, line, errorf(line, "%s", err.Error())
+	}
+	return tok, r.line(r.dec.InputOffset()), nil
+}
+
+func (r *jsonReader) read() (*value, error) {
+	tok, line, err := r.token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			m := newMap(line)
+			for r.dec.More() {
+				key, kline, err := r.token()
+				if err != nil {
+					return nil, err
+				}
+				k := key.(string)
+				if _, dup := m.fields[k]; dup {
+					return nil, errorf(kline, "duplicate key %q", k)
+				}
+				v, err := r.read()
+				if err != nil {
+					return nil, err
+				}
+				m.set(k, v)
+			}
+			_, _, err := r.token()
+			return m, err
+		case '[':
+			s := &value{kind: listValue, line: line}
+			for r.dec.More() {
+				item, err := r.read()
+				if err != nil {
+					return nil, err
+				}
+				s.items = append(s.items, item)
+			}
+			_, _, err := r.token()
+			return s, err
+		}
+		return nil, errorf(line, "unexpected %v", tok)
+	case string:
+		return &value{kind: scalarValue, line: line, str: tok}, nil
+	case json.Number:
+		return &value{kind: scalarValue, line: line, str: tok.String()}, nil
+	case bool:
+		if tok {
+			return &value{kind: scalarValue, line: line, str: "true"}, nil
+		}
+		return &value{kind: scalarValue, line: line, str: "false"}, nil
+	default:
+		return &value{kind: nullValue, line: line}, nil
+	}
+}
@@ -0,0 +1,352 @@
+package spec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// ParamType is the type of a node parameter.
+type ParamType int
+
+const (
+	String ParamType = iota
+	Int
+	Float
+	Bool
+	Duration
+	StringList
+)
+
+func (t ParamType) String() string {
+	switch t {
+	case String:
+		return "string"
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Bool:
+		return "bool"
+	case Duration:
+		return "duration"
+	case StringList:
+		return "list of strings"
+	default:
+		return "unknown"
+	}
+}
+
+// Param describes one parameter of a node kind. Default is used for a missing optional
+// param and must have the Go type of the param: string, int, float64, bool, time.Duration
+// or []string.
+type Param struct {
+	Name     string
+	Type     ParamType
+	Required bool
+	Default  any
+	Doc      string
+}
+
+// Schema documents a node kind and lists its parameters. SingleOutput marks kinds whose nodes
+// support only one output, such as nodes.NewWorkerPool, whose Output replaces the previous
+// channel; Check rejects a second edge from them.
+type Schema struct {
+	Doc          string
+	Params       []Param
+	SingleOutput bool
+}
+
+// Kind is a registered node kind. In is nil for kinds without an input (generators)
+// and Out for kinds without an output (sinks).
+type Kind struct {
+	Name   string
+	Schema Schema
+	In     reflect.Type
+	Out    reflect.Type
+
+	build func(Params, nodes.Config) (port, error)
+}
+
+// Registry holds node kinds by name. Kinds are meant to be registered during
+// initialization; a Registry is not safe for concurrent registration.
+type Registry struct {
+	kinds map[string]*Kind
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{kinds: make(map[string]*Kind)}
+}
+
+// Kinds returns the registered kinds sorted by name.
+func (r *Registry) Kinds() []Kind {
+	kinds := make([]Kind, 0, len(r.kinds))
+	for _, k := range r.kinds {
+		kinds = append(kinds, *k)
+	}
+	slices.SortFunc(kinds, func(a, b Kind) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return kinds
+}
+
+// Kind returns the kind registered under name.
+func (r *Registry) Kind(name string) (Kind, bool) {
+	k, ok := r.kinds[name]
+	if !ok {
+		return Kind{}, false
+	}
+	return *k, true
+}
+
+func (r *Registry) register(k *Kind) {
+	if _, dup := r.kinds[k.Name]; dup {
+		panic(fmt.Sprintf("spec: kind %q registered twice", k.Name))
+	}
+	r.kinds[k.Name] = k
+}
+
+// RegisterGenerator registers a kind of nodes without inputs that emit Out items,
+// such as nodes.NewGenerator. It panics if name is already registered.
+func RegisterGenerator[Out any](
+	r *Registry,
+	name string,
+	schema Schema,
+	factory func(Params, nodes.Config) (pipelines.Node[any, Out], error),
+) {
+	r.register(&Kind{
+		Name:   name,
+		Schema: schema,
+		Out:    reflect.TypeFor[Out](),
+		build:  buildPort(factory),
+	})
+}
+
+// RegisterProcessor registers a kind of nodes that turn In items into Out items.
+// It panics if name is already registered.
+func RegisterProcessor[In, Out any](
+	r *Registry,
+	name string,
+	schema Schema,
+	factory func(Params, nodes.Config) (pipelines.Node[In, Out], error),
+) {
+	r.register(&Kind{
+		Name:   name,
+		Schema: schema,
+		In:     reflect.TypeFor[In](),
+		Out:    reflect.TypeFor[Out](),
+		build:  buildPort(factory),
+	})
+}
+
+// RegisterSink registers a kind of nodes without outputs that consume In items,
+// such as nodes.NewResultAggregator. It panics if name is already registered.
+func RegisterSink[In any](
+	r *Registry,
+	name string,
+	schema Schema,
+	factory func(Params, nodes.Config) (pipelines.Node[In, any], error),
+) {
+	r.register(&Kind{
+		Name:   name,
+		Schema: schema,
+		In:     reflect.TypeFor[In](),
+		build:  buildPort(factory),
+	})
+}
+
+func buildPort[In, Out any](factory func(Params, nodes.Config) (pipelines.Node[In, Out], error)) func(Params, nodes.Config) (port, error) {
+	return func(p Params, cfg nodes.Config) (port, error) {
+		n, err := factory(p, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return typedPort[In, Out]{n}, nil
+	}
+}
+
+// TypeName returns the name of an item type as written in Go source, e.g. "any"
+// instead of "interface {}".
+func TypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 && t.Name() == "" {
+		return "any"
+	}
+	return t.String()
+}
+
+// assignable reports whether items of type out can be sent to an input of type in.
+func assignable(out, in reflect.Type) bool {
+	return out == in || in.Kind() == reflect.Interface && out.Implements(in)
+}
+
+// port erases the item types of a node so that the loader can connect it.
+type port interface {
+	pipelines.Runnable
+
+	// output returns a new output channel (a chan Out).
+	output() (any, error)
+	// setInput sets channels returned by output of other ports as inputs. It returns
+	// converters that must run with the node for inputs of an assignable type.
+	setInput(chans []any) ([]pipelines.Runnable, error)
+	node() pipelines.Runnable
+}
+
+type typedPort[In, Out any] struct {
+	pipelines.Node[In, Out]
+}
+
+func (p typedPort[In, Out]) output() (any, error) {
+	return p.Output()
+}
+
+func (p typedPort[In, Out]) setInput(chans []any) ([]pipelines.Runnable, error) {
+	var (
+		ins        = make([]<-chan In, len(chans))
+		converters []pipelines.Runnable
+	)
+	for i, ch := range chans {
+		if in, ok := ch.(chan In); ok {
+			ins[i] = in
+			continue
+		}
+		c := &converter[In]{src: reflect.ValueOf(ch), out: make(chan In)}
+		ins[i] = c.out
+		converters = append(converters, c)
+	}
+	return converters, p.SetInput(ins...)
+}
+
+func (p typedPort[In, Out]) node() pipelines.Runnable {
+	return p.Node
+}
+
+// converter forwards items of another type to an interface-typed input.
+type converter[In any] struct {
+	src reflect.Value
+	out chan In
+}
+
+func (c *converter[In]) Run(ctx context.Context) error {
+	defer close(c.out)
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: c.src},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+	for {
+		chosen, v, open := reflect.Select(cases)
+		if chosen == 1 {
+			return ctx.Err()
+		}
+		if !open {
+			return nil
+		}
+
+		item, _ := v.Interface().(In)
+		select {
+		case c.out <- item:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Params are the parameters of a node, converted to the types of its schema.
+// Missing optional params hold their default.
+type Params struct {
+	values map[string]any
+}
+
+// Has reports whether the param is set, either in the definition or by a default.
+func (p Params) Has(name string) bool {
+	_, ok := p.values[name]
+	return ok
+}
+
+func (p Params) String(name string) string {
+	v, _ := p.values[name].(string)
+	return v
+}
+
+func (p Params) Int(name string) int {
+	v, _ := p.values[name].(int)
+	return v
+}
+
+func (p Params) Float(name string) float64 {
+	v, _ := p.values[name].(float64)
+	return v
+}
+
+func (p Params) Bool(name string) bool {
+	v, _ := p.values[name].(bool)
+	return v
+}
+
+func (p Params) Duration(name string) time.Duration {
+	v, _ := p.values[name].(time.Duration)
+	return v
+}
+
+func (p Params) Strings(name string) []string {
+	v, _ := p.values[name].([]string)
+	return v
+}
+
+// convert converts a param value as written in a definition to typ.
+func convert(v any, typ ParamType) (any, bool) {
+	if typ == StringList {
+		switch v := v.(type) {
+		case string:
+			return []string{v}, true
+		case []any:
+			list := make([]string, len(v))
+			for i, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return nil, false
+				}
+				list[i] = s
+			}
+			return list, true
+		}
+		return nil, false
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, false
+	}
+	var err error
+	switch typ {
+	case String:
+		return s, true
+	case Int:
+		var n int
+		n, err = strconv.Atoi(s)
+		v = n
+	case Float:
+		var f float64
+		f, err = strconv.ParseFloat(s, 64)
+		v = f
+	case Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v = b
+	case Duration:
+		var d time.Duration
+		d, err = time.ParseDuration(s)
+		v = d
+	default:
+		return nil, false
+	}
+	return v, err == nil
+}
@@ -0,0 +1,308 @@
+// Package spec builds pipelines from declarative definitions, so that they can be rewired
+// without recompiling. Go code registers node kinds in a Registry: a factory together with
+// its parameter schema and input/output types. A definition written in YAML or JSON lists
+// the nodes with their kind, params and config, and the edges between them:
+//
+//	name: hashing
+//	nodes:
+//	  - id: files
+//	    kind: walk
+//	    params: {root: ./data}
+//	  - id: hash
+//	    kind: sha256
+//	    config: {workers: 8, timeout: 30s}
+//	  - id: out
+//	    kind: jsonl
+//	    params: {path: hashes.jsonl}
+//	edges:
+//	  - files -> hash
+//	  - from: hash
+//	    to: out
+//
+// Registry.Check validates a definition without building anything: kinds and params against
+// their schemas, and that connected ports exist and have compatible types. Every problem is
+// reported with the line it was found on. Registry.Build constructs and connects the nodes.
+package spec
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// Spec is a parsed pipeline definition.
+type Spec struct {
+	Name  string
+	Nodes []NodeSpec
+	Edges []EdgeSpec
+
+	// File is the name the definition was parsed from; it prefixes error messages.
+	File string
+}
+
+// NodeSpec is one node of a definition. Params hold strings, []any and map[string]any
+// values as written; they are converted by the schema of the node kind.
+type NodeSpec struct {
+	ID     string
+	Kind   string
+	Params map[string]any
+	Config nodes.Config
+	Line   int
+
+	paramLines map[string]int
+}
+
+// EdgeSpec connects the output of node From to the input of node To.
+// Inputs of a node are set in the order of its edges.
+type EdgeSpec struct {
+	From string
+	To   string
+	Line int
+}
+
+// ParseFile reads a definition from path. Files with a .json extension are read as JSON,
+// everything else as YAML.
+func ParseFile(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse reads a definition named name from data. JSON is recognized by a .json extension
+// of name or a leading '{'; anything else is read as YAML.
+func Parse(name string, data []byte) (*Spec, error) {
+	var (
+		root *value
+		err  error
+	)
+	if strings.EqualFold(filepath.Ext(name), ".json") || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		root, err = parseJSON(data)
+	} else {
+		root, err = parseYAML(data)
+	}
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			return nil, ErrorList{e}.withFile(name)
+		}
+		return nil, err
+	}
+
+	s, errs := decodeSpec(root)
+	if len(errs) > 0 {
+		return nil, errs.withFile(name)
+	}
+	s.File = name
+	return s, nil
+}
+
+func decodeSpec(root *value) (*Spec, ErrorList) {
+	var (
+		s    = &Spec{}
+		errs ErrorList
+	)
+	if root.kind != mapValue {
+		return nil, ErrorList{errorf(root.line, "expected a mapping with nodes and edges, got a %s", root.kind)}
+	}
+
+	for _, key := range root.keys {
+		v := root.fields[key]
+		switch key {
+		case "name":
+			if v.kind != scalarValue {
+				errs = append(errs, errorf(v.line, "name must be a string"))
+				continue
+			}
+			s.Name = v.str
+		case "nodes":
+			if v.kind != listValue {
+				errs = append(errs, errorf(v.line, "nodes must be a list"))
+				continue
+			}
+			for _, item := range v.items {
+				n, nerrs := decodeNode(item)
+				errs = append(errs, nerrs...)
+				s.Nodes = append(s.Nodes, n)
+			}
+		case "edges":
+			if v.kind == nullValue {
+				continue
+			}
+			if v.kind != listValue {
+				errs = append(errs, errorf(v.line, "edges must be a list"))
+				continue
+			}
+			for _, item := range v.items {
+				e, err := decodeEdge(item)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				s.Edges = append(s.Edges, e)
+			}
+		default:
+			errs = append(errs, errorf(v.line, "unknown field %q", key))
+		}
+	}
+	return s, errs
+}
+
+func decodeNode(v *value) (NodeSpec, ErrorList) {
+	n := NodeSpec{
+		Line:       v.line,
+		Params:     make(map[string]any),
+		Config:     nodes.DefaultConfig(),
+		paramLines: make(map[string]int),
+	}
+	if v.kind != mapValue {
+		return n, ErrorList{errorf(v.line, "a node must be a mapping with id and kind")}
+	}
+
+	var errs ErrorList
+	for _, key := range v.keys {
+		f := v.fields[key]
+		switch key {
+		case "id", "kind":
+			if f.kind != scalarValue || f.str == "" {
+				errs = append(errs, errorf(f.line, "%s must be a non-empty string", key))
+				continue
+			}
+			if key == "id" {
+				n.ID = f.str
+			} else {
+				n.Kind = f.str
+			}
+		case "params":
+			if f.kind == nullValue {
+				continue
+			}
+			if f.kind != mapValue {
+				errs = append(errs, errorf(f.line, "params must be a mapping"))
+				continue
+			}
+			for _, name := range f.keys {
+				n.Params[name] = f.fields[name].plain()
+				n.paramLines[name] = f.fields[name].line
+			}
+		case "config":
+			if f.kind == nullValue {
+				continue
+			}
+			if f.kind != mapValue {
+				errs = append(errs, errorf(f.line, "config must be a mapping"))
+				continue
+			}
+			for _, name := range f.keys {
+				if err := setConfig(&n.Config, name, f.fields[name]); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		default:
+			errs = append(errs, errorf(f.line, "unknown node field %q", key))
+		}
+	}
+
+	if n.ID == "" {
+		errs = append(errs, errorf(v.line, "node has no id"))
+	}
+	if n.Kind == "" {
+		errs = append(errs, errorf(v.line, "node %q has no kind", n.ID))
+	}
+	return n, errs
+}
+
+// configFields maps config keys of a definition to nodes.Config fields.
+var configFields = map[string]func(c *nodes.Config, s string) error{
+	"in_buffer":      intField(func(c *nodes.Config) *int { return &c.InBuffer }),
+	"buffer":         intField(func(c *nodes.Config) *int { return &c.Buffer }),
+	"workers":        intField(func(c *nodes.Config) *int { return &c.Workers }),
+	"min_workers":    intField(func(c *nodes.Config) *int { return &c.MinWorkers }),
+	"max_workers":    intField(func(c *nodes.Config) *int { return &c.MaxWorkers }),
+	"scale_cooldown": durationField(func(c *nodes.Config) *time.Duration { return &c.ScaleCooldown }),
+	"scale_latency":  durationField(func(c *nodes.Config) *time.Duration { return &c.ScaleLatency }),
+	"timeout":        durationField(func(c *nodes.Config) *time.Duration { return &c.Timeout }),
+	"ordered":        boolField(func(c *nodes.Config) *bool { return &c.Ordered }),
+	"repanic":        boolField(func(c *nodes.Config) *bool { return &c.RePanic }),
+}
+
+func intField(field func(*nodes.Config) *int) func(*nodes.Config, string) error {
+	return func(c *nodes.Config, s string) error {
+		v, err := strconv.Atoi(s)
+		if err == nil && v < 0 {
+			return strconv.ErrRange
+		}
+		*field(c) = v
+		return err
+	}
+}
+
+func boolField(field func(*nodes.Config) *bool) func(*nodes.Config, string) error {
+	return func(c *nodes.Config, s string) error {
+		v, err := strconv.ParseBool(s)
+		*field(c) = v
+		return err
+	}
+}
+
+func durationField(field func(*nodes.Config) *time.Duration) func(*nodes.Config, string) error {
+	return func(c *nodes.Config, s string) error {
+		v, err := time.ParseDuration(s)
+		*field(c) = v
+		return err
+	}
+}
+
+func setConfig(c *nodes.Config, name string, v *value) *Error {
+	set, ok := configFields[name]
+	if !ok {
+		return errorf(v.line, "unknown config field %q", name)
+	}
+	if v.kind != scalarValue {
+		return errorf(v.line, "config %s must be a scalar", name)
+	}
+	if err := set(c, v.str); err != nil {
+		return errorf(v.line, "invalid config %s %q", name, v.str)
+	}
+	return nil
+}
+
+func decodeEdge(v *value) (EdgeSpec, *Error) {
+	e := EdgeSpec{Line: v.line}
+
+	switch v.kind {
+	case scalarValue:
+		from, to, ok := strings.Cut(v.str, "->")
+		e.From, e.To = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || e.From == "" || e.To == "" {
+			return e, errorf(v.line, "expected \"from -> to\", got %q", v.str)
+		}
+		return e, nil
+	case mapValue:
+		for _, key := range v.keys {
+			f := v.fields[key]
+			if f.kind != scalarValue {
+				return e, errorf(f.line, "%s must be a node id", key)
+			}
+			switch key {
+			case "from":
+				e.From = f.str
+			case "to":
+				e.To = f.str
+			default:
+				return e, errorf(f.line, "unknown edge field %q", key)
+			}
+		}
+		if e.From == "" || e.To == "" {
+			return e, errorf(v.line, "an edge needs both from and to")
+		}
+		return e, nil
+	default:
+		return e, errorf(v.line, "an edge must be \"from -> to\" or a mapping with from and to")
+	}
+}
@@ -0,0 +1,316 @@
+package spec_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/sinks"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+	"github.com/Sergey-Polishchenko/pipelines/spec"
+)
+
+// registry регистрирует простые виды узлов; collect получает всё, что дошло до приёмника
+func registry(collect *sinks.Collector[any]) *spec.Registry {
+	r := spec.NewRegistry()
+
+	spec.RegisterGenerator(r, "numbers", spec.Schema{
+		Params: []spec.Param{{Name: "count", Type: spec.Int, Required: true}},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[any, int], error) {
+		items := make([]int, p.Int("count"))
+		for i := range items {
+			items[i] = i + 1
+		}
+		return nodes.NewGenerator(sources.Slice(items), cfg), nil
+	})
+
+	spec.RegisterProcessor(r, "multiply", spec.Schema{
+		Params:       []spec.Param{{Name: "by", Type: spec.Int, Default: 2}},
+		SingleOutput: true,
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[int, int], error) {
+		by := p.Int("by")
+		return nodes.NewWorkerPool(func(v int) (int, error) { return v * by, nil }, cfg), nil
+	})
+
+	spec.RegisterProcessor(r, "format", spec.Schema{
+		Params: []spec.Param{{Name: "template", Type: spec.String, Default: "%d"}},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[int, string], error) {
+		tmpl := p.String("template")
+		return nodes.NewNode(func(v int) (string, error) { return fmt.Sprintf(tmpl, v), nil }, cfg), nil
+	})
+
+	spec.RegisterSink(r, "collect", spec.Schema{}, func(_ spec.Params, cfg nodes.Config) (pipelines.Node[any, any], error) {
+		return nodes.NewWriterAggregator[any](collect, cfg), nil
+	})
+	return r
+}
+
+func run(t *testing.T, r *spec.Registry, name, def string) {
+	t.Helper()
+
+	s, err := spec.Parse(name, []byte(def))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	g, err := r.Build(s)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := g.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func sorted(items []any) []string {
+	var out []string
+	for _, v := range items {
+		out = append(out, fmt.Sprint(v))
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestBuildYAML(t *testing.T) {
+	collect := sinks.Collect[any]()
+	r := registry(collect)
+
+	// Выход format (string) подключается ко входу collect (any) через преобразователь
+	run(t, r, "pipeline.yaml", `
+name: demo
+nodes:
+  - id: src
+    kind: numbers
+    params:
+      count: 3            # три числа
+  - id: triple
+    kind: multiply
+    params: {by: 3}
+    config:
+      workers: 2
+      timeout: 1s
+  - id: fmt
+    kind: format
+    params:
+      template: "n=%d"
+  - id: out
+    kind: collect
+edges:
+  - src -> triple
+  - from: triple
+    to: fmt
+  - fmt -> out
+`)
+
+	got := sorted(collect.Items())
+	want := []string{"n=3", "n=6", "n=9"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestBuildJSON(t *testing.T) {
+	collect := sinks.Collect[any]()
+	r := registry(collect)
+
+	run(t, r, "pipeline.json", `{
+  "nodes": [
+    {"id": "src", "kind": "numbers", "params": {"count": 2}},
+    {"id": "double", "kind": "multiply"},
+    {"id": "out", "kind": "collect"}
+  ],
+  "edges": ["src -> double", {"from": "double", "to": "out"}]
+}`)
+
+	got := sorted(collect.Items())
+	want := []string{"2", "4"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckReportsLines(t *testing.T) {
+	r := registry(sinks.Collect[any]())
+
+	s, err := spec.Parse("bad.yaml", []byte(`nodes:
+  - id: src
+    kind: numbers
+    params:
+      count: many
+  - id: fmt
+    kind: format
+    params:
+      colour: red
+  - id: twice
+    kind: multiply
+  - id: ghost
+    kind: teleport
+edges:
+  - src -> fmt
+  - fmt -> twice
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	err = r.Check(s)
+	var list spec.ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+
+	// Каждая ошибка должна указывать на свою строку
+	want := map[int]string{
+		5:  "param count: expected int, got \"many\"",
+		9:  `unknown param "colour"`,
+		10: `output of node "twice" is not connected`,
+		12: `unknown kind "teleport"`,
+		15: "fmt emits string, but twice accepts int",
+	}
+	if len(list) != len(want) {
+		t.Fatalf("expected %d errors, got:\n%v", len(want), err)
+	}
+	for _, e := range list {
+		if !strings.Contains(e.Msg, want[e.Line]) {
+			t.Errorf("line %d: unexpected error %q", e.Line, e.Msg)
+		}
+		if e.File != "bad.yaml" {
+			t.Errorf("line %d: file = %q", e.Line, e.File)
+		}
+	}
+}
+
+// trackedWriter считает вызовы Close, как файл, открытый фабрикой при сборке
+type trackedWriter struct {
+	closed int
+}
+
+func (w *trackedWriter) Write(context.Context, any) error { return nil }
+
+func (w *trackedWriter) Close() error {
+	w.closed++
+	return nil
+}
+
+func TestBuildClosesNodesOnError(t *testing.T) {
+	r := registry(sinks.Collect[any]())
+	w := &trackedWriter{}
+	spec.RegisterSink(r, "tracked", spec.Schema{}, func(_ spec.Params, cfg nodes.Config) (pipelines.Node[any, any], error) {
+		return nodes.NewWriterAggregator[any](w, cfg), nil
+	})
+	errBroken := errors.New("cannot open source")
+	spec.RegisterGenerator(r, "broken", spec.Schema{}, func(spec.Params, nodes.Config) (pipelines.Node[any, int], error) {
+		return nil, errBroken
+	})
+
+	// Приёмник собран раньше сломанного источника и должен быть закрыт
+	s, err := spec.Parse("pipeline.yaml", []byte(`
+nodes:
+  - id: out
+    kind: tracked
+  - id: src
+    kind: broken
+edges:
+  - src -> out
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var list spec.ErrorList
+	if _, err := r.Build(s); !errors.As(err, &list) || !strings.Contains(err.Error(), errBroken.Error()) {
+		t.Fatalf("expected an ErrorList with %v, got %v", errBroken, err)
+	}
+	if w.closed != 1 {
+		t.Fatalf("writer closed %d times, want 1", w.closed)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		line int
+	}{
+		{"bad-indent.yaml", "nodes:\n  - id: a\n     kind: b\n", 3},
+		{"tabs.yaml", "nodes:\n\t- id: a\n", 2},
+		{"unknown-field.yaml", "nodes: []\nwires: []\n", 2},
+		{"bad-config.yaml", "nodes:\n  - id: a\n    kind: b\n    config:\n      workers: lots\n", 5},
+		{"broken.json", "{\n  \"nodes\": [\n    {\"id\": \"a\",}\n  ]\n}", 3},
+	}
+
+	for _, tt := range tests {
+		_, err := spec.Parse(tt.name, []byte(tt.def))
+		var list spec.ErrorList
+		if !errors.As(err, &list) || len(list) == 0 {
+			t.Fatalf("%s: expected ErrorList, got %v", tt.name, err)
+		}
+		if list[0].Line != tt.line {
+			t.Errorf("%s: error on line %d, want %d: %v", tt.name, list[0].Line, tt.line, err)
+		}
+	}
+}
+
+func TestCycle(t *testing.T) {
+	r := registry(sinks.Collect[any]())
+
+	s, err := spec.Parse("cycle.yaml", []byte(`nodes:
+  - {id: a, kind: multiply}
+  - {id: b, kind: multiply}
+edges: [a -> b, b -> a]
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := r.Check(s); err == nil || !strings.Contains(err.Error(), "cycle: a -> b -> a") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func TestSingleOutput(t *testing.T) {
+	r := registry(sinks.Collect[any]())
+
+	// Пул воркеров отдаёт только один выход, второе ребро из него — ошибка на своей строке
+	s, err := spec.Parse("fanout.yaml", []byte(`nodes:
+  - {id: src, kind: numbers, params: {count: 1}}
+  - {id: double, kind: multiply}
+  - {id: a, kind: collect}
+  - {id: b, kind: collect}
+edges:
+  - src -> double
+  - double -> a
+  - double -> b
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var list spec.ErrorList
+	if err := r.Check(s); !errors.As(err, &list) || len(list) != 1 {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	if e := list[0]; e.Line != 9 || !strings.Contains(e.Msg, "supports only one output, connected on line 8") {
+		t.Errorf("unexpected error on line %d: %s", e.Line, e.Msg)
+	}
+}
+
+func TestConfigFields(t *testing.T) {
+	s, err := spec.Parse("config.yaml", []byte(`nodes:
+  - id: pool
+    kind: multiply
+    config: {workers: 3, ordered: true, repanic: true, scale_cooldown: 2s}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	cfg := s.Nodes[0].Config
+	if cfg.Workers != 3 || !cfg.Ordered || !cfg.RePanic || cfg.ScaleCooldown != 2*time.Second {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
@@ -0,0 +1,199 @@
+// Package std registers the standard node kinds of the library in a spec.Registry:
+// the generators of package sources, the writers of package sinks and a few generic
+// processors. Paths given as "-" mean standard input or output.
+//
+//	Generators: read-lines, read-csv, read-jsonl, walk, ticker
+//	Processors: format, dedup
+//	Sinks:      stdout, write-text, write-jsonl, write-csv, discard
+package std
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/sinks"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+	"github.com/Sergey-Polishchenko/pipelines/spec"
+)
+
+// Registry returns a new registry with the standard kinds. More kinds can be added to it.
+func Registry() *spec.Registry {
+	r := spec.NewRegistry()
+	Register(r)
+	return r
+}
+
+// Register adds the standard kinds to r.
+func Register(r *spec.Registry) {
+	registerSources(r)
+	registerProcessors(r)
+	registerSinks(r)
+}
+
+var pathParam = spec.Param{Name: "path", Type: spec.String, Default: "-", Doc: `file path, "-" for standard input or output`}
+
+func registerSources(r *spec.Registry) {
+	spec.RegisterGenerator(r, "read-lines", spec.Schema{
+		Doc:    "emits the lines of a file",
+		Params: []spec.Param{pathParam},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[any, string], error) {
+		if p.String("path") == "-" {
+			return nodes.NewGenerator(sources.Stdin(), cfg), nil
+		}
+		return nodes.NewGenerator(sources.LinesFile(p.String("path")), cfg), nil
+	})
+
+	spec.RegisterGenerator(r, "read-csv", spec.Schema{
+		Doc:    "emits the records of a CSV file",
+		Params: []spec.Param{pathParam},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[any, []string], error) {
+		if p.String("path") == "-" {
+			return nodes.NewGenerator(sources.CSV(os.Stdin), cfg), nil
+		}
+		return nodes.NewGenerator(sources.CSVFile(p.String("path")), cfg), nil
+	})
+
+	spec.RegisterGenerator(r, "read-jsonl", spec.Schema{
+		Doc:    "emits the objects of a JSON Lines file",
+		Params: []spec.Param{pathParam},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[any, map[string]any], error) {
+		if p.String("path") == "-" {
+			return nodes.NewGenerator(sources.JSONLines[map[string]any](os.Stdin), cfg), nil
+		}
+		return nodes.NewGenerator(sources.JSONLinesFile[map[string]any](p.String("path")), cfg), nil
+	})
+
+	spec.RegisterGenerator(r, "walk", spec.Schema{
+		Doc: "emits the paths of regular files under a directory",
+		Params: []spec.Param{
+			{Name: "root", Type: spec.String, Required: true},
+			{Name: "include", Type: spec.StringList, Doc: "glob patterns of files to keep"},
+			{Name: "exclude", Type: spec.StringList, Doc: "glob patterns of files and directories to skip"},
+			{Name: "skip_errors", Type: spec.Bool, Doc: "skip unreadable entries"},
+		},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[any, string], error) {
+		opts := sources.WalkOptions{
+			Include:    p.Strings("include"),
+			Exclude:    p.Strings("exclude"),
+			SkipErrors: p.Bool("skip_errors"),
+		}
+		return nodes.NewGenerator(sources.Walk(p.String("root"), opts), cfg), nil
+	})
+
+	spec.RegisterGenerator(r, "ticker", spec.Schema{
+		Doc: "emits the current time periodically",
+		Params: []spec.Param{
+			{Name: "interval", Type: spec.Duration, Required: true},
+			{Name: "limit", Type: spec.Int, Doc: "number of ticks, 0 for no limit"},
+		},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[any, time.Time], error) {
+		if p.Duration("interval") <= 0 {
+			return nil, fmt.Errorf("interval must be positive")
+		}
+		return nodes.NewGenerator(sources.Ticker(p.Duration("interval"), p.Int("limit")), cfg), nil
+	})
+}
+
+func registerProcessors(r *spec.Registry) {
+	spec.RegisterProcessor(r, "format", spec.Schema{
+		Doc:          "formats items with fmt.Sprintf",
+		Params:       []spec.Param{{Name: "template", Type: spec.String, Default: "%v"}},
+		SingleOutput: true,
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[any, string], error) {
+		tmpl := p.String("template")
+		return nodes.NewWorkerPool(func(v any) (string, error) {
+			return fmt.Sprintf(tmpl, v), nil
+		}, cfg), nil
+	})
+
+	spec.RegisterProcessor(r, "dedup", spec.Schema{
+		Doc: "drops repeated strings",
+		Params: []spec.Param{
+			{Name: "strategy", Type: spec.String, Default: "lru", Doc: "lru, ttl or bloom"},
+			{Name: "max_entries", Type: spec.Int},
+			{Name: "ttl", Type: spec.Duration},
+			{Name: "expected_items", Type: spec.Int},
+		},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[string, string], error) {
+		dcfg := nodes.DedupConfig{
+			MaxEntries:    p.Int("max_entries"),
+			TTL:           p.Duration("ttl"),
+			ExpectedItems: uint64(p.Int("expected_items")),
+		}
+		switch p.String("strategy") {
+		case "lru":
+			dcfg.Strategy = nodes.DedupLRU
+		case "ttl":
+			dcfg.Strategy = nodes.DedupTTL
+		case "bloom":
+			dcfg.Strategy = nodes.DedupBloom
+		default:
+			return nil, fmt.Errorf("unknown dedup strategy %q", p.String("strategy"))
+		}
+		return nodes.NewDedup(func(s string) string { return s }, dcfg, cfg), nil
+	})
+}
+
+func sprint(v any) string {
+	return fmt.Sprint(v)
+}
+
+func registerSinks(r *spec.Registry) {
+	spec.RegisterSink(r, "stdout", spec.Schema{
+		Doc: "prints items to standard output, one per line",
+	}, func(_ spec.Params, cfg nodes.Config) (pipelines.Node[any, any], error) {
+		return nodes.NewWriterAggregator[any](sinks.Text(os.Stdout, sprint), cfg), nil
+	})
+
+	spec.RegisterSink(r, "write-text", spec.Schema{
+		Doc:    "writes items as lines of text",
+		Params: []spec.Param{pathParam},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[any, any], error) {
+		if p.String("path") == "-" {
+			return nodes.NewWriterAggregator[any](sinks.Text(os.Stdout, sprint), cfg), nil
+		}
+		w, err := sinks.TextFile(p.String("path"), sprint)
+		if err != nil {
+			return nil, err
+		}
+		return nodes.NewWriterAggregator[any](w, cfg), nil
+	})
+
+	spec.RegisterSink(r, "write-jsonl", spec.Schema{
+		Doc:    "writes items as JSON Lines",
+		Params: []spec.Param{pathParam},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[any, any], error) {
+		if p.String("path") == "-" {
+			return nodes.NewWriterAggregator[any](sinks.JSONLines[any](os.Stdout), cfg), nil
+		}
+		w, err := sinks.JSONLinesFile[any](p.String("path"))
+		if err != nil {
+			return nil, err
+		}
+		return nodes.NewWriterAggregator[any](w, cfg), nil
+	})
+
+	record := func(r []string) ([]string, error) { return r, nil }
+	spec.RegisterSink(r, "write-csv", spec.Schema{
+		Doc:    "writes records as CSV",
+		Params: []spec.Param{pathParam},
+	}, func(p spec.Params, cfg nodes.Config) (pipelines.Node[[]string, any], error) {
+		if p.String("path") == "-" {
+			return nodes.NewWriterAggregator(sinks.CSV(os.Stdout, record), cfg), nil
+		}
+		w, err := sinks.CSVFile(p.String("path"), record)
+		if err != nil {
+			return nil, err
+		}
+		return nodes.NewWriterAggregator(w, cfg), nil
+	})
+
+	spec.RegisterSink(r, "discard", spec.Schema{
+		Doc: "consumes items and does nothing",
+	}, func(_ spec.Params, cfg nodes.Config) (pipelines.Node[any, any], error) {
+		return nodes.NewResultAggregator(func(any) error { return nil }, cfg), nil
+	})
+}
@@ -0,0 +1,67 @@
+package spec
+
+// valueKind is the kind of a parsed document node.
+type valueKind int
+
+const (
+	nullValue valueKind = iota
+	scalarValue
+	mapValue
+	listValue
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case scalarValue:
+		return "scalar"
+	case mapValue:
+		return "mapping"
+	case listValue:
+		return "list"
+	default:
+		return "null"
+	}
+}
+
+// value is a YAML or JSON document node together with the line it starts on.
+// Scalars keep their text; their type is decided by the schema that reads them.
+type value struct {
+	kind valueKind
+	line int
+
+	str    string
+	keys   []string
+	fields map[string]*value
+	items  []*value
+}
+
+func newMap(line int) *value {
+	return &value{kind: mapValue, line: line, fields: make(map[string]*value)}
+}
+
+func (v *value) set(key string, f *value) {
+	v.keys = append(v.keys, key)
+	v.fields[key] = f
+}
+
+// plain converts v into string, []any or map[string]any values.
+func (v *value) plain() any {
+	switch v.kind {
+	case scalarValue:
+		return v.str
+	case listValue:
+		items := make([]any, len(v.items))
+		for i, item := range v.items {
+			items[i] = item.plain()
+		}
+		return items
+	case mapValue:
+		m := make(map[string]any, len(v.keys))
+		for _, k := range v.keys {
+			m[k] = v.fields[k].plain()
+		}
+		return m
+	default:
+		return nil
+	}
+}
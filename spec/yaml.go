@@ -0,0 +1,420 @@
+package spec
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The YAML reader supports the subset used by pipeline definitions: block mappings and
+// sequences, plain, single- and double-quoted scalars, single-line flow sequences and
+// mappings, and comments. Anchors, tags, block scalars and multiple documents are rejected.
+
+type yamlLine struct {
+	no     int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+func parseYAML(data []byte) (*value, error) {
+	lines, err := yamlLines(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return newMap(1), nil
+	}
+
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.lines) {
+		return nil, errorf(p.lines[p.i].no, "unexpected indentation")
+	}
+	return v, nil
+}
+
+func yamlLines(data string) ([]yamlLine, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(data, "\n") {
+		no := i + 1
+		raw = strings.TrimSuffix(raw, "\r")
+
+		text := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(text)
+		if strings.HasPrefix(text, "\t") {
+			return nil, errorf(no, "tabs are not allowed for indentation")
+		}
+
+		text = stripComment(text)
+		switch {
+		case text == "":
+			continue
+		case text == "---" && len(lines) == 0:
+			continue
+		case text == "---" || text == "...":
+			return nil, errorf(no, "multiple documents are not supported")
+		case strings.HasPrefix(text, "%"):
+			return nil, errorf(no, "directives are not supported")
+		}
+		lines = append(lines, yamlLine{no: no, indent: indent, text: text})
+	}
+	return lines, nil
+}
+
+// stripComment removes a trailing comment outside of quotes and trailing spaces.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return strings.TrimRight(s[:i], " ")
+		}
+	}
+	return strings.TrimRight(s, " ")
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) block(indent int) (*value, error) {
+	if isSeqItem(p.lines[p.i].text) {
+		return p.seq(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) mapping(indent int) (*value, error) {
+	m := newMap(p.lines[p.i].no)
+
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		l := p.lines[p.i]
+		if isSeqItem(l.text) {
+			return nil, errorf(l.no, "unexpected list item in a mapping")
+		}
+		key, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, errorf(l.no, "expected \"key: value\", got %q", l.text)
+		}
+		if _, dup := m.fields[key]; dup {
+			return nil, errorf(l.no, "duplicate key %q", key)
+		}
+		p.i++
+
+		var (
+			v   *value
+			err error
+		)
+		switch {
+		case rest != "":
+			v, err = parseInline(rest, l.no)
+		case p.i < len(p.lines) && p.lines[p.i].indent > indent:
+			v, err = p.block(p.lines[p.i].indent)
+		case p.i < len(p.lines) && p.lines[p.i].indent == indent && isSeqItem(p.lines[p.i].text):
+			// a sequence may start at the indentation of its key
+			v, err = p.seq(indent)
+		default:
+			v = &value{kind: nullValue, line: l.no}
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.set(key, v)
+	}
+
+	if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+		return nil, errorf(p.lines[p.i].no, "unexpected indentation")
+	}
+	return m, nil
+}
+
+func (p *yamlParser) seq(indent int) (*value, error) {
+	s := &value{kind: listValue, line: p.lines[p.i].no}
+
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && isSeqItem(p.lines[p.i].text) {
+		l := p.lines[p.i]
+		rest := strings.TrimLeft(l.text[1:], " ")
+
+		var (
+			item *value
+			err  error
+		)
+		switch {
+		case rest == "":
+			p.i++
+			if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+				item, err = p.block(p.lines[p.i].indent)
+			} else {
+				item = &value{kind: nullValue, line: l.no}
+			}
+		case isSeqItem(rest) || isMappingEntry(rest):
+			// the item is a block starting at the column of rest; parse the rest of the line
+			// as if it were on a line of its own
+			col := indent + len(l.text) - len(rest)
+			p.lines[p.i] = yamlLine{no: l.no, indent: col, text: rest}
+			item, err = p.block(col)
+		default:
+			p.i++
+			item, err = parseInline(rest, l.no)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.items = append(s.items, item)
+	}
+	return s, nil
+}
+
+func isMappingEntry(text string) bool {
+	if text[0] == '[' || text[0] == '{' {
+		return false
+	}
+	_, _, ok := splitKey(text)
+	return ok
+}
+
+// splitKey splits "key: value" into its parts. The key may be quoted.
+func splitKey(text string) (key, rest string, ok bool) {
+	end := -1
+	if text[0] == '"' || text[0] == '\'' {
+		q := closingQuote(text)
+		if q < 0 || q+1 >= len(text) || text[q+1] != ':' {
+			return "", "", false
+		}
+		k, err := unquote(text[:q+1])
+		if err != nil {
+			return "", "", false
+		}
+		key, end = k, q+1
+	} else {
+		end = strings.Index(text, ": ")
+		if end < 0 {
+			if !strings.HasSuffix(text, ":") {
+				return "", "", false
+			}
+			end = len(text) - 1
+		}
+		key = text[:end]
+	}
+
+	rest = text[end+1:]
+	if rest != "" && rest[0] != ' ' {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(rest), true
+}
+
+// closingQuote returns the index of the quote closing the scalar that starts text, or -1.
+func closingQuote(text string) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case text[i] == q && q == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return strconv.Unquote(s)
+}
+
+func parseInline(text string, line int) (*value, error) {
+	switch text[0] {
+	case '[', '{':
+		return parseFlow(text, line)
+	case '&', '*', '!':
+		return nil, errorf(line, "anchors, aliases and tags are not supported")
+	case '|', '>':
+		return nil, errorf(line, "block scalars are not supported")
+	}
+	return parseScalar(text, line)
+}
+
+func parseScalar(text string, line int) (*value, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		if q := closingQuote(text); q != len(text)-1 {
+			return nil, errorf(line, "malformed quoted string %s", text)
+		}
+		s, err := unquote(text)
+		if err != nil {
+			return nil, errorf(line, "malformed quoted string %s", text)
+		}
+		return &value{kind: scalarValue, line: line, str: s}, nil
+	}
+
+	if text == "~" || text == "null" {
+		return &value{kind: nullValue, line: line}, nil
+	}
+	return &value{kind: scalarValue, line: line, str: text}, nil
+}
+
+// parseFlow parses a flow sequence or mapping that ends on the same line.
+func parseFlow(text string, line int) (*value, error) {
+	p := &flowParser{text: text, line: line}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.text) {
+		return nil, errorf(line, "unexpected %q after flow collection", p.text[p.pos:])
+	}
+	return v, nil
+}
+
+type flowParser struct {
+	text string
+	pos  int
+	line int
+}
+
+func (p *flowParser) skipSpaces() {
+	for p.pos < len(p.text) && p.text[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *flowParser) unterminated() error {
+	return errorf(p.line, "flow collections must end on the same line")
+}
+
+func (p *flowParser) value() (*value, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.text) {
+		return nil, p.unterminated()
+	}
+
+	switch p.text[p.pos] {
+	case '[':
+		return p.collection(']')
+	case '{':
+		return p.collection('}')
+	}
+	s, err := p.scalar(",]}")
+	if err != nil {
+		return nil, err
+	}
+	return parseScalar(s, p.line)
+}
+
+// scalar returns the text of a quoted scalar, or of a plain scalar up to one of stops.
+func (p *flowParser) scalar(stops string) (string, error) {
+	rest := p.text[p.pos:]
+	if rest[0] == '"' || rest[0] == '\'' {
+		q := closingQuote(rest)
+		if q < 0 {
+			return "", errorf(p.line, "malformed quoted string %s", rest)
+		}
+		p.pos += q + 1
+		return rest[:q+1], nil
+	}
+
+	end := strings.IndexAny(rest, stops)
+	if end < 0 {
+		return "", p.unterminated()
+	}
+	p.pos += end
+	s := strings.TrimSpace(rest[:end])
+	if s == "" {
+		return "", errorf(p.line, "empty item in flow collection")
+	}
+	return s, nil
+}
+
+func (p *flowParser) collection(closing byte) (*value, error) {
+	p.pos++
+	var v *value
+	if closing == ']' {
+		v = &value{kind: listValue, line: p.line}
+	} else {
+		v = newMap(p.line)
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.text) && p.text[p.pos] == closing {
+		p.pos++
+		return v, nil
+	}
+
+	for {
+		if closing == ']' {
+			item, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			v.items = append(v.items, item)
+		} else if err := p.entry(v); err != nil {
+			return nil, err
+		}
+
+		p.skipSpaces()
+		if p.pos >= len(p.text) {
+			return nil, p.unterminated()
+		}
+		switch p.text[p.pos] {
+		case ',':
+			p.pos++
+		case closing:
+			p.pos++
+			return v, nil
+		default:
+			return nil, errorf(p.line, "expected ',' or '%c' in flow collection", closing)
+		}
+	}
+}
+
+func (p *flowParser) entry(m *value) error {
+	p.skipSpaces()
+	if p.pos >= len(p.text) {
+		return p.unterminated()
+	}
+	raw, err := p.scalar(":,}")
+	if err != nil {
+		return err
+	}
+	key := raw
+	if raw[0] == '"' || raw[0] == '\'' {
+		if key, err = unquote(raw); err != nil {
+			return errorf(p.line, "malformed quoted string %s", raw)
+		}
+	}
+
+	p.skipSpaces()
+	if p.pos >= len(p.text) || p.text[p.pos] != ':' {
+		return errorf(p.line, "expected \"key: value\" in flow mapping after %q", key)
+	}
+	p.pos++
+
+	if _, dup := m.fields[key]; dup {
+		return errorf(p.line, "duplicate key %q", key)
+	}
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	m.set(key, v)
+	return nil
+}
@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Frame types. Sender to receiver: hello, data, end. Receiver to sender: welcome, credit, endAck.
+const (
+	frameHello   byte = iota + 1 // stream id, codec name
+	frameData                    // seq uint64, encoded item
+	frameEnd                     // total uint64
+	frameWelcome                 // next seq uint64, credits uint32
+	frameCredit                  // ack seq uint64, credits uint32
+	frameEndAck                  // empty
+)
+
+// maxFrameSize protects the reader against corrupted length prefixes.
+const maxFrameSize = 64 << 20
+
+var (
+	ErrFrameTooLarge   = errors.New("transport: frame too large")
+	ErrUnexpectedFrame = errors.New("transport: unexpected frame")
+	ErrCodecMismatch   = errors.New("transport: codec mismatch")
+)
+
+type frame struct {
+	typ     byte
+	payload []byte
+}
+
+// writeFrame writes a frame as: type (1 byte), payload length (4 bytes, big endian), payload.
+func writeFrame(w *bufio.Writer, typ byte, payload []byte) error {
+	var hdr [5]byte
+	hdr[0] = typ
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(payload)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func readFrame(r io.Reader) (frame, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return frame{}, err
+	}
+
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > maxFrameSize {
+		return frame{}, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return frame{}, err
+	}
+	return frame{typ: hdr[0], payload: payload}, nil
+}
+
+func putSeq(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, seq)
+}
+
+func putSeqCredits(seq uint64, credits uint32) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, seq)
+	binary.BigEndian.PutUint32(b[8:], credits)
+	return b
+}
+
+func parseSeqCredits(p []byte) (uint64, uint32, error) {
+	if len(p) != 12 {
+		return 0, 0, fmt.Errorf("%w: bad length %d", ErrUnexpectedFrame, len(p))
+	}
+	return binary.BigEndian.Uint64(p), binary.BigEndian.Uint32(p[8:]), nil
+}
+
+func parseSeq(p []byte) (uint64, []byte, error) {
+	if len(p) < 8 {
+		return 0, nil, fmt.Errorf("%w: bad length %d", ErrUnexpectedFrame, len(p))
+	}
+	return binary.BigEndian.Uint64(p), p[8:], nil
+}
+
+// payloadReader feeds one frame payload at a time to a codec Decoder, which keeps its state
+// (e.g. gob type information) across frames of one connection. It implements io.ByteReader
+// so decoders do not read ahead.
+type payloadReader struct {
+	buf []byte
+}
+
+func (r *payloadReader) reset(p []byte) {
+	r.buf = p
+}
+
+func (r *payloadReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *payloadReader) ReadByte() (byte, error) {
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b, nil
+}
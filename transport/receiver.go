@@ -0,0 +1,242 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+)
+
+// Receive returns a Generator that accepts sender streams on ln and emits their items.
+// The generator ends once opts.Streams senders have finished their streams; ln is closed
+// when it stops. Protocol and decoding errors are reported with nodes.ReportError.
+func Receive[T any](ln net.Listener, opts ...Options) nodes.Generator[T] {
+	o := optionsOf(opts)
+
+	return func(ctx context.Context) (<-chan T, error) {
+		r := &receiver[T]{
+			ln:       ln,
+			opts:     o,
+			streams:  make(map[streamID]*streamState),
+			conns:    make(map[net.Conn]struct{}),
+			finished: make(chan struct{}),
+		}
+		out := make(chan T)
+		go r.serve(ctx, out)
+		return out, nil
+	}
+}
+
+type receiver[T any] struct {
+	ln   net.Listener
+	opts Options
+
+	mu       sync.Mutex
+	streams  map[streamID]*streamState
+	conns    map[net.Conn]struct{}
+	done     int
+	finished chan struct{}
+}
+
+// streamState is the delivery state of one sender. Its mutex is held by the connection
+// currently serving the stream, so a reconnecting sender waits for the old one to stop.
+type streamState struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	nextSeq uint64
+	ended   bool
+}
+
+func (r *receiver[T]) serve(ctx context.Context, out chan<- T) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer func() {
+		r.ln.Close()
+		r.mu.Lock()
+		for conn := range r.conns {
+			conn.Close()
+		}
+		r.mu.Unlock()
+		wg.Wait()
+		close(out)
+	}()
+
+	go func() {
+		select {
+		case <-r.finished:
+		case <-ctx.Done():
+		}
+		r.ln.Close()
+	}()
+
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			select {
+			case <-r.finished:
+			case <-ctx.Done():
+			default:
+				nodes.ReportError(ctx, err)
+			}
+			return
+		}
+
+		r.mu.Lock()
+		r.conns[conn] = struct{}{}
+		r.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer r.forget(conn)
+
+			if err := r.handle(ctx, conn, out); err != nil {
+				nodes.ReportError(ctx, err)
+				cancel()
+			}
+		}()
+	}
+}
+
+func (r *receiver[T]) forget(conn net.Conn) {
+	conn.Close()
+	r.mu.Lock()
+	delete(r.conns, conn)
+	r.mu.Unlock()
+}
+
+// handle serves one connection. Connection failures are not errors, since the sender
+// reconnects; only protocol and decoding problems are returned.
+func (r *receiver[T]) handle(ctx context.Context, conn net.Conn, out chan<- T) error {
+	hello, err := readFrame(conn)
+	if err != nil {
+		return nil
+	}
+	if hello.typ != frameHello || len(hello.payload) < len(streamID{}) {
+		return fmt.Errorf("%w %d, want hello", ErrUnexpectedFrame, hello.typ)
+	}
+	id := streamID(hello.payload[:len(streamID{})])
+	if name := string(hello.payload[len(streamID{}):]); name != r.opts.Codec.Name() {
+		return fmt.Errorf("%w: sender uses %q, receiver %q", ErrCodecMismatch, name, r.opts.Codec.Name())
+	}
+
+	st := r.takeOver(id, conn)
+	defer st.mu.Unlock()
+
+	w := bufio.NewWriter(conn)
+	if st.ended {
+		writeFrame(w, frameEndAck, nil)
+		return nil
+	}
+	if err := writeFrame(w, frameWelcome, putSeqCredits(st.nextSeq, uint32(r.opts.Window))); err != nil {
+		return nil
+	}
+
+	var (
+		pr       payloadReader
+		dec      = r.opts.Codec.NewDecoder(&pr)
+		consumed = 0
+		batch    = max(r.opts.Window/2, 1)
+	)
+
+	for {
+		f, err := readFrame(conn)
+		if err != nil {
+			return nil
+		}
+
+		switch f.typ {
+		case frameData:
+			seq, body, err := parseSeq(f.payload)
+			if err != nil {
+				return err
+			}
+
+			// decode every frame, even duplicates, to keep the decoder state in sync
+			var item T
+			pr.reset(body)
+			if err := dec.Decode(&item); err != nil {
+				return fmt.Errorf("transport: decode item %d: %w", seq, err)
+			}
+
+			switch {
+			case seq == st.nextSeq:
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return nil
+				}
+				st.nextSeq++
+			case seq > st.nextSeq:
+				return fmt.Errorf("%w: item %d, expected %d", ErrUnexpectedFrame, seq, st.nextSeq)
+			}
+
+			consumed++
+			if consumed >= batch {
+				if err := writeFrame(w, frameCredit, putSeqCredits(st.nextSeq, uint32(consumed))); err != nil {
+					return nil
+				}
+				consumed = 0
+			}
+
+		case frameEnd:
+			total, _, err := parseSeq(f.payload)
+			if err != nil {
+				return err
+			}
+			if total != st.nextSeq {
+				return fmt.Errorf("%w: stream ended at %d, received %d", ErrUnexpectedFrame, total, st.nextSeq)
+			}
+			// acknowledge before finishing: once every stream has finished, the receiver may close
+			// its connections and the sender would retry a stream that has already ended
+			st.ended = true
+			writeFrame(w, frameEndAck, nil)
+			r.finish()
+			return nil
+
+		default:
+			return fmt.Errorf("%w %d", ErrUnexpectedFrame, f.typ)
+		}
+	}
+}
+
+// takeOver makes conn the serving connection of stream id, closing a previous one,
+// and returns the stream with its mutex held.
+func (r *receiver[T]) takeOver(id streamID, conn net.Conn) *streamState {
+	r.mu.Lock()
+	st, ok := r.streams[id]
+	if !ok {
+		st = &streamState{}
+		r.streams[id] = st
+	}
+	st.closeConn()
+	r.mu.Unlock()
+
+	st.mu.Lock()
+	r.mu.Lock()
+	st.conn = conn
+	r.mu.Unlock()
+	return st
+}
+
+// closeConn closes the serving connection; it must be called with receiver.mu held.
+func (st *streamState) closeConn() {
+	if st.conn != nil {
+		st.conn.Close()
+	}
+}
+
+func (r *receiver[T]) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.done++
+	if r.done == r.opts.Streams {
+		close(r.finished)
+	}
+}
@@ -0,0 +1,272 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/codec"
+	"github.com/Sergey-Polishchenko/pipelines/pkg/utils"
+)
+
+var _ pipelines.Node[any, any] = &sender[any]{}
+
+var senderCounter atomic.Uint64
+
+type sender[T any] struct {
+	id uint64
+
+	in      []<-chan T
+	network string
+	address string
+	stream  streamID
+
+	opts Options
+}
+
+// NewSender creates a node that streams its inputs to a receiver listening on network/address
+// ("tcp", "host:port" or "unix", "/path/to.sock"), see Receive. Like an aggregator it has no
+// outputs. It reconnects after connection failures, waiting opts.RetryInterval between attempts,
+// and resends items the receiver has not acknowledged. Run returns once the receiver has
+// confirmed the end of the stream.
+func NewSender[T any](network, address string, opts ...Options) pipelines.Node[T, any] {
+	return &sender[T]{
+		id:      senderCounter.Add(1),
+		network: network,
+		address: address,
+		stream:  newStreamID(),
+		opts:    optionsOf(opts),
+	}
+}
+
+func (n *sender[T]) ID() string {
+	return fmt.Sprintf("transport-sender-node-%d", n.id)
+}
+
+func (n *sender[T]) SetInput(in ...<-chan T) error {
+	n.in = append(n.in, in...)
+	return nil
+}
+
+func (n *sender[T]) Output() (chan any, error) {
+	return nil, nodes.ErrHasNoOutput
+}
+
+// fatalError marks errors that reconnecting cannot fix.
+type fatalError struct {
+	err error
+}
+
+func (e fatalError) Error() string { return e.err.Error() }
+func (e fatalError) Unwrap() error { return e.err }
+
+type pendingItem[T any] struct {
+	seq  uint64
+	item T
+}
+
+// sendState survives reconnects: items not yet acknowledged are kept in pending.
+type sendState[T any] struct {
+	in        <-chan T
+	inputDone bool
+	pending   []pendingItem[T]
+	nextSeq   uint64
+}
+
+func (n *sender[T]) Run(ctx context.Context) error {
+	in, err := utils.FanIn(ctx, n.in, 0)
+	if err != nil {
+		return err
+	}
+	state := &sendState[T]{in: in}
+
+	failures := 0
+	for {
+		conn, err := n.dial(ctx)
+		if err == nil {
+			var done bool
+			done, err = n.serve(ctx, conn, state)
+			conn.Close()
+			if done {
+				return nil
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var fatal fatalError
+		if errors.As(err, &fatal) {
+			return fmt.Errorf("%s: %w", n.ID(), err)
+		}
+
+		failures++
+		if n.opts.MaxRetries > 0 && failures > n.opts.MaxRetries {
+			return fmt.Errorf("%s: giving up after %d attempts: %w", n.ID(), failures, err)
+		}
+
+		select {
+		case <-time.After(n.opts.RetryInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (n *sender[T]) dial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, n.network, n.address)
+}
+
+type controlEvent struct {
+	frame frame
+	err   error
+}
+
+// serve runs one connection. It reports done once the receiver acknowledged the end of stream.
+func (n *sender[T]) serve(ctx context.Context, conn net.Conn, s *sendState[T]) (done bool, err error) {
+	// reads from conn do not watch ctx, so a receiver that never answers is cut off by closing it
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	w := bufio.NewWriter(conn)
+
+	hello := append(n.stream[:], n.opts.Codec.Name()...)
+	if err := writeFrame(w, frameHello, hello); err != nil {
+		return false, err
+	}
+
+	welcome, err := readFrame(conn)
+	if err != nil {
+		return false, err
+	}
+	if welcome.typ == frameEndAck {
+		// the stream was finished before the last connection broke
+		return true, nil
+	}
+	if welcome.typ != frameWelcome {
+		return false, fatalError{fmt.Errorf("%w %d, want welcome", ErrUnexpectedFrame, welcome.typ)}
+	}
+	ack, credits, err := parseSeqCredits(welcome.payload)
+	if err != nil {
+		return false, fatalError{err}
+	}
+	s.acknowledge(ack)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	control := make(chan controlEvent)
+	go readControl(conn, control, stop)
+
+	var (
+		enc     = newFrameEncoder(n.opts.Codec)
+		sent    = 0
+		endSent = false
+	)
+
+	for {
+		for sent < len(s.pending) && credits > 0 {
+			if err := enc.writeItem(w, s.pending[sent].seq, s.pending[sent].item); err != nil {
+				return false, err
+			}
+			sent++
+			credits--
+		}
+
+		if s.inputDone && sent == len(s.pending) && !endSent {
+			if err := writeFrame(w, frameEnd, putSeq(s.nextSeq)); err != nil {
+				return false, err
+			}
+			endSent = true
+		}
+
+		// read new input only when it can be sent right away, so backpressure reaches upstream
+		var in <-chan T
+		if !s.inputDone && credits > 0 && sent == len(s.pending) {
+			in = s.in
+		}
+
+		select {
+		case item, open := <-in:
+			if !open {
+				s.inputDone = true
+				continue
+			}
+			s.pending = append(s.pending, pendingItem[T]{seq: s.nextSeq, item: item})
+			s.nextSeq++
+
+		case ev := <-control:
+			if ev.err != nil {
+				return false, ev.err
+			}
+			switch ev.frame.typ {
+			case frameCredit:
+				ack, granted, err := parseSeqCredits(ev.frame.payload)
+				if err != nil {
+					return false, fatalError{err}
+				}
+				sent -= s.acknowledge(ack)
+				credits += granted
+			case frameEndAck:
+				return true, nil
+			default:
+				return false, fatalError{fmt.Errorf("%w %d", ErrUnexpectedFrame, ev.frame.typ)}
+			}
+
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// acknowledge drops pending items below seq and returns how many were dropped.
+func (s *sendState[T]) acknowledge(seq uint64) int {
+	k := 0
+	for k < len(s.pending) && s.pending[k].seq < seq {
+		k++
+	}
+	s.pending = s.pending[k:]
+	return k
+}
+
+func readControl(conn net.Conn, control chan<- controlEvent, stop <-chan struct{}) {
+	for {
+		f, err := readFrame(conn)
+		select {
+		case control <- controlEvent{frame: f, err: err}:
+		case <-stop:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// frameEncoder encodes items of one connection into data frames, keeping the codec state
+// (e.g. gob type information) across frames.
+type frameEncoder struct {
+	buf bytes.Buffer
+	enc codec.Encoder
+}
+
+func newFrameEncoder(c codec.Codec) *frameEncoder {
+	e := &frameEncoder{}
+	e.enc = c.NewEncoder(&e.buf)
+	return e
+}
+
+func (e *frameEncoder) writeItem(w *bufio.Writer, seq uint64, item any) error {
+	e.buf.Reset()
+	e.buf.Write(putSeq(seq))
+	if err := e.enc.Encode(item); err != nil {
+		return fatalError{err}
+	}
+	return writeFrame(w, frameData, e.buf.Bytes())
+}
@@ -0,0 +1,65 @@
+// Package transport splits a pipeline across processes. NewSender is a sink node that streams
+// its inputs over TCP or Unix sockets; Receive is a generator that accepts such streams and
+// emits the items on the other side.
+//
+// Items are encoded with a pluggable codec and sent in length-prefixed frames. The receiver
+// grants credits as the pipeline consumes items, so a slow consumer applies backpressure to the
+// sender across the wire. Credits also acknowledge delivery: after a dropped connection the
+// sender reconnects and resends unacknowledged items, and the receiver skips those it has
+// already delivered. A sender finishes only after the receiver has confirmed the end of its stream.
+package transport
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines/pkg/codec"
+)
+
+// Options configures senders and receivers. Zero fields take the defaults listed below.
+type Options struct {
+	// Codec encodes items (default codec.Gob). Both sides must use the same codec.
+	Codec codec.Codec
+
+	// Window is the number of items a sender may have in flight (receiver side, default 64).
+	Window int
+	// Streams is the number of senders the receiver waits for before it ends (default 1).
+	Streams int
+
+	// RetryInterval is the delay between reconnect attempts of a sender (default 500ms).
+	RetryInterval time.Duration
+	// MaxRetries limits consecutive failed connection attempts of a sender; zero means
+	// retrying until the context is canceled.
+	MaxRetries int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Codec == nil {
+		o.Codec = codec.Gob
+	}
+	if o.Window <= 0 {
+		o.Window = 64
+	}
+	if o.Streams <= 0 {
+		o.Streams = 1
+	}
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = 500 * time.Millisecond
+	}
+	return o
+}
+
+func optionsOf(opts []Options) Options {
+	if len(opts) > 0 {
+		return opts[0].withDefaults()
+	}
+	return Options{}.withDefaults()
+}
+
+type streamID [16]byte
+
+func newStreamID() streamID {
+	var id streamID
+	rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,202 @@
+package transport_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sergey-Polishchenko/pipelines"
+	"github.com/Sergey-Polishchenko/pipelines/nodes"
+	"github.com/Sergey-Polishchenko/pipelines/pipelinetest"
+	"github.com/Sergey-Polishchenko/pipelines/sinks"
+	"github.com/Sergey-Polishchenko/pipelines/sources"
+	"github.com/Sergey-Polishchenko/pipelines/transport"
+)
+
+// transfer передаёт items от отправителя к получателю через ln и возвращает полученное
+func transfer(t *testing.T, ln net.Listener, items []int, opts transport.Options) []int {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	gen := nodes.NewGenerator(sources.Slice(items))
+	send := transport.NewSender[int](ln.Addr().Network(), ln.Addr().String(), opts)
+	if err := pipelines.Connect(gen, send); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	sender := pipelines.New()
+	sender.Add(gen, send)
+
+	recv := nodes.NewGenerator(transport.Receive[int](ln, opts))
+	collect := sinks.Collect[int]()
+	agg := nodes.NewWriterAggregator(collect)
+	if err := pipelines.Connect(recv, agg); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	receiver := pipelines.New()
+	receiver.Add(recv, agg)
+
+	var (
+		wg      sync.WaitGroup
+		sendErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sendErr = sender.Run(ctx)
+	}()
+
+	if err := receiver.Run(ctx); err != nil {
+		t.Fatalf("receiver failed: %v", err)
+	}
+	wg.Wait()
+	if sendErr != nil {
+		t.Fatalf("sender failed: %v", sendErr)
+	}
+	return collect.Items()
+}
+
+func sequence(n int) []int {
+	items := make([]int, n)
+	for i := range items {
+		items[i] = i
+	}
+	return items
+}
+
+func checkSequence(t *testing.T, got []int, n int) {
+	t.Helper()
+
+	if len(got) != n {
+		t.Fatalf("received %d items, want %d", len(got), n)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("item %d = %d, want %d", i, v, i)
+		}
+	}
+}
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	got := transfer(t, ln, sequence(1000), transport.Options{Window: 8})
+	checkSequence(t, got, 1000)
+}
+
+func TestUnixSocket(t *testing.T) {
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "pipe.sock"))
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	got := transfer(t, ln, sequence(500), transport.Options{})
+	checkSequence(t, got, 500)
+}
+
+// flakyListener обрывает первые соединения после limit прочитанных байт
+type flakyListener struct {
+	net.Listener
+
+	mu    sync.Mutex
+	drops int
+	limit int
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.drops == 0 {
+		return conn, nil
+	}
+	l.drops--
+	return &flakyConn{Conn: conn, left: l.limit}, nil
+}
+
+type flakyConn struct {
+	net.Conn
+	left int
+}
+
+func (c *flakyConn) Read(p []byte) (int, error) {
+	if c.left <= 0 {
+		c.Conn.Close()
+		return 0, net.ErrClosed
+	}
+	if len(p) > c.left {
+		p = p[:c.left]
+	}
+	n, err := c.Conn.Read(p)
+	c.left -= n
+	return n, err
+}
+
+func TestReconnectDeliversExactlyOnce(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	flaky := &flakyListener{Listener: ln, drops: 3, limit: 300}
+
+	opts := transport.Options{Window: 4, RetryInterval: 10 * time.Millisecond}
+	got := transfer(t, flaky, sequence(300), opts)
+
+	// Обрывы не должны приводить ни к потерям, ни к повторам
+	checkSequence(t, got, 300)
+}
+
+func TestSenderCancelBeforeWelcome(t *testing.T) {
+	pipelinetest.VerifyNoLeaks(t)
+
+	// Слушатель принимает соединение, но никогда не отвечает на приветствие
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	send := transport.NewSender[int](ln.Addr().Network(), ln.Addr().String())
+	if err := send.SetInput(make(chan int)); err != nil {
+		t.Fatalf("SetInput failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	errc := make(chan error, 1)
+	go func() { errc <- send.Run(ctx) }()
+
+	// Заголовок приветствия получен: отправитель ждёт ответа
+	conn := <-accepted
+	defer conn.Close()
+	if _, err := io.ReadFull(conn, make([]byte, 5)); err != nil {
+		t.Fatalf("reading hello failed: %v", err)
+	}
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sender did not return while waiting for the welcome frame")
+	}
+}